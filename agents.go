@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// AR is the registry of agents
+var AR = AgentRegistry{agents: make(map[string]*Agent)}
+
+var errAgentExists = errors.New("agent id already registered")
+
+// Presence states of an Agent
+const (
+	AVAILABLE = "AVAILABLE"
+	BUSY      = "BUSY"
+	WRAPUP    = "WRAP_UP"
+	AWAY      = "AWAY"
+)
+
+func isValidState(state string) bool {
+	return state == AVAILABLE || state == BUSY || state == WRAPUP || state == AWAY
+}
+
+// This function registers agent, IDs must be unique. Agents are AVAILABLE unless another state is given.
+func registerAgent(ar *AgentRegistry, agent *Agent) error {
+	logger.Printf("registering agent %s", agent.ID)
+	if agent.ID == "" {
+		return errors.New("agent id is required")
+	}
+	for skill, proficiency := range agent.Skills {
+		if proficiency < 1 || proficiency > 10 {
+			return errors.New("proficiency of skill " + skill + " must be from 1 to 10")
+		}
+	}
+	if agent.State == "" {
+		agent.State = AVAILABLE
+	}
+	if !isValidState(agent.State) {
+		return errors.New("state must be one of AVAILABLE, BUSY, WRAP_UP and AWAY")
+	}
+	agent.StateSince = time.Now()
+	agent.Assignment = nil
+	ar.mutex.Lock()
+	defer ar.mutex.Unlock()
+	if _, ok := ar.agents[agent.ID]; ok {
+		return errAgentExists
+	}
+	ar.agents[agent.ID] = agent
+	logger.Printf("registered agent %s with skills %v", agent.ID, agent.Skills)
+	return nil
+}
+
+// This function unregisters the agent with id=agentID, agents working on a request can not be unregistered
+func unregisterAgent(ar *AgentRegistry, agentID string) (Agent, error) {
+	logger.Printf("unregistering agent %s", agentID)
+	ar.mutex.Lock()
+	defer ar.mutex.Unlock()
+	agent, ok := ar.agents[agentID]
+	if !ok {
+		return Agent{}, errors.New("agent not found")
+	}
+	if agent.Assignment != nil {
+		return Agent{}, errors.New("agent is working on a customer request")
+	}
+	delete(ar.agents, agentID)
+	return *agent, nil
+}
+
+// This function returns a copy of the agent with id=agentID
+func getAgentByID(ar *AgentRegistry, agentID string) (Agent, error) {
+	ar.mutex.Lock()
+	defer ar.mutex.Unlock()
+	agent, ok := ar.agents[agentID]
+	if !ok {
+		logger.Printf("agent %s not found", agentID)
+		return Agent{}, errors.New("agent not found")
+	}
+	return *agent, nil
+}
+
+// This function returns copies of all agents ordered by ID
+func listAgents(ar *AgentRegistry) []Agent {
+	ar.mutex.Lock()
+	defer ar.mutex.Unlock()
+	agents := make([]Agent, 0, len(ar.agents))
+	for _, agent := range ar.agents {
+		agents = append(agents, *agent)
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].ID < agents[j].ID })
+	return agents
+}
+
+// This function changes the presence state of the agent with id=agentID.
+// The assignment is kept during WRAP_UP and cleared once the agent is AVAILABLE or AWAY.
+func setAgentState(ar *AgentRegistry, agentID string, state string) (Agent, error) {
+	logger.Printf("setting state of agent %s to %s", agentID, state)
+	if !isValidState(state) {
+		return Agent{}, errors.New("state must be one of AVAILABLE, BUSY, WRAP_UP and AWAY")
+	}
+	ar.mutex.Lock()
+	defer ar.mutex.Unlock()
+	agent, ok := ar.agents[agentID]
+	if !ok {
+		return Agent{}, errors.New("agent not found")
+	}
+	if agent.State != state {
+		agent.State = state
+		agent.StateSince = time.Now()
+	}
+	if state == AVAILABLE || state == AWAY {
+		agent.Assignment = nil
+	}
+	return *agent, nil
+}
+
+// This function counts the agents in every presence state
+func countAgents(ar *AgentRegistry) AgentsInfo {
+	ar.mutex.Lock()
+	defer ar.mutex.Unlock()
+	info := AgentsInfo{}
+	for _, agent := range ar.agents {
+		switch agent.State {
+		case AVAILABLE:
+			info.Available++
+		case BUSY:
+			info.Busy++
+		case WRAPUP:
+			info.WrapUp++
+		case AWAY:
+			info.Away++
+		}
+	}
+	return info
+}
+
+// This method is for Servicing Customer Request by the agent with id=agentID.
+// The agent has to be AVAILABLE and becomes BUSY with the serviced request as its assignment.
+func serviceForAgent(ar *AgentRegistry, pq *PriorityQueue, agentID string, isConsole bool) (Selection3Struct, ErrorStruct, error) {
+	logger.Printf("servicing for agent %s, isConsole: %t", agentID, isConsole)
+	ar.mutex.Lock()
+	defer ar.mutex.Unlock()
+	agent, ok := ar.agents[agentID]
+	errorMsg := ""
+	if !ok {
+		errorMsg = "agent not found"
+	} else if agent.State != AVAILABLE {
+		errorMsg = "agent is not AVAILABLE"
+	}
+	if errorMsg != "" {
+		if isConsole {
+			fmt.Println(errorMsg)
+		}
+		logger.Printf("error servicing for agent %s. %s", agentID, errorMsg)
+		return Selection3Struct{}, ErrorStruct{Msg: errorMsg}, errors.New(errorMsg)
+	}
+
+	s3Struct, errorStruct, err := selection3(pq, agent, false)
+	if err != nil {
+		if isConsole {
+			fmt.Println(err)
+		}
+		return s3Struct, errorStruct, err
+	}
+	agent.State = BUSY
+	agent.StateSince = time.Now()
+	agent.Assignment = &Assignment{
+		RequestID:    s3Struct.ID,
+		CustomerName: s3Struct.CustomerName,
+		AssignedAt:   agent.StateSince}
+
+	if isConsole {
+		fmt.Println("Dequeuing Customer Request")
+		jsonData, _ := json.MarshalIndent(s3Struct, "", "    ")
+		fmt.Println(string(jsonData))
+	}
+	return s3Struct, ErrorStruct{}, nil
+}
+
+// This function checks if agent has every skill required by cr at the required proficiency
+// and has not rejected an offer of cr before
+func canHandle(pq *PriorityQueue, agent *Agent, cr *CustomerRequest) bool {
+	if pq.declined[cr.ID][agent.ID] {
+		return false
+	}
+	for skill, minProficiency := range cr.RequiredSkills {
+		proficiency, ok := agent.Skills[skill]
+		if !ok || proficiency < minProficiency {
+			return false
+		}
+	}
+	return true
+}
+
+// This function returns the CustomerRequest with highest priority that agent can handle.
+// A nil agent can handle everything, so the top of the heap is returned.
+func peekForAgent(pq *PriorityQueue, agent *Agent) (*CustomerRequest, error) {
+	if pq.queue.Len() <= 0 {
+		return nil, errors.New("Queue is empty.")
+	}
+	if agent == nil {
+		return pq.queue.Peek()
+	}
+	cr, err := pq.queue.PeekFunc(func(cr *CustomerRequest) bool { return canHandle(pq, agent, cr) })
+	if err != nil {
+		return nil, errors.New("No customer request matches the skills of the agent.")
+	}
+	return cr, nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Roles of API clients
+const (
+	CUSTOMER   = "customer"
+	AGENT      = "agent"
+	SUPERVISOR = "supervisor"
+	ADMIN      = "admin"
+)
+
+// APIKEYS maps every API key to the client it belongs to, see loadAuthConfig
+var APIKEYS = make(map[string]Principal)
+
+// JWTSECRET is the HMAC secret HS256 bearer tokens are signed with
+var JWTSECRET []byte
+
+// routeRoles maps every route name to the roles allowed to call it, routes not listed are public
+var routeRoles = map[string][]string{
+	"list":             {AGENT, SUPERVISOR, ADMIN},
+	"detail":           {SUPERVISOR, ADMIN},
+	"service":          {AGENT, SUPERVISOR, ADMIN},
+	"enqueue":          {CUSTOMER, SUPERVISOR, ADMIN},
+	"renege":           {CUSTOMER, SUPERVISOR, ADMIN},
+	"enqueueBatch":     {CUSTOMER, SUPERVISOR, ADMIN},
+	"renegeBatch":      {CUSTOMER, SUPERVISOR, ADMIN},
+	"priority":         {SUPERVISOR, ADMIN},
+	"ordering":         {ADMIN},
+	"capacity":         {SUPERVISOR, ADMIN},
+	"quotas":           {SUPERVISOR, ADMIN},
+	"listScheduled":    {SUPERVISOR, ADMIN},
+	"cancelScheduled":  {CUSTOMER, SUPERVISOR, ADMIN},
+	"requestStatus":    {CUSTOMER, SUPERVISOR, ADMIN},
+	"renegeRequest":    {CUSTOMER, SUPERVISOR, ADMIN},
+	"customerRequests": {CUSTOMER, SUPERVISOR, ADMIN},
+	"renegeCustomer":   {CUSTOMER, SUPERVISOR, ADMIN},
+	"request":          {CUSTOMER, AGENT, SUPERVISOR, ADMIN},
+	"listAgents":       {AGENT, SUPERVISOR, ADMIN},
+	"registerAgent":    {ADMIN},
+	"getAgent":         {AGENT, SUPERVISOR, ADMIN},
+	"unregisterAgent":  {ADMIN},
+	"agentState":       {AGENT, SUPERVISOR, ADMIN},
+	"getOffer":         {AGENT, SUPERVISOR, ADMIN},
+	"acceptOffer":      {AGENT, SUPERVISOR, ADMIN},
+	"rejectOffer":      {AGENT, SUPERVISOR, ADMIN},
+	"systemInfo":       {AGENT, SUPERVISOR, ADMIN},
+	"stats":            {SUPERVISOR, ADMIN},
+	"reports":          {SUPERVISOR, ADMIN},
+	"events":           {SUPERVISOR, ADMIN},
+}
+
+type principalKey struct{}
+
+// This function loads API keys given as comma separated key:role:clientID entries and the JWT secret.
+// Customer and agent keys must have a clientID as they may only act for themselves, supervisor and
+// admin keys without one are identified by their role. Authentication is disabled if neither is given.
+func loadAuthConfig(apiKeys string, jwtSecret string) error {
+	keys := make(map[string]Principal)
+	for _, entry := range strings.Split(apiKeys, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) < 2 || len(parts) > 3 || !isValidRole(parts[1]) ||
+			(len(parts) == 2 && (parts[1] == CUSTOMER || parts[1] == AGENT)) || (len(parts) == 3 && parts[2] == "") {
+			return errors.New("API keys must be given as key:role:clientID")
+		}
+		principal := Principal{ClientID: parts[1], Role: parts[1]} // the key itself must never be used as ID
+		if len(parts) == 3 {
+			principal.ClientID = parts[2]
+		}
+		keys[parts[0]] = principal
+	}
+	APIKEYS = keys
+	JWTSECRET = []byte(jwtSecret)
+	if !authEnabled() {
+		logger.Println("WARNING: no API keys or JWT secret configured, authentication is disabled")
+	}
+	return nil
+}
+
+func authEnabled() bool {
+	return len(APIKEYS) > 0 || len(JWTSECRET) > 0
+}
+
+func isValidRole(role string) bool {
+	return role == CUSTOMER || role == AGENT || role == SUPERVISOR || role == ADMIN
+}
+
+// This function returns the client who made r, every client is an admin if authentication is disabled
+func principalFrom(r *http.Request) Principal {
+	if principal, ok := r.Context().Value(principalKey{}).(Principal); ok {
+		return principal
+	}
+	return Principal{Role: ADMIN}
+}
+
+// This function reports if r was authenticated as a supervisor or admin. Nobody is while
+// authentication is disabled, as anyone could claim to be.
+func isStaff(r *http.Request) bool {
+	principal, ok := r.Context().Value(principalKey{}).(Principal)
+	return ok && (principal.Role == SUPERVISOR || principal.Role == ADMIN)
+}
+
+// This function checks if the client may act on a resource owned by ownerID.
+// Customers and agents may only act on their own resources.
+func mayActFor(principal Principal, ownerID string) bool {
+	if principal.Role == SUPERVISOR || principal.Role == ADMIN {
+		return true
+	}
+	return principal.ClientID == ownerID
+}
+
+// This function authenticates r by its X-API-Key header or its HS256 bearer token
+func authenticate(r *http.Request) (Principal, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		for k, principal := range APIKEYS {
+			if hmac.Equal([]byte(k), []byte(key)) {
+				return principal, nil
+			}
+		}
+		return Principal{}, errors.New("invalid API key")
+	}
+	auth := r.Header.Get("Authorization")
+	if strings.HasPrefix(auth, "Bearer ") && len(JWTSECRET) > 0 {
+		return verifyJWT(strings.TrimPrefix(auth, "Bearer "), JWTSECRET, time.Now())
+	}
+	return Principal{}, errors.New("API key or bearer token required")
+}
+
+// This function verifies an HS256 signed JWT and returns the client from its sub and role claims
+func verifyJWT(token string, secret []byte, now time.Time) (Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, errors.New("malformed bearer token")
+	}
+	header := struct {
+		Alg string `json:"alg"`
+	}{}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(headerJSON, &header) != nil || header.Alg != "HS256" {
+		return Principal{}, errors.New("bearer token must be signed with HS256")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if err != nil || !hmac.Equal(signature, mac.Sum(nil)) {
+		return Principal{}, errors.New("invalid bearer token signature")
+	}
+	claims := struct {
+		Sub  string `json:"sub"`
+		Role string `json:"role"`
+		Exp  int64  `json:"exp"`
+		Nbf  int64  `json:"nbf"`
+	}{}
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(claimsJSON, &claims) != nil {
+		return Principal{}, errors.New("malformed bearer token claims")
+	}
+	if claims.Exp == 0 || now.Unix() >= claims.Exp || now.Unix() < claims.Nbf {
+		return Principal{}, errors.New("bearer token expired or not yet valid")
+	}
+	if claims.Sub == "" || !isValidRole(claims.Role) {
+		return Principal{}, errors.New("bearer token must have sub and a valid role")
+	}
+	return Principal{ClientID: claims.Sub, Role: claims.Role}, nil
+}
+
+// This function writes an error in the same format as the enqueue errors
+func writeError(w http.ResponseWriter, status int, errorCode string, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	enc.Encode(Selection4ErrorStruct{Error: errorCode, Msg: msg})
+}
+
+// This method is used as middleware to authenticate clients and check their role for the route
+func authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := ""
+		if current := mux.CurrentRoute(r); current != nil {
+			name = current.GetName()
+		}
+		roles, protected := routeRoles[name]
+		if !protected || !authEnabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		principal, err := authenticate(r)
+		if err != nil {
+			logger.Printf("unauthorized request to %s. %s", r.URL.Path, err.Error())
+			w.Header().Set("WWW-Authenticate", `Bearer realm="PriorityQueue"`)
+			writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+			return
+		}
+		for _, role := range roles {
+			if role == principal.Role {
+				ctx := context.WithValue(r.Context(), principalKey{}, principal)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+		}
+		logger.Printf("forbidden request to %s by %s with role %s", r.URL.Path, principal.ClientID, principal.Role)
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "role "+principal.Role+" may not call this endpoint")
+	})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signJWT(claims string, secret []byte) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(header + "." + payload))
+	return header + "." + payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func doRequest(router http.Handler, method string, url string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// This test checks that customer and agent keys must have a client ID, so customers do not share an identity
+func TestLoadAuthConfig(t *testing.T) {
+	defer loadAuthConfig("", "")
+	for _, keys := range []string{"c1key:customer", "c1key:customer:", "agentkey:agent", "key:owner:c1"} {
+		if err := loadAuthConfig(keys, ""); err == nil {
+			t.Errorf("loadAuthConfig() failed. Expected an error for %q", keys)
+		}
+	}
+	if err := loadAuthConfig("supkey:supervisor,adminkey:admin", ""); err != nil || APIKEYS["supkey"].ClientID != SUPERVISOR {
+		t.Errorf("loadAuthConfig() failed. Supervisor keys without a client ID should be identified by their role, got %v", err)
+	}
+}
+
+// This test checks that clients are authenticated and may only call the endpoints of their role
+func TestAuthMiddleware(t *testing.T) {
+	if err := loadAuthConfig("c1key:customer:c1,c2key:customer:c2,agentkey:agent:a1,supkey:supervisor", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	defer loadAuthConfig("", "")
+	router := newRouter()
+
+	if rec := doRequest(router, "GET", "/api/v1.0/queue/detail", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("authMiddleware() failed. Expected 401 without credentials, got %d", rec.Code)
+	}
+	if rec := doRequest(router, "GET", "/api/v1.0/queue/detail", "", map[string]string{"X-API-Key": "agentkey"}); rec.Code != http.StatusForbidden {
+		t.Errorf("authMiddleware() failed. Expected 403 for agents, got %d", rec.Code)
+	}
+	if rec := doRequest(router, "GET", "/api/v1.0/queue/detail", "", map[string]string{"X-API-Key": "supkey"}); rec.Code != http.StatusOK {
+		t.Errorf("authMiddleware() failed. Expected 200 for supervisors, got %d", rec.Code)
+	}
+	if rec := doRequest(router, "GET", "/healthz", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("authMiddleware() failed. Health endpoint should be public")
+	}
+
+	rec := doRequest(router, "POST", "/api/v1.0/queue/enqueue", `{"customerName":"c1","description":"d","priorityWeight":3}`, map[string]string{"X-API-Key": "c1key"})
+	s4Struct := Selection4Struct{}
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &s4Struct) != nil {
+		t.Fatalf("authMiddleware() failed. Customer should be able to enqueue, got %d", rec.Code)
+	}
+	renegeURL := "/api/v1.0/queue/renege/" + strconv.Itoa(s4Struct.ID)
+	rec = doRequest(router, "DELETE", renegeURL, "", map[string]string{"X-API-Key": "c2key"})
+	errStruct := Selection4ErrorStruct{}
+	if rec.Code != http.StatusForbidden || json.Unmarshal(rec.Body.Bytes(), &errStruct) != nil || errStruct.Error != "FORBIDDEN" {
+		t.Errorf("api5() failed. Customers should not renege requests of others, got %d", rec.Code)
+	}
+
+	rec = doRequest(router, "POST", "/api/v1.0/queue/enqueue", `{"customerName":"c2","description":"d","priorityWeight":3}`, map[string]string{"X-API-Key": "c2key"})
+	other := Selection4Struct{}
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &other) != nil {
+		t.Fatalf("authMiddleware() failed. Customer should be able to enqueue, got %d", rec.Code)
+	}
+	if rec := doRequest(router, "DELETE", "/api/v1.0/queue/renege/"+strconv.Itoa(other.ID), "", map[string]string{"X-API-Key": "c1key"}); rec.Code != http.StatusForbidden {
+		t.Errorf("api5() failed. Customers should not renege requests of others, got %d", rec.Code)
+	}
+	if rec := doRequest(router, "DELETE", "/api/v1.0/queue/renege/"+strconv.Itoa(other.ID), "", map[string]string{"X-API-Key": "supkey"}); rec.Code != http.StatusOK {
+		t.Errorf("api5() failed. Supervisors should renege by id without the token, got %d", rec.Code)
+	}
+
+	valid := signJWT(`{"sub":"c1","role":"customer","exp":`+strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)+`}`, []byte("secret"))
+	expired := signJWT(`{"sub":"c1","role":"customer","exp":`+strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)+`}`, []byte("secret"))
+	forged := signJWT(`{"sub":"c1","role":"admin","exp":`+strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)+`}`, []byte("other"))
+	if rec := doRequest(router, "DELETE", renegeURL, "", map[string]string{"Authorization": "Bearer " + expired}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("verifyJWT() failed. Expected 401 for expired token, got %d", rec.Code)
+	}
+	if rec := doRequest(router, "DELETE", renegeURL, "", map[string]string{"Authorization": "Bearer " + forged}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("verifyJWT() failed. Expected 401 for forged token, got %d", rec.Code)
+	}
+	if rec := doRequest(router, "DELETE", renegeURL, "", map[string]string{"Authorization": "Bearer " + valid}); rec.Code != http.StatusForbidden {
+		t.Errorf("api5() failed. Customers should give the token to renege by id, got %d", rec.Code)
+	}
+	if rec := doRequest(router, "DELETE", renegeURL+"?token="+s4Struct.Token, "", map[string]string{"Authorization": "Bearer " + valid}); rec.Code != http.StatusOK {
+		t.Errorf("verifyJWT() failed. Owner should renege with a valid token, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/umerf52/ExpertFlow-Programming-Assignment/priorityqueue"
+)
+
+// Batch modes
+const (
+	ALLORNOTHING = "ALL_OR_NOTHING"
+	BESTEFFORT   = "BEST_EFFORT"
+)
+
+// This function checks the mode of a batch, BEST_EFFORT is the default
+func batchMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return BESTEFFORT, nil
+	case ALLORNOTHING, BESTEFFORT:
+		return mode, nil
+	}
+	return "", errors.New("mode must be ALL_OR_NOTHING or BEST_EFFORT")
+}
+
+// This function marks every succeeded item as rolled back, it is used when an ALL_OR_NOTHING batch fails
+func rollBack(bStruct *BatchStruct) {
+	for i := range bStruct.Results {
+		if bStruct.Results[i].Status == "SUCCEEDED" {
+			bStruct.Results[i].Status = "ROLLED_BACK"
+			bStruct.Results[i].Enqueued = nil
+			bStruct.Results[i].Reneged = nil
+		}
+	}
+	bStruct.Failed += bStruct.Succeeded
+	bStruct.Succeeded = 0
+}
+
+// batchPlan holds the requests a batch will change pq with once every item has been checked
+type batchPlan struct {
+	accepted   []*CustomerRequest // accepted holds the accepted requests in the order of the batch
+	placements []string           // placements holds where every accepted request goes: the queue if empty, MERGE, EVICT, SPILL or BUFFER
+	existing   []*CustomerRequest // existing holds the request of the same customer every accepted request is merged into or replaces
+	reserved   int                // reserved is the number of slots of the queue taken by accepted requests
+	victims    []*CustomerRequest // victims holds the waiting requests that are evicted for accepted requests
+	spilled    int                // spilled is the number of accepted requests that wait in the overflow queue or buffer
+	lowest     []*CustomerRequest // lowest holds the lowest waiting requests, the victims are taken from them in order
+	next       int                // next is the index in lowest of the next victim
+	// kept holds the requests accepted requests are merged into or replace, they are never evicted. freed and
+	// freedOverflow are the slots the replaced requests free in the queue and in the overflow queue or buffer.
+	kept                 map[*CustomerRequest]bool
+	freed, freedOverflow int
+}
+
+// This function returns the slots existing frees in the queue and in the overflow queue or buffer of pq
+// when it is replaced. pq.mutex must be held.
+func frees(pq *PriorityQueue, existing *CustomerRequest) (int, int) {
+	if _, err := pq.queue.Get(existing.ID); err == nil {
+		return 1, 0
+	}
+	if _, err := getOverflowed(pq, existing.ID); err == nil {
+		return 0, 1
+	}
+	return 0, 0
+}
+
+// This method returns if cr is evicted for an accepted request
+func (plan *batchPlan) isVictim(cr *CustomerRequest) bool {
+	for _, victim := range plan.victims {
+		if victim == cr {
+			return true
+		}
+	}
+	return false
+}
+
+// This method returns where cr goes given the requests the batch has accepted so far: MERGE if it is merged
+// into existing, the queue if the reason is empty, or EVICT, SPILL or BUFFER if the overflow policy applies
+// to it. existing is the request of the same customer or nil, victim is the index in lowest of the request
+// cr evicts. ok is false if there is no room for cr. n is the number of items of the batch. pq.mutex must be held.
+func (plan *batchPlan) place(pq *PriorityQueue, cr *CustomerRequest, existing *CustomerRequest, n int) (reason string, victim int, ok bool) {
+	if existing != nil && pq.duplicatePolicy == MERGE {
+		return MERGE, 0, true
+	}
+	freed, freedOverflow := plan.freed, plan.freedOverflow
+	if existing != nil {
+		q, o := frees(pq, existing)
+		freed, freedOverflow = freed+q, freedOverflow+o
+	}
+	if !pq.queue.IsFull(pq.offered + plan.reserved - freed) {
+		return "", 0, true
+	}
+	switch pq.overflowPolicy {
+	case EVICT:
+		if plan.lowest == nil {
+			plan.lowest = pq.queue.LowestN(2 * n) // no batch evicts and replaces more than it has items
+		}
+		victim = plan.next
+		for victim < len(plan.lowest) && (plan.kept[plan.lowest[victim]] || plan.lowest[victim] == existing) {
+			victim++
+		}
+		if victim < len(plan.lowest) && pq.queue.Less(cr, plan.lowest[victim]) {
+			return EVICT, victim, true
+		}
+	case SPILL, BUFFER:
+		if overflowRoom(pq)+freedOverflow > plan.spilled {
+			return pq.overflowPolicy, 0, true
+		}
+	}
+	return "", 0, false
+}
+
+// This method is for accepting cr, which goes where place returned
+func (plan *batchPlan) accept(pq *PriorityQueue, cr *CustomerRequest, reason string, existing *CustomerRequest, victim int) {
+	plan.accepted = append(plan.accepted, cr)
+	plan.placements = append(plan.placements, reason)
+	plan.existing = append(plan.existing, existing)
+	if existing != nil {
+		plan.kept[existing] = true
+		if reason != MERGE {
+			q, o := frees(pq, existing)
+			plan.freed, plan.freedOverflow = plan.freed+q, plan.freedOverflow+o
+		}
+	}
+	switch reason {
+	case "":
+		plan.reserved++
+	case EVICT:
+		plan.victims = append(plan.victims, plan.lowest[victim])
+		plan.next = victim + 1
+	case SPILL, BUFFER:
+		plan.spilled++
+	}
+}
+
+// This method is for undoing the quotas of the accepted requests of owner when they are not enqueued
+// after all, their rate limit tokens are given back
+func (plan *batchPlan) unaccept(pq *PriorityQueue, owner string) {
+	for _, reason := range plan.placements {
+		if reason != MERGE {
+			releaseQuota(pq, owner)
+		}
+		refundQuota(pq, owner)
+	}
+}
+
+// This method is for Enqueueing many Customer Requests of the client owner with a single lock
+// acquisition and a single heap fix-up pass. The duplicate policy applies to the items of customers who
+// have a request in pq and the overflow policy to the items that find the queue full. Requests are only
+// merged into, replaced or evicted once the batch is known to succeed. A batch may have one item per
+// customer if customers may have only one request.
+func enqueueBatch(pq *PriorityQueue, crs []*CustomerRequest, mode string, owner string) BatchStruct {
+	logger.Printf("enqueueing batch of %d in mode %s", len(crs), mode)
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	now := time.Now() // taken under the lock, so the requests and samples of pq stay in time order
+	bStruct := BatchStruct{Mode: mode, Results: make([]BatchItemResult, len(crs))}
+	plan := batchPlan{accepted: make([]*CustomerRequest, 0, len(crs)), kept: make(map[*CustomerRequest]bool)}
+	customers := make(map[string]bool)
+	for i, cr := range crs {
+		result := &bStruct.Results[i]
+		result.Index = i
+		result.Status = "FAILED"
+		var existing *CustomerRequest
+		if cr != nil {
+			existing, _ = findCustomer(pq, cr)
+		}
+		if existing != nil && plan.isVictim(existing) {
+			existing = nil // it is evicted for an earlier item, so cr takes its unique key
+		}
+		var reason string
+		var victim int
+		var ok bool
+		if cr == nil || (cr.CustomerName == "" && cr.Description == "" && cr.PriorityWeight == 0) {
+			result.Error = &Selection4ErrorStruct{Error: "INVALID_PARAMETERS", Msg: "empty customer request"}
+		} else if err := validateTiming(cr, now); err != nil {
+			result.Error = &Selection4ErrorStruct{Error: "INVALID_PARAMETERS", Msg: err.Error()}
+		} else if (existing != nil && !mayDeduplicate(pq, existing, owner)) || (isUnique(pq) && customers[customerKey(cr)]) {
+			result.Error = &Selection4ErrorStruct{Error: "DUPLICATE_CUSTOMER", Msg: errDuplicateCustomer.Error()}
+		} else if reason, victim, ok = plan.place(pq, cr, existing, len(crs)); !ok {
+			pq.rejectedCount++
+			result.Error = &Selection4ErrorStruct{Error: "MAX_CAPACITY_REACHED", Msg: errCapacityReached.Error()}
+		} else if errorCode, _ := takeQuota(pq, owner, now); errorCode != "" {
+			result.Error = &Selection4ErrorStruct{Error: errorCode, Msg: "too many requests, please try again later"}
+		} else {
+			cr.EnqueueTime = now
+			if reason != MERGE {
+				holdQuota(pq, owner)
+			}
+			customers[customerKey(cr)] = true
+			plan.accept(pq, cr, reason, existing, victim)
+			result.Status = "SUCCEEDED"
+			bStruct.Succeeded++
+			continue
+		}
+		bStruct.Failed++
+	}
+
+	if mode == ALLORNOTHING && bStruct.Failed > 0 {
+		plan.unaccept(pq, owner)
+		rollBack(&bStruct)
+		logger.Printf("rolled back batch, %d of %d failed", bStruct.Failed-len(plan.accepted), len(crs))
+		return bStruct
+	}
+
+	for i, cr := range plan.accepted {
+		if plan.placements[i] == MERGE {
+			merge(pq, plan.existing[i], cr)
+		} else if plan.existing[i] != nil {
+			replace(pq, plan.existing[i])
+		}
+	}
+	for _, victim := range plan.victims {
+		evict(pq, victim)
+	}
+	queued := make([]*CustomerRequest, 0, len(plan.accepted))
+	for i, cr := range plan.accepted {
+		if plan.placements[i] == "" || plan.placements[i] == EVICT {
+			queued = append(queued, cr)
+		}
+	}
+	if err := pq.queue.EnqueueAll(queued); err != nil {
+		// capacity and duplicates were checked for every accepted request above, this should not happen
+		logger.Printf("error enqueueing batch. %s", err.Error())
+		errorStruct := &Selection4ErrorStruct{Error: "MAX_CAPACITY_REACHED", Msg: errCapacityReached.Error()}
+		if errors.Is(err, priorityqueue.ErrExists) {
+			errorStruct = &Selection4ErrorStruct{Error: "DUPLICATE_CUSTOMER", Msg: errDuplicateCustomer.Error()}
+		}
+		plan.unaccept(pq, owner)
+		for i := range bStruct.Results {
+			if bStruct.Results[i].Status == "SUCCEEDED" {
+				bStruct.Results[i].Status = "FAILED"
+				bStruct.Results[i].Error = errorStruct
+			}
+		}
+		bStruct.Failed += bStruct.Succeeded
+		bStruct.Succeeded = 0
+		return bStruct
+	}
+	for i, cr := range plan.accepted {
+		if plan.placements[i] == SPILL || plan.placements[i] == BUFFER {
+			pq.queue.Reserve(cr)
+			hold(pq, cr)
+		}
+	}
+	for i, cr := range plan.accepted {
+		if plan.placements[i] != MERGE {
+			pq.enqueuedCount++
+			track(pq, cr, owner)
+		}
+	}
+
+	j := 0
+	for i := range bStruct.Results {
+		if bStruct.Results[i].Status != "SUCCEEDED" {
+			continue
+		}
+		cr, reason, existing := plan.accepted[j], plan.placements[j], plan.existing[j]
+		j++
+		if reason == MERGE {
+			cr = existing // the response is the request it was merged into, like for single enqueues
+		}
+		s4Struct := &Selection4Struct{ID: cr.ID,
+			PriorityWeight:  cr.PriorityWeight,
+			CustomerName:    cr.CustomerName,
+			Description:     cr.Description,
+			EnqueueTime:     cr.EnqueueTime,
+			PositionInQueue: pq.queue.Position(cr),
+			ExternalRef:     cr.ExternalRef,
+			Token:           pq.tokens[cr.ID]}
+		if existing != nil {
+			s4Struct.Deduplicated = REPLACE
+		}
+		switch {
+		case reason == MERGE:
+			s4Struct.Deduplicated = MERGE
+			if pq.queue.IsScheduled(cr) {
+				s4Struct.NotBefore = cr.NotBefore
+			} else if _, err := getOverflowed(pq, cr.ID); err == nil {
+				s4Struct.Overflow = pq.overflowPolicy
+			}
+		case reason == SPILL || reason == BUFFER:
+			s4Struct.Overflow = reason
+			recordEvent(pq, "OVERFLOWED", cr, reason)
+		case pq.queue.IsScheduled(cr):
+			s4Struct.NotBefore = cr.NotBefore
+			recordEvent(pq, "SCHEDULED", cr, "")
+		default:
+			recordEvent(pq, "ENQUEUED", cr, reason)
+		}
+		bStruct.Results[i].Enqueued = s4Struct
+	}
+	logger.Printf("enqueued batch, %d succeeded and %d failed", bStruct.Succeeded, bStruct.Failed)
+	return bStruct
+}
+
+// This method is for Reneging many Customer Requests with a single lock acquisition and a single
+// heap fix-up pass for the waiting ones. Overflowed requests are reneged and scheduled ones cancelled
+// one by one like single reneges, requests being offered to an agent are kept. mayRenege decides if
+// the caller may renege a request of the given owner.
+func renegeBatch(pq *PriorityQueue, ids []int, mode string, mayRenege func(owner string) bool) BatchStruct {
+	logger.Printf("reneging batch of %d in mode %s", len(ids), mode)
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	now := time.Now() // taken under the lock, so the samples of pq stay in time order
+	bStruct := BatchStruct{Mode: mode, Results: make([]BatchItemResult, len(ids))}
+	removed := make(map[*CustomerRequest]bool, len(ids))
+	others := make(map[int]*CustomerRequest) // others holds the requests that are not waiting by their index in ids
+	for i, id := range ids {
+		result := &bStruct.Results[i]
+		result.Index = i
+		result.Status = "FAILED"
+		cr, ok := findLive(pq, id)
+		if !ok || removed[cr] {
+			result.Error = &Selection4ErrorStruct{Error: "NOT_FOUND", Msg: "id not found"}
+		} else if !mayRenege(pq.owners[cr.ID]) {
+			result.Error = &Selection4ErrorStruct{Error: "FORBIDDEN", Msg: "customers may only renege their own requests"}
+		} else if status := statusOf(pq, cr, now).Status; status == OFFERED {
+			result.Error = &Selection4ErrorStruct{Error: "OFFERED", Msg: errOffered.Error()}
+		} else {
+			removed[cr] = true
+			if status != WAITING {
+				others[i] = cr
+			}
+			result.Status = "SUCCEEDED"
+			result.Reneged = &Selection5Struct{
+				CustomerName:  cr.CustomerName,
+				ID:            cr.ID,
+				EnqueueTime:   cr.EnqueueTime,
+				WaitTimeinSec: now.Sub(cr.EnqueueTime).Seconds(),
+				Message:       "Request reneged successfully"}
+			bStruct.Succeeded++
+			continue
+		}
+		bStruct.Failed++
+	}
+
+	if mode == ALLORNOTHING && bStruct.Failed > 0 {
+		rollBack(&bStruct)
+		return bStruct
+	}
+	if len(removed) == 0 {
+		return bStruct
+	}
+
+	ids = make([]int, 0, len(removed))
+	for cr := range removed {
+		ids = append(ids, cr.ID)
+	}
+	for _, cr := range pq.queue.RemoveAll(ids) {
+		forget(pq, cr)
+		pq.renegedCount++
+		recordEvent(pq, "ABANDONED", cr, "RENEGED")
+		recordSample(pq, now, now.Sub(cr.EnqueueTime).Seconds(), true)
+		recordCompleted(pq, cr, RENEGED, "", now)
+	}
+	for i := range bStruct.Results {
+		cr, ok := others[i]
+		if !ok {
+			continue
+		}
+		// refilling the queue may have moved an overflowed request into it, renegeRequest looks at its status again
+		s5Struct, err := renegeRequest(pq, cr, false)
+		if err != nil {
+			logger.Printf("error reneging %d in batch. %s", cr.ID, err.Error())
+			bStruct.Results[i].Status = "FAILED"
+			bStruct.Results[i].Reneged = nil
+			bStruct.Results[i].Error = &Selection4ErrorStruct{Error: "NOT_FOUND", Msg: err.Error()}
+			bStruct.Succeeded--
+			bStruct.Failed++
+			continue
+		}
+		bStruct.Results[i].Reneged = &s5Struct
+	}
+	logger.Printf("reneged batch, %d succeeded and %d failed", bStruct.Succeeded, bStruct.Failed)
+	return bStruct
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/umerf52/ExpertFlow-Programming-Assignment/priorityqueue"
+)
+
+// This function checks the heap invariant and the positions kept by the queue
+func checkHeap(t testing.TB, pq *PriorityQueue) {
+	items := pq.queue.Items()
+	for i, cr := range items {
+		if pq.queue.Position(cr) != i {
+			t.Fatalf("position of %d is %d, expected %d", cr.ID, pq.queue.Position(cr), i)
+		}
+		if i > 0 && pq.queue.Less(cr, items[(i-1)/2]) {
+			t.Fatalf("heap invariant broken at %d", i)
+		}
+	}
+}
+
+// This test checks both batch modes of enqueue and renege
+func TestBatches(t *testing.T) {
+	pq := newPriorityQueue("DefaultQueue", "", 100)
+	now := time.Now()
+	_ = insert(pq, &CustomerRequest{PriorityWeight: 5, CustomerName: "single", EnqueueTime: now}, false)
+	newBatch := func(weights ...int) []*CustomerRequest {
+		crs := make([]*CustomerRequest, 0)
+		for _, w := range weights {
+			crs = append(crs, &CustomerRequest{PriorityWeight: w, CustomerName: "name" + strconv.Itoa(w), TTLInSec: float64(w)})
+		}
+		return crs
+	}
+
+	bad := append(newBatch(3, 9), &CustomerRequest{})
+	if bStruct := enqueueBatch(pq, bad, ALLORNOTHING, "c1"); bStruct.Succeeded != 0 || bStruct.Results[0].Status != "ROLLED_BACK" || pq.queue.Len() != 1 {
+		t.Errorf("enqueueBatch() failed. ALL_OR_NOTHING batch with an invalid item should not enqueue anything")
+	}
+	bad = append(newBatch(3, 9), &CustomerRequest{})
+	bStruct := enqueueBatch(pq, bad, BESTEFFORT, "c1")
+	if bStruct.Succeeded != 2 || bStruct.Failed != 1 || pq.queue.Len() != 3 || pq.outstanding["c1"] != 2 {
+		t.Errorf("enqueueBatch() failed. BEST_EFFORT batch should enqueue the valid items")
+	}
+	checkHeap(t, pq)
+	if top, _ := pq.queue.Peek(); top.PriorityWeight != 9 {
+		t.Errorf("enqueueBatch() failed. Highest priority should be on top")
+	}
+
+	ids := []int{bStruct.Results[0].Enqueued.ID, 12345}
+	all := func(owner string) bool { return true }
+	if rStruct := renegeBatch(pq, ids, ALLORNOTHING, all); rStruct.Succeeded != 0 || pq.queue.Len() != 3 {
+		t.Errorf("renegeBatch() failed. ALL_OR_NOTHING batch with an unknown id should not renege anything")
+	}
+	if rStruct := renegeBatch(pq, ids, BESTEFFORT, all); rStruct.Succeeded != 1 || pq.queue.Len() != 2 || pq.renegedCount != 1 {
+		t.Errorf("renegeBatch() failed. BEST_EFFORT batch should renege the known id")
+	}
+	checkHeap(t, pq)
+
+	// overflowed requests are reneged and scheduled ones cancelled like single reneges
+	later := now.Add(time.Hour)
+	scheduled := &CustomerRequest{PriorityWeight: 2, CustomerName: "scheduled", EnqueueTime: now, NotBefore: &later}
+	_ = insert(pq, scheduled, false)
+	_ = setOverflowPolicy(pq, BUFFER)
+	pq.queue.SetCapacity(pq.queue.Len() + pq.queue.ScheduledLen())
+	buffered := &CustomerRequest{PriorityWeight: 2, CustomerName: "buffered", EnqueueTime: now}
+	_ = insert(pq, buffered, false)
+	if _, err := getOverflowed(pq, buffered.ID); err != nil {
+		t.Fatalf("insert() failed. Expected the request to be buffered")
+	}
+	rStruct := renegeBatch(pq, []int{scheduled.ID, buffered.ID}, ALLORNOTHING, all)
+	if rStruct.Succeeded != 2 || pq.queue.ScheduledLen() != 0 || len(pq.buffer) != 0 || pq.renegedCount != 2 {
+		t.Errorf("renegeBatch() failed. Expected the scheduled and buffered requests to be reneged, got %+v", rStruct)
+	}
+	pq.queue.SetCapacity(100)
+
+	pq.rateLimit, pq.rateBurst = 0.001, 2
+	if bStruct := enqueueBatch(pq, append(newBatch(4, 6), &CustomerRequest{}), ALLORNOTHING, "c2"); bStruct.Succeeded != 0 {
+		t.Errorf("enqueueBatch() failed. ALL_OR_NOTHING batch with an invalid item should not enqueue anything")
+	}
+	if bStruct := enqueueBatch(pq, newBatch(4, 6), ALLORNOTHING, "c2"); bStruct.Succeeded != 2 || pq.outstanding["c2"] != 2 {
+		t.Errorf("enqueueBatch() failed. Expected the tokens of the rolled back batch to be refunded, got %+v", bStruct)
+	}
+}
+
+// This test checks that the overflow policy applies to the items of a batch that find the queue full
+func TestBatchOverflow(t *testing.T) {
+	newBatch := func(weights ...int) []*CustomerRequest {
+		crs := make([]*CustomerRequest, 0)
+		for _, w := range weights {
+			crs = append(crs, &CustomerRequest{PriorityWeight: w, CustomerName: "name" + strconv.Itoa(w)})
+		}
+		return crs
+	}
+
+	pq := newPriorityQueue("DefaultQueue", "", 2)
+	_ = setOverflowPolicy(pq, EVICT)
+	enqueueBatch(pq, newBatch(1, 2), BESTEFFORT, "")
+	if bStruct := enqueueBatch(pq, newBatch(5, 1), ALLORNOTHING, ""); bStruct.Succeeded != 0 || pq.evictedCount != 0 {
+		t.Errorf("enqueueBatch() failed. A rolled back batch should not evict, got %+v", bStruct)
+	}
+	bStruct := enqueueBatch(pq, newBatch(5, 1, 7), BESTEFFORT, "")
+	if bStruct.Succeeded != 2 || bStruct.Results[1].Error == nil || bStruct.Results[1].Error.Error != "MAX_CAPACITY_REACHED" || pq.evictedCount != 2 {
+		t.Errorf("enqueueBatch() failed. Expected the items that outrank the lowest to evict them, got %+v", bStruct)
+	}
+	if items := pq.queue.Items(); len(items) != 2 || items[0].PriorityWeight != 7 || items[1].PriorityWeight != 5 {
+		t.Errorf("enqueueBatch() failed. Unexpected queue after evictions %+v", items)
+	}
+	checkHeap(t, pq)
+
+	for _, policy := range []string{SPILL, BUFFER} {
+		pq := newPriorityQueue("DefaultQueue", "", 2)
+		_ = setOverflowPolicy(pq, policy)
+		bStruct := enqueueBatch(pq, newBatch(1, 2, 3, 4), BESTEFFORT, "")
+		if bStruct.Succeeded != 4 || pq.queue.Len() != 2 || overflowRoom(pq) != 98 {
+			t.Errorf("enqueueBatch() failed with %s. Expected the items that do not fit to overflow, got %+v", policy, bStruct)
+		}
+		if s4Struct := bStruct.Results[3].Enqueued; s4Struct == nil || s4Struct.Overflow != policy || s4Struct.PositionInQueue != -1 {
+			t.Errorf("enqueueBatch() failed with %s. Unexpected result of an overflowed item %+v", policy, s4Struct)
+		}
+		if _, err := getOverflowed(pq, bStruct.Results[3].Enqueued.ID); err != nil {
+			t.Errorf("enqueueBatch() failed with %s. Overflowed item not found", policy)
+		}
+	}
+}
+
+func benchmarkPQ() {
+	PQ = PriorityQueue{queue: priorityqueue.New(priorityqueue.WithName("DefaultQueue"), priorityqueue.WithCapacity(SIZE))}
+}
+
+// BenchmarkEnqueueLoop enqueues 10k requests one API call at a time
+func BenchmarkEnqueueLoop(b *testing.B) {
+	router := newRouter()
+	for n := 0; n < b.N; n++ {
+		benchmarkPQ()
+		for i := 0; i < 10000; i++ {
+			doRequest(router, "POST", "/api/v1.0/queue/enqueue", `{"customerName":"name","description":"desc","priorityWeight":`+strconv.Itoa(i%10+1)+`}`, nil)
+		}
+	}
+}
+
+// BenchmarkEnqueueBatch enqueues 10k requests with one batch API call
+func BenchmarkEnqueueBatch(b *testing.B) {
+	router := newRouter()
+	crs := make([]CustomerRequest, 10000)
+	for i := range crs {
+		crs[i] = CustomerRequest{CustomerName: "name", Description: "desc", PriorityWeight: i%10 + 1}
+	}
+	body, _ := json.Marshal(map[string]interface{}{"mode": BESTEFFORT, "customerRequests": crs})
+	for n := 0; n < b.N; n++ {
+		benchmarkPQ()
+		doRequest(router, "POST", "/api/v1.0/queue/enqueue:batch", string(body), nil)
+	}
+}
+
+// BenchmarkRenegeLoop reneges 10k of 50k requests one API call at a time
+func BenchmarkRenegeLoop(b *testing.B) {
+	router := newRouter()
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		fillBenchmarkPQ()
+		urls := make([]string, 10000)
+		for i := range urls {
+			urls[i] = "/api/v1.0/queue/renege/" + strconv.Itoa(i*5) + "?token=" + PQ.tokens[i*5]
+		}
+		b.StartTimer()
+		for _, url := range urls {
+			doRequest(router, "DELETE", url, "", nil)
+		}
+	}
+}
+
+// BenchmarkRenegeBatch reneges 10k of 50k requests with one batch API call
+func BenchmarkRenegeBatch(b *testing.B) {
+	router := newRouter()
+	ids := make([]string, 10000)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i * 5)
+	}
+	body := `{"mode":"BEST_EFFORT","ids":[` + strings.Join(ids, ",") + `]}`
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		fillBenchmarkPQ()
+		b.StartTimer()
+		doRequest(router, "POST", "/api/v1.0/queue/renege:batch", body, nil)
+	}
+}
+
+func fillBenchmarkPQ() {
+	benchmarkPQ()
+	crs := make([]*CustomerRequest, SIZE)
+	for i := range crs {
+		crs[i] = &CustomerRequest{CustomerName: "name", Description: "desc", PriorityWeight: i%10 + 1}
+	}
+	enqueueBatch(&PQ, crs, BESTEFFORT, "")
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CAPACITYFILE is the file in the log directory the capacity of the queue is persisted to
+const CAPACITYFILE = "capacity.json"
+
+var errCapacityRefused = errors.New("the queue holds more requests than the new capacity and the overflow policy cannot make room")
+
+// This method is for resizing the queue at runtime. Lowering the capacity below the requests in the queue
+// evicts or overflows the lowest priority waiting requests as the overflow policy says, the REJECT policy
+// refuses it. The new capacity is persisted and loaded on the next start.
+func setCapacity(pq *PriorityQueue, capacity int, isConsole bool) (CapacityJSON, error) {
+	logger.Printf("setting capacity to %d, isConsole: %t", capacity, isConsole)
+	if capacity < 1 {
+		err := errors.New("capacity must be at least 1")
+		if isConsole {
+			fmt.Println(err)
+		}
+		return CapacityJSON{}, err
+	}
+	pq.mutex.Lock()
+	if err := shrink(pq, occupancy(pq)-capacity); err != nil {
+		pq.mutex.Unlock()
+		logger.Printf("error setting capacity. %s", err.Error())
+		if isConsole {
+			fmt.Println(err)
+		}
+		return CapacityJSON{}, err
+	}
+	pq.queue.SetCapacity(capacity)
+	refill(pq)
+	pq.mutex.Unlock()
+
+	result := CapacityJSON{Capacity: capacity}
+	if err := saveJSON(CAPACITYFILE, result); err != nil {
+		logger.Printf("error saving capacity. %s", err.Error())
+	}
+	return result, nil
+}
+
+// This function makes room for excess requests by evicting or overflowing the lowest priority waiting
+// requests. Nothing is changed if the overflow policy cannot make enough room. pq.mutex must be held.
+func shrink(pq *PriorityQueue, excess int) error {
+	if excess <= 0 {
+		return nil
+	}
+	switch pq.overflowPolicy {
+	case EVICT:
+	case SPILL, BUFFER:
+		if overflowRoom(pq) < excess {
+			return errCapacityRefused
+		}
+	default:
+		return errCapacityRefused
+	}
+	// Scheduled and offered requests keep their slots, only waiting requests can make room
+	if pq.queue.Len() < excess {
+		return errCapacityRefused
+	}
+	for _, lowest := range pq.queue.LowestN(excess) {
+		if pq.overflowPolicy == EVICT {
+			evict(pq, lowest)
+			continue
+		}
+		if _, err := pq.queue.Remove(lowest.ID); err != nil {
+			return err
+		}
+		hold(pq, lowest)
+		recordEvent(pq, "OVERFLOWED", lowest, pq.overflowPolicy)
+	}
+	return nil
+}
+
+// This function loads the capacity persisted by a previous run
+func loadCapacity(pq *PriorityQueue) error {
+	result := CapacityJSON{}
+	if err := loadJSON(CAPACITYFILE, &result); err != nil || result.Capacity < 1 {
+		return err
+	}
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	pq.queue.SetCapacity(result.Capacity)
+	logger.Printf("loaded capacity %d", result.Capacity)
+	return nil
+}
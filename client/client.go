@@ -0,0 +1,451 @@
+// Package client is a Go client of the priority queue API.
+//
+//	c := client.New("http://localhost:10000", client.WithAPIKey("key"))
+//	enqueued, err := c.Enqueue(ctx, client.CustomerRequest{CustomerName: "name", PriorityWeight: 5})
+//	if errors.Is(err, client.ErrCapacityReached) {
+//		...
+//	}
+//
+// Requests answered with 503 Service Unavailable are retried with exponential backoff.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Client calls the API at baseURL, it is safe for concurrent use
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	apiKey     string
+	token      string
+	retries    int           // retries is the number of retries of a request answered with 503
+	backoff    time.Duration // backoff is the delay before the first retry, it doubles with every retry
+	maxBackoff time.Duration
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithAPIKey authenticates every request with the X-API-Key header
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithBearerToken authenticates every request with a JWT bearer token
+func WithBearerToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithHTTPClient sets the http.Client used for requests, http.DefaultClient is used otherwise
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithRetries sets how often a request answered with 503 is retried and the delay before the first retry.
+// A Retry-After header of the server takes precedence over the backoff.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// New returns a Client of the API at baseURL, e.g. http://localhost:10000
+func New(baseURL string, options ...Option) *Client {
+	c := &Client{
+		baseURL:    baseURL,
+		httpClient: http.DefaultClient,
+		retries:    3,
+		backoff:    100 * time.Millisecond,
+		maxBackoff: 5 * time.Second}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// List returns the IDs of the customer requests in the queue
+func (c *Client) List(ctx context.Context) (Selection1Struct, error) {
+	s1Struct := Selection1Struct{}
+	err := c.do(ctx, "GET", "/api/v1.0/queue/list", nil, nil, &s1Struct)
+	return s1Struct, err
+}
+
+// Detail returns the customer requests in the queue
+func (c *Client) Detail(ctx context.Context) (Selection2Struct, error) {
+	s2Struct := Selection2Struct{}
+	err := c.do(ctx, "GET", "/api/v1.0/queue/detail", nil, nil, &s2Struct)
+	return s2Struct, err
+}
+
+// Service dequeues the customer request with highest priority.
+// ErrNothingToService is returned if the queue is empty.
+func (c *Client) Service(ctx context.Context) (Selection3Struct, error) {
+	return c.ServiceForAgent(ctx, "")
+}
+
+// ServiceForAgent dequeues the customer request with highest priority the agent can handle,
+// the agent becomes BUSY. ErrNothingToService is returned if no request matches.
+func (c *Client) ServiceForAgent(ctx context.Context, agentID string) (Selection3Struct, error) {
+	path := "/api/v1.0/queue/service"
+	if agentID != "" {
+		path += "?agentId=" + url.QueryEscape(agentID)
+	}
+	s3Struct := Selection3Struct{}
+	err := c.do(ctx, "GET", path, nil, nil, &s3Struct)
+	if e, ok := err.(*Error); ok && e.StatusCode == http.StatusOK {
+		e.Code = "NOTHING_TO_SERVICE"
+	}
+	return s3Struct, err
+}
+
+// Enqueue enqueues cr
+func (c *Client) Enqueue(ctx context.Context, cr CustomerRequest) (Selection4Struct, error) {
+	return c.EnqueueIdempotent(ctx, cr, "")
+}
+
+// EnqueueIdempotent enqueues cr, retries with the same key return the original response
+// instead of enqueueing the customer twice
+func (c *Client) EnqueueIdempotent(ctx context.Context, cr CustomerRequest, key string) (Selection4Struct, error) {
+	var headers map[string]string
+	if key != "" {
+		headers = map[string]string{"Idempotency-Key": key}
+	}
+	s4Struct := Selection4Struct{}
+	err := c.do(ctx, "POST", "/api/v1.0/queue/enqueue", headers, cr, &s4Struct)
+	return s4Struct, err
+}
+
+// Renege removes the customer request with id from the queue, token is the one returned by Enqueue and
+// may only be left empty by supervisors and admins
+func (c *Client) Renege(ctx context.Context, id int, token string) (Selection5Struct, error) {
+	s5Struct := Selection5Struct{}
+	path := "/api/v1.0/queue/renege/" + strconv.Itoa(id)
+	if token != "" {
+		path += "?token=" + url.QueryEscape(token)
+	}
+	err := c.do(ctx, "DELETE", path, nil, nil, &s5Struct)
+	return s5Struct, err
+}
+
+// GetRequest returns the status of the customer request with token, which is returned by Enqueue
+func (c *Client) GetRequest(ctx context.Context, token string) (RequestStatusStruct, error) {
+	rsStruct := RequestStatusStruct{}
+	err := c.do(ctx, "GET", "/api/v1.0/queue/requests/"+url.PathEscape(token), nil, nil, &rsStruct)
+	return rsStruct, err
+}
+
+// RenegeRequest removes the customer request with token, ErrConflict is returned while it is offered to an agent
+func (c *Client) RenegeRequest(ctx context.Context, token string) (Selection5Struct, error) {
+	s5Struct := Selection5Struct{}
+	err := c.do(ctx, "DELETE", "/api/v1.0/queue/requests/"+url.PathEscape(token), nil, nil, &s5Struct)
+	return s5Struct, err
+}
+
+// CustomerRequests returns the status of the customer requests of customerName enqueued by the client
+func (c *Client) CustomerRequests(ctx context.Context, customerName string) (CustomerRequestsStruct, error) {
+	crStruct := CustomerRequestsStruct{}
+	err := c.do(ctx, "GET", "/api/v1.0/queue/customers/"+url.PathEscape(customerName), nil, nil, &crStruct)
+	return crStruct, err
+}
+
+// RenegeCustomer removes the customer requests of customerName enqueued by the client
+func (c *Client) RenegeCustomer(ctx context.Context, customerName string) ([]Selection5Struct, error) {
+	reneged := make([]Selection5Struct, 0)
+	err := c.do(ctx, "DELETE", "/api/v1.0/queue/customers/"+url.PathEscape(customerName), nil, nil, &reneged)
+	return reneged, err
+}
+
+// Request returns the lifecycle status of the customer request with id, also once it was serviced or abandoned
+func (c *Client) Request(ctx context.Context, id int) (RequestStatusStruct, error) {
+	rsStruct := RequestStatusStruct{}
+	err := c.do(ctx, "GET", "/api/v1.0/requests/"+strconv.Itoa(id), nil, nil, &rsStruct)
+	return rsStruct, err
+}
+
+// EnqueueBatch enqueues crs, mode is ALLORNOTHING or BESTEFFORT
+func (c *Client) EnqueueBatch(ctx context.Context, mode string, crs []CustomerRequest) (BatchStruct, error) {
+	body := struct {
+		Mode             string            `json:"mode"`
+		CustomerRequests []CustomerRequest `json:"customerRequests"`
+	}{mode, crs}
+	batch := BatchStruct{}
+	err := c.do(ctx, "POST", "/api/v1.0/queue/enqueue:batch", nil, body, &batch)
+	return batch, err
+}
+
+// RenegeBatch removes the customer requests with ids, mode is ALLORNOTHING or BESTEFFORT
+func (c *Client) RenegeBatch(ctx context.Context, mode string, ids []int) (BatchStruct, error) {
+	body := struct {
+		Mode string `json:"mode"`
+		IDs  []int  `json:"ids"`
+	}{mode, ids}
+	batch := BatchStruct{}
+	err := c.do(ctx, "POST", "/api/v1.0/queue/renege:batch", nil, body, &batch)
+	return batch, err
+}
+
+// ChangePriority changes the priority weight of the customer request with id
+func (c *Client) ChangePriority(ctx context.Context, id int, priorityWeight int) (CustomerRequest, error) {
+	body := struct {
+		PriorityWeight int `json:"priorityWeight"`
+	}{priorityWeight}
+	cr := CustomerRequest{}
+	err := c.do(ctx, "PUT", "/api/v1.0/queue/"+strconv.Itoa(id)+"/priority", nil, body, &cr)
+	return cr, err
+}
+
+// SetOrdering switches the ordering of the queue to PRIORITY, EDF, AGING, WEIGHTED_FAIR or FAIR_SHARE
+func (c *Client) SetOrdering(ctx context.Context, ordering string) (string, error) {
+	body := struct {
+		Ordering string `json:"ordering"`
+	}{ordering}
+	err := c.do(ctx, "PUT", "/api/v1.0/queue/ordering", nil, body, &body)
+	return body.Ordering, err
+}
+
+// SetCapacity resizes the queue, ErrConflict is returned if the queue holds more requests than
+// capacity and the overflow policy cannot make room
+func (c *Client) SetCapacity(ctx context.Context, capacity int) (int, error) {
+	body := struct {
+		Capacity int `json:"capacity"`
+	}{capacity}
+	err := c.do(ctx, "PUT", "/api/v1.0/queue/capacity", nil, body, &body)
+	return body.Capacity, err
+}
+
+// SetQuotas sets the rate limit and the outstanding requests quota of clients, zero turns a quota off.
+// The quotas in effect are returned.
+func (c *Client) SetQuotas(ctx context.Context, quotas QuotasJSON) (QuotasJSON, error) {
+	result := QuotasJSON{}
+	err := c.do(ctx, "PUT", "/api/v1.0/queue/quotas", nil, quotas, &result)
+	return result, err
+}
+
+// ListScheduled returns the customer requests that are not due yet
+func (c *Client) ListScheduled(ctx context.Context) (ScheduledStruct, error) {
+	scheduled := ScheduledStruct{}
+	err := c.do(ctx, "GET", "/api/v1.0/queue/scheduled", nil, nil, &scheduled)
+	return scheduled, err
+}
+
+// CancelScheduled removes the scheduled customer request with id
+func (c *Client) CancelScheduled(ctx context.Context, id int) (Selection5Struct, error) {
+	s5Struct := Selection5Struct{}
+	err := c.do(ctx, "DELETE", "/api/v1.0/queue/scheduled/"+strconv.Itoa(id), nil, nil, &s5Struct)
+	return s5Struct, err
+}
+
+// SystemInfo returns the status of the queue and the agents
+func (c *Client) SystemInfo(ctx context.Context) (Selection6Struct, error) {
+	s6Struct := Selection6Struct{}
+	err := c.do(ctx, "GET", "/api/v1.0/SystemInfo", nil, nil, &s6Struct)
+	return s6Struct, err
+}
+
+// Stats returns the service level metrics, a threshold of 0 uses the threshold of the server
+func (c *Client) Stats(ctx context.Context, threshold time.Duration) (StatsStruct, error) {
+	path := "/api/v1.0/stats"
+	if threshold > 0 {
+		path += "?threshold=" + strconv.FormatFloat(threshold.Seconds(), 'f', -1, 64)
+	}
+	stats := StatsStruct{}
+	err := c.do(ctx, "GET", path, nil, nil, &stats)
+	return stats, err
+}
+
+// Report returns the completed requests aggregated by day or week bucket and by groupBy, priorityWeight
+// or queue. Zero times and empty strings use the defaults of the server.
+func (c *Client) Report(ctx context.Context, from time.Time, to time.Time, bucket string, groupBy string) (ReportStruct, error) {
+	query := url.Values{}
+	if !from.IsZero() {
+		query.Set("from", from.Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		query.Set("to", to.Format(time.RFC3339))
+	}
+	if bucket != "" {
+		query.Set("bucket", bucket)
+	}
+	if groupBy != "" {
+		query.Set("groupBy", groupBy)
+	}
+	path := "/api/v1.0/reports"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	report := ReportStruct{}
+	err := c.do(ctx, "GET", path, nil, nil, &report)
+	return report, err
+}
+
+// Events returns the recent lifecycle events of the queue
+func (c *Client) Events(ctx context.Context) (EventsStruct, error) {
+	events := EventsStruct{}
+	err := c.do(ctx, "GET", "/api/v1.0/events", nil, nil, &events)
+	return events, err
+}
+
+// ListAgents returns the registered agents
+func (c *Client) ListAgents(ctx context.Context) ([]Agent, error) {
+	agents := make([]Agent, 0)
+	err := c.do(ctx, "GET", "/api/v1.0/agents", nil, nil, &agents)
+	return agents, err
+}
+
+// RegisterAgent registers agent, ErrConflict is returned if the ID is taken
+func (c *Client) RegisterAgent(ctx context.Context, agent Agent) (Agent, error) {
+	registered := Agent{}
+	err := c.do(ctx, "POST", "/api/v1.0/agents", nil, agent, &registered)
+	return registered, err
+}
+
+// GetAgent returns the agent with agentID
+func (c *Client) GetAgent(ctx context.Context, agentID string) (Agent, error) {
+	agent := Agent{}
+	err := c.do(ctx, "GET", "/api/v1.0/agents/"+url.PathEscape(agentID), nil, nil, &agent)
+	return agent, err
+}
+
+// UnregisterAgent unregisters the agent with agentID
+func (c *Client) UnregisterAgent(ctx context.Context, agentID string) (Agent, error) {
+	agent := Agent{}
+	err := c.do(ctx, "DELETE", "/api/v1.0/agents/"+url.PathEscape(agentID), nil, nil, &agent)
+	return agent, err
+}
+
+// SetAgentState changes the presence state of the agent with agentID
+func (c *Client) SetAgentState(ctx context.Context, agentID string, state string) (Agent, error) {
+	body := struct {
+		State string `json:"state"`
+	}{state}
+	agent := Agent{}
+	err := c.do(ctx, "PUT", "/api/v1.0/agents/"+url.PathEscape(agentID)+"/state", nil, body, &agent)
+	return agent, err
+}
+
+// GetOffer returns the pending offer of the agent with agentID
+func (c *Client) GetOffer(ctx context.Context, agentID string) (Offer, error) {
+	offer := Offer{}
+	err := c.do(ctx, "GET", "/api/v1.0/agents/"+url.PathEscape(agentID)+"/offer", nil, nil, &offer)
+	return offer, err
+}
+
+// AcceptOffer accepts the offer with offerID, ErrConflict is returned if the agent is no longer AVAILABLE
+// and the request is offered again
+func (c *Client) AcceptOffer(ctx context.Context, offerID int) (OfferResponseStruct, error) {
+	response := OfferResponseStruct{}
+	err := c.do(ctx, "POST", "/api/v1.0/offers/"+strconv.Itoa(offerID)+"/accept", nil, nil, &response)
+	return response, err
+}
+
+// RejectOffer rejects the offer with offerID, the request is offered to another agent
+func (c *Client) RejectOffer(ctx context.Context, offerID int) (OfferResponseStruct, error) {
+	response := OfferResponseStruct{}
+	err := c.do(ctx, "POST", "/api/v1.0/offers/"+strconv.Itoa(offerID)+"/reject", nil, nil, &response)
+	return response, err
+}
+
+// This method sends a request and decodes the response into out. Requests answered with 503
+// are retried until c.retries is exhausted or ctx is done.
+func (c *Client) do(ctx context.Context, method string, path string, headers map[string]string, in interface{}, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		if in != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if c.apiKey != "" {
+			req.Header.Set("X-API-Key", c.apiKey)
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		apiErr := errorOf(resp, data)
+		if apiErr == nil {
+			return json.Unmarshal(data, out)
+		}
+		if resp.StatusCode != http.StatusServiceUnavailable || attempt >= c.retries {
+			return apiErr
+		}
+
+		delay := backoff
+		if apiErr.RetryAfter > 0 {
+			delay = apiErr.RetryAfter
+		}
+		if delay > c.maxBackoff {
+			delay = c.maxBackoff
+		}
+		backoff *= 2
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// This function returns the error of a response or nil if it succeeded. Some endpoints answer
+// errors with 200, e.g. service if the queue is empty, so bodies of only an error are errors too.
+func errorOf(resp *http.Response, data []byte) *Error {
+	fields := make(map[string]json.RawMessage)
+	json.Unmarshal(data, &fields)
+	errorStruct := struct {
+		Error string `json:"error"`
+		Msg   string `json:"message"`
+	}{}
+	json.Unmarshal(data, &errorStruct)
+
+	_, hasMessage := fields["message"]
+	_, hasError := fields["error"]
+	isErrorBody := hasMessage && (len(fields) == 1 || len(fields) == 2 && hasError)
+	if resp.StatusCode < 300 && !isErrorBody {
+		return nil
+	}
+
+	apiErr := &Error{StatusCode: resp.StatusCode, Code: errorStruct.Error, Message: errorStruct.Msg}
+	if apiErr.Code == "" {
+		apiErr.Code = codeOf(resp.StatusCode)
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Errors to compare an *Error with errors.Is
+var (
+	ErrUnauthorized      = errors.New("unauthorized")
+	ErrForbidden         = errors.New("forbidden")
+	ErrNotFound          = errors.New("not found")
+	ErrConflict          = errors.New("conflict")
+	ErrInvalidParameters = errors.New("invalid parameters")
+	ErrCapacityReached   = errors.New("max capacity reached")
+	ErrRateLimited       = errors.New("rate limited")
+	ErrQuotaExceeded     = errors.New("quota exceeded")
+	ErrIdempotencyReused = errors.New("idempotency key reused")
+	// ErrNothingToService is returned by Service and ServiceForAgent if there is no request to service
+	ErrNothingToService = errors.New("nothing to service")
+)
+
+// Error is an error response of the API
+type Error struct {
+	StatusCode int
+	// Code is the error code of the server, e.g. MAX_CAPACITY_REACHED. Responses that only
+	// have a message get a code from their status.
+	Code       string
+	Message    string
+	RetryAfter time.Duration // RetryAfter is set from the Retry-After header
+}
+
+func (e *Error) Error() string {
+	return strconv.Itoa(e.StatusCode) + " " + e.Code + ": " + e.Message
+}
+
+// Is maps the error to the errors of this package
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrInvalidParameters:
+		return e.Code == "INVALID_PARAMETERS" || e.Code == "INVALID_AGENT" && e.StatusCode == http.StatusBadRequest
+	case ErrCapacityReached:
+		return e.Code == "MAX_CAPACITY_REACHED"
+	case ErrRateLimited:
+		return e.Code == "RATE_LIMITED"
+	case ErrQuotaExceeded:
+		return e.Code == "QUOTA_EXCEEDED"
+	case ErrIdempotencyReused:
+		return e.Code == "IDEMPOTENCY_KEY_REUSED"
+	case ErrNothingToService:
+		return e.Code == "NOTHING_TO_SERVICE"
+	}
+	return false
+}
+
+// This function returns the code of a response that only has a message
+func codeOf(statusCode int) string {
+	switch statusCode {
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusConflict:
+		return "CONFLICT"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	}
+	return "ERROR"
+}
@@ -0,0 +1,273 @@
+package client
+
+import "time"
+
+// The types of this file mirror the JSON of the API and keep the names of the server structs,
+// which are also the names of the schemas in /api/openapi.json.
+
+// CustomerRequest is a request of a customer in the queue
+type CustomerRequest struct {
+	ID             int            `json:"id"`
+	CustomerName   string         `json:"customerName"`
+	Description    string         `json:"description"`
+	PriorityWeight int            `json:"priorityWeight"`
+	EnqueueTime    time.Time      `json:"enqueueTime"`
+	TTLInSec       float64        `json:"ttlInSec,omitempty"`
+	Deadline       *time.Time     `json:"deadline,omitempty"`
+	NotBefore      *time.Time     `json:"notBefore,omitempty"`
+	RequiredSkills map[string]int `json:"requiredSkills,omitempty"`
+	ExternalRef    string         `json:"externalRef,omitempty"`
+	Account        string         `json:"account,omitempty"`
+}
+
+// IDJSON is used in Selection1Struct
+type IDJSON struct {
+	ID int `json:"id"`
+}
+
+// Selection1Struct is the response of List
+type Selection1Struct struct {
+	QueueName        string   `json:"queueName"`
+	QueueDescription string   `json:"queueDescription"`
+	Size             int      `json:"size"`
+	OldestTaskID     int      `json:"oldestTaskId"`
+	CustomerRequests []IDJSON `json:"customerRequests"`
+}
+
+// Selection2Struct is the response of Detail
+type Selection2Struct struct {
+	QueueName        string             `json:"queueName"`
+	QueueDescription string             `json:"queueDescription"`
+	Size             int                `json:"size"`
+	OldestTaskID     int                `json:"oldestTaskId"`
+	CustomerRequests []*CustomerRequest `json:"customerRequests"`
+}
+
+// Selection3Struct is the response of Service
+type Selection3Struct struct {
+	ID             int            `json:"id"`
+	CustomerName   string         `json:"customerName"`
+	Description    string         `json:"description"`
+	PriorityWeight int            `json:"priorityWeight"`
+	EnqueueTime    time.Time      `json:"enqueueTime"`
+	WaitTimeinSec  float64        `json:"waitTimeinSec"`
+	RequiredSkills map[string]int `json:"requiredSkills,omitempty"`
+	AgentID        string         `json:"agentId,omitempty"`
+}
+
+// Selection4Struct is the response of Enqueue, PositionInQueue is -1 for scheduled and overflowed requests
+type Selection4Struct struct {
+	CustomerName    string     `json:"customerName"`
+	Description     string     `json:"description"`
+	PriorityWeight  int        `json:"priorityWeight"`
+	ID              int        `json:"id"`
+	EnqueueTime     time.Time  `json:"enqueueTime"`
+	PositionInQueue int        `json:"positionInQueue"`
+	ExternalRef     string     `json:"externalRef,omitempty"`
+	NotBefore       *time.Time `json:"notBefore,omitempty"`
+	Overflow        string     `json:"overflow,omitempty"`
+	Deduplicated    string     `json:"deduplicated,omitempty"`
+	Token           string     `json:"token,omitempty"`
+}
+
+// Selection5Struct is the response of Renege and CancelScheduled
+type Selection5Struct struct {
+	CustomerName  string    `json:"customerName"`
+	ID            int       `json:"id"`
+	EnqueueTime   time.Time `json:"enqueueTime"`
+	WaitTimeinSec float64   `json:"waitTimeinSec"`
+	Message       string    `json:"message"`
+}
+
+// RequestStatusStruct is the response of GetRequest and Request
+type RequestStatusStruct struct {
+	ID              int        `json:"id"`
+	CustomerName    string     `json:"customerName"`
+	PriorityWeight  int        `json:"priorityWeight"`
+	Status          string     `json:"status"`
+	PositionInQueue int        `json:"positionInQueue"`
+	EnqueueTime     time.Time  `json:"enqueueTime"`
+	WaitTimeinSec   float64    `json:"waitTimeinSec"`
+	CompletedAt     *time.Time `json:"completedAt,omitempty"`
+	AgentID         string     `json:"agentId,omitempty"`
+}
+
+// CustomerRequestsStruct is the response of CustomerRequests
+type CustomerRequestsStruct struct {
+	CustomerName string                `json:"customerName"`
+	Requests     []RequestStatusStruct `json:"requests"`
+}
+
+// QueueInfo is used in Selection6Struct
+type QueueInfo struct {
+	Name                           string  `json:"name"`
+	Ordering                       string  `json:"ordering"`
+	Size                           string  `json:"size"`
+	OldestCustomerRequestTimeInSec float64 `json:"oldestCustomerRequestTimeInSec"`
+	RenegedCount                   int     `json:"renegedCount"`
+	ExpiredCount                   int     `json:"expiredCount"`
+	AbandonedCount                 int     `json:"abandonedCount"`
+	ScheduledCount                 int     `json:"scheduledCount"`
+	OfferedCount                   int     `json:"offeredCount"`
+	OverflowPolicy                 string  `json:"overflowPolicy"`
+	EvictedCount                   int     `json:"evictedCount"`
+	SpilledCount                   int     `json:"spilledCount"`
+	BufferedCount                  int     `json:"bufferedCount"`
+}
+
+// AgentsInfo is used in Selection6Struct
+type AgentsInfo struct {
+	Available int `json:"available"`
+	Busy      int `json:"busy"`
+	WrapUp    int `json:"wrapUp"`
+	Away      int `json:"away"`
+}
+
+// Selection6Struct is the response of SystemInfo
+type Selection6Struct struct {
+	Status string        `json:"status"`
+	Queue  QueueInfo     `json:"queue"`
+	Agents AgentsInfo    `json:"agents"`
+	Stats  []WindowStats `json:"stats"`
+}
+
+// WindowStats holds the service level metrics of a sliding window
+type WindowStats struct {
+	Window                    string  `json:"window"`
+	ServicedCount             int     `json:"servicedCount"`
+	AbandonedCount            int     `json:"abandonedCount"`
+	ServiceLevelPercent       float64 `json:"serviceLevelPercent"`
+	AverageSpeedOfAnswerInSec float64 `json:"averageSpeedOfAnswerInSec"`
+	WaitP50InSec              float64 `json:"waitP50InSec"`
+	WaitP90InSec              float64 `json:"waitP90InSec"`
+	WaitP99InSec              float64 `json:"waitP99InSec"`
+	AbandonmentRatePercent    float64 `json:"abandonmentRatePercent"`
+	ThroughputPerMin          float64 `json:"throughputPerMin"`
+}
+
+// StatsStruct is the response of Stats
+type StatsStruct struct {
+	QueueName                  string        `json:"queueName"`
+	ServiceLevelThresholdInSec float64       `json:"serviceLevelThresholdInSec"`
+	Windows                    []WindowStats `json:"windows"`
+}
+
+// ReportRow holds the completed requests of a time bucket, and of a group if the report is grouped
+type ReportRow struct {
+	BucketStart            time.Time `json:"bucketStart"`
+	Group                  string    `json:"group,omitempty"`
+	VolumeCount            int       `json:"volumeCount"`
+	ServicedCount          int       `json:"servicedCount"`
+	AbandonedCount         int       `json:"abandonedCount"`
+	AbandonmentRatePercent float64   `json:"abandonmentRatePercent"`
+	AverageWaitInSec       float64   `json:"averageWaitInSec"`
+	WaitP90InSec           float64   `json:"waitP90InSec"`
+}
+
+// ReportStruct is the response of Report
+type ReportStruct struct {
+	From    time.Time   `json:"from"`
+	To      time.Time   `json:"to"`
+	Bucket  string      `json:"bucket"`
+	GroupBy string      `json:"groupBy,omitempty"`
+	Rows    []ReportRow `json:"rows"`
+}
+
+// QuotasJSON holds the enqueue quotas of clients, see SetQuotas
+type QuotasJSON struct {
+	RateLimit      float64 `json:"rateLimit"`
+	RateBurst      float64 `json:"rateBurst"`
+	MaxOutstanding int     `json:"maxOutstanding"`
+}
+
+// ScheduledStruct is the response of ListScheduled
+type ScheduledStruct struct {
+	QueueName        string             `json:"queueName"`
+	Size             int                `json:"size"`
+	CustomerRequests []*CustomerRequest `json:"customerRequests"`
+}
+
+// Event is a change in the lifecycle of a CustomerRequest
+type Event struct {
+	Type         string    `json:"type"`
+	ID           int       `json:"id"`
+	CustomerName string    `json:"customerName"`
+	Reason       string    `json:"reason,omitempty"`
+	Time         time.Time `json:"time"`
+}
+
+// EventsStruct is the response of Events
+type EventsStruct struct {
+	QueueName string  `json:"queueName"`
+	Events    []Event `json:"events"`
+}
+
+// Agent is someone who services CustomerRequests
+type Agent struct {
+	ID         string         `json:"id"`
+	Name       string         `json:"name"`
+	Skills     map[string]int `json:"skills"`
+	State      string         `json:"state"`
+	StateSince time.Time      `json:"stateSince"`
+	Assignment *Assignment    `json:"assignment,omitempty"`
+}
+
+// Assignment is the CustomerRequest an Agent is currently working on
+type Assignment struct {
+	RequestID    int       `json:"requestId"`
+	CustomerName string    `json:"customerName"`
+	AssignedAt   time.Time `json:"assignedAt"`
+}
+
+// Offer is a CustomerRequest offered to an Agent
+type Offer struct {
+	ID              int              `json:"id"`
+	AgentID         string           `json:"agentId"`
+	OfferedAt       time.Time        `json:"offeredAt"`
+	ExpiresAt       time.Time        `json:"expiresAt"`
+	CustomerRequest *CustomerRequest `json:"customerRequest"`
+}
+
+// OfferResponseStruct is the response of AcceptOffer and RejectOffer
+type OfferResponseStruct struct {
+	OfferID  int              `json:"offerId"`
+	Accepted bool             `json:"accepted"`
+	Request  Selection3Struct `json:"request"`
+}
+
+// BatchItemResult is the result of a single item of a batch, Enqueued or Reneged is set on success
+type BatchItemResult struct {
+	Index    int                    `json:"index"`
+	Status   string                 `json:"status"`
+	Enqueued *Selection4Struct      `json:"enqueued,omitempty"`
+	Reneged  *Selection5Struct      `json:"reneged,omitempty"`
+	Error    *Selection4ErrorStruct `json:"error,omitempty"`
+}
+
+// BatchStruct is the response of EnqueueBatch and RenegeBatch
+type BatchStruct struct {
+	Mode      string            `json:"mode"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []BatchItemResult `json:"results"`
+}
+
+// Selection4ErrorStruct is the error of a batch item
+type Selection4ErrorStruct struct {
+	Error string `json:"error"`
+	Msg   string `json:"message"`
+}
+
+// Modes of EnqueueBatch and RenegeBatch
+const (
+	ALLORNOTHING = "ALL_OR_NOTHING"
+	BESTEFFORT   = "BEST_EFFORT"
+)
+
+// Presence states of an Agent
+const (
+	AVAILABLE = "AVAILABLE"
+	BUSY      = "BUSY"
+	WRAPUP    = "WRAP_UP"
+	AWAY      = "AWAY"
+)
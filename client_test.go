@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/umerf52/ExpertFlow-Programming-Assignment/client"
+	"github.com/umerf52/ExpertFlow-Programming-Assignment/priorityqueue"
+)
+
+// This test checks that the types of the client have the same JSON as the structs of the server
+func TestClientTypesMatchServer(t *testing.T) {
+	pairs := [][2]interface{}{
+		{client.CustomerRequest{}, CustomerRequest{}},
+		{client.Selection1Struct{}, Selection1Struct{}},
+		{client.Selection2Struct{}, Selection2Struct{}},
+		{client.Selection3Struct{}, Selection3Struct{}},
+		{client.Selection4Struct{}, Selection4Struct{}},
+		{client.Selection5Struct{}, Selection5Struct{}},
+		{client.Selection6Struct{}, Selection6Struct{}},
+		{client.RequestStatusStruct{}, RequestStatusStruct{}},
+		{client.CustomerRequestsStruct{}, CustomerRequestsStruct{}},
+		{client.StatsStruct{}, StatsStruct{}},
+		{client.ReportStruct{}, ReportStruct{}},
+		{client.QuotasJSON{}, QuotasJSON{}},
+		{client.ScheduledStruct{}, ScheduledStruct{}},
+		{client.EventsStruct{}, EventsStruct{}},
+		{client.Agent{}, Agent{}},
+		{client.Offer{}, Offer{}},
+		{client.OfferResponseStruct{}, OfferResponseStruct{}},
+		{client.BatchStruct{}, BatchStruct{}},
+	}
+	for _, pair := range pairs {
+		clientComponents := make(map[string]interface{})
+		serverComponents := make(map[string]interface{})
+		schemaFor(reflect.TypeOf(pair[0]), clientComponents)
+		schemaFor(reflect.TypeOf(pair[1]), serverComponents)
+		if !reflect.DeepEqual(clientComponents, serverComponents) {
+			t.Errorf("client.%T does not match %T", pair[0], pair[1])
+		}
+	}
+}
+
+// This test uses the client against the real router
+func TestClient(t *testing.T) {
+	PQ = PriorityQueue{queue: priorityqueue.New(priorityqueue.WithName("DefaultQueue"), priorityqueue.WithCapacity(2))}
+	AR = AgentRegistry{agents: make(map[string]*Agent)}
+	DP = newDispatcher(&PQ, &AR, logNotifier{}, DISPATCHPOLICY, OFFERTIMEOUT)
+	IK = IdempotencyStore{entries: make(map[string]IdempotencyEntry)}
+	server := httptest.NewServer(newRouter())
+	defer server.Close()
+	c := client.New(server.URL, client.WithRetries(2, time.Millisecond))
+	ctx := context.Background()
+
+	if _, err := c.Service(ctx); !errors.Is(err, client.ErrNothingToService) {
+		t.Errorf("Service() failed. Expected ErrNothingToService, got %v", err)
+	}
+	first, err := c.EnqueueIdempotent(ctx, client.CustomerRequest{CustomerName: "c1", Description: "d", PriorityWeight: 3}, "k1")
+	if err != nil || first.ID != 0 {
+		t.Fatalf("Enqueue() failed. %+v %v", first, err)
+	}
+	if retried, err := c.EnqueueIdempotent(ctx, client.CustomerRequest{CustomerName: "c1", Description: "d", PriorityWeight: 3}, "k1"); err != nil || retried.ID != first.ID {
+		t.Errorf("Enqueue() failed. Expected the original response on retry, got %+v %v", retried, err)
+	}
+	second, err := c.Enqueue(ctx, client.CustomerRequest{CustomerName: "c2", Description: "d", PriorityWeight: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsStruct, err := c.GetRequest(ctx, second.Token); err != nil || rsStruct.ID != second.ID || rsStruct.Status != "WAITING" {
+		t.Errorf("GetRequest() failed. %+v %v", rsStruct, err)
+	}
+	if crStruct, err := c.CustomerRequests(ctx, "c1"); err != nil || len(crStruct.Requests) != 1 {
+		t.Errorf("CustomerRequests() failed. %+v %v", crStruct, err)
+	}
+	_, err = c.Enqueue(ctx, client.CustomerRequest{CustomerName: "c3", Description: "d", PriorityWeight: 1})
+	var apiErr *client.Error
+	if !errors.Is(err, client.ErrCapacityReached) || !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Enqueue() failed. Expected ErrCapacityReached, got %v", err)
+	}
+
+	if s1Struct, err := c.List(ctx); err != nil || s1Struct.Size != 2 || s1Struct.OldestTaskID != 0 {
+		t.Errorf("List() failed. %+v %v", s1Struct, err)
+	}
+	if s2Struct, err := c.Detail(ctx); err != nil || len(s2Struct.CustomerRequests) != 2 {
+		t.Errorf("Detail() failed. %+v %v", s2Struct, err)
+	}
+	if s3Struct, err := c.Service(ctx); err != nil || s3Struct.CustomerName != "c2" {
+		t.Errorf("Service() failed. Expected c2, got %+v %v", s3Struct, err)
+	}
+	if rsStruct, err := c.Request(ctx, second.ID); err != nil || rsStruct.Status != "SERVICED" || rsStruct.CompletedAt == nil {
+		t.Errorf("Request() failed. Expected a serviced request, got %+v %v", rsStruct, err)
+	}
+	if _, err := c.RenegeRequest(ctx, second.Token); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("RenegeRequest() failed. Expected ErrNotFound after service, got %v", err)
+	}
+	if _, err := c.RenegeCustomer(ctx, "nobody"); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("RenegeCustomer() failed. Expected ErrNotFound, got %v", err)
+	}
+	if _, err := c.Renege(ctx, 0, ""); !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("Renege() failed. Expected ErrForbidden without the token, got %v", err)
+	}
+	if s5Struct, err := c.Renege(ctx, 0, first.Token); err != nil || s5Struct.ID != 0 {
+		t.Errorf("Renege() failed. %+v %v", s5Struct, err)
+	}
+	if _, err := c.Renege(ctx, 0, first.Token); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("Renege() failed. Expected ErrNotFound, got %v", err)
+	}
+	if report, err := c.Report(ctx, time.Time{}, time.Time{}, "", "priorityWeight"); err != nil || report.GroupBy != "priorityWeight" || len(report.Rows) == 0 {
+		t.Errorf("Report() failed. %+v %v", report, err)
+	}
+	if _, err := c.Report(ctx, time.Time{}, time.Time{}, "month", ""); !errors.Is(err, client.ErrInvalidParameters) {
+		t.Errorf("Report() failed. Expected ErrInvalidParameters, got %v", err)
+	}
+	if ordering, err := c.SetOrdering(ctx, "AGING"); err != nil || ordering != "AGING" {
+		t.Errorf("SetOrdering() failed. %s %v", ordering, err)
+	}
+	if _, err := c.SetOrdering(ctx, "RANDOM"); !errors.Is(err, client.ErrInvalidParameters) {
+		t.Errorf("SetOrdering() failed. Expected ErrInvalidParameters, got %v", err)
+	}
+	if _, err := c.SetCapacity(ctx, 0); !errors.Is(err, client.ErrInvalidParameters) {
+		t.Errorf("SetCapacity() failed. Expected ErrInvalidParameters, got %v", err)
+	}
+	if capacity, err := c.SetCapacity(ctx, 2); err != nil || capacity != 2 {
+		t.Errorf("SetCapacity() failed. %d %v", capacity, err)
+	}
+	if quotas, err := c.SetQuotas(ctx, client.QuotasJSON{RateLimit: 100, MaxOutstanding: 100}); err != nil || quotas.RateBurst != 100 {
+		t.Errorf("SetQuotas() failed. %+v %v", quotas, err)
+	}
+	if _, err := c.SetQuotas(ctx, client.QuotasJSON{}); err != nil {
+		t.Errorf("SetQuotas() failed. %v", err)
+	}
+	if s6Struct, err := c.SystemInfo(ctx); err != nil || s6Struct.Queue.RenegedCount != 1 || s6Struct.Queue.Ordering != "AGING" {
+		t.Errorf("SystemInfo() failed. %+v %v", s6Struct, err)
+	}
+
+	if _, err := c.RegisterAgent(ctx, client.Agent{ID: "a1", Skills: map[string]int{"english": 5}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.RegisterAgent(ctx, client.Agent{ID: "a1"}); !errors.Is(err, client.ErrConflict) {
+		t.Errorf("RegisterAgent() failed. Expected ErrConflict, got %v", err)
+	}
+	if _, err := c.SetAgentState(ctx, "a1", "SLEEPING"); !errors.Is(err, client.ErrInvalidParameters) {
+		t.Errorf("SetAgentState() failed. Expected ErrInvalidParameters, got %v", err)
+	}
+	if agents, err := c.ListAgents(ctx); err != nil || len(agents) != 1 || agents[0].State != client.AVAILABLE {
+		t.Errorf("ListAgents() failed. %+v %v", agents, err)
+	}
+	batch, err := c.EnqueueBatch(ctx, client.BESTEFFORT, []client.CustomerRequest{
+		{CustomerName: "c4", Description: "d", PriorityWeight: 2},
+		{CustomerName: "c5", Description: "d", PriorityWeight: 2},
+		{CustomerName: "c6", Description: "d", PriorityWeight: 2}})
+	if err != nil || batch.Succeeded != 2 || batch.Failed != 1 || batch.Results[2].Error.Error != "MAX_CAPACITY_REACHED" {
+		t.Errorf("EnqueueBatch() failed. %+v %v", batch, err)
+	}
+}
+
+// This test checks that the client retries 503 responses with backoff and gives up on context cancellation
+func TestClientRetries(t *testing.T) {
+	PQ = PriorityQueue{queue: priorityqueue.New(priorityqueue.WithName("DefaultQueue"), priorityqueue.WithCapacity(SIZE))}
+	IK = IdempotencyStore{entries: make(map[string]IdempotencyEntry)}
+	router := newRouter()
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			writeError(w, http.StatusServiceUnavailable, "MAX_CAPACITY_REACHED", "try again")
+			return
+		}
+		router.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	c := client.New(server.URL, client.WithRetries(3, time.Millisecond))
+	if s4Struct, err := c.Enqueue(ctx, client.CustomerRequest{CustomerName: "c1", Description: "d", PriorityWeight: 3}); err != nil || s4Struct.CustomerName != "c1" {
+		t.Errorf("Enqueue() failed. Expected success after 2 retries, got %+v %v", s4Struct, err)
+	}
+	if atomic.LoadInt32(&calls) != 3 {
+		t.Errorf("Enqueue() failed. Expected 3 calls, got %d", calls)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	c = client.New(server.URL, client.WithRetries(1, time.Millisecond))
+	if _, err := c.List(ctx); !errors.Is(err, client.ErrCapacityReached) || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("List() failed. Expected to give up after 1 retry, got %v after %d calls", err, calls)
+	}
+
+	atomic.StoreInt32(&calls, -100)
+	c = client.New(server.URL, client.WithRetries(100, time.Second))
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.List(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("List() failed. Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+// This test checks that authentication errors are mapped
+func TestClientAuthentication(t *testing.T) {
+	if err := loadAuthConfig("agentkey:agent:a1,c1key:customer:c1", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer loadAuthConfig("", "")
+	server := httptest.NewServer(newRouter())
+	defer server.Close()
+	ctx := context.Background()
+
+	if _, err := client.New(server.URL).List(ctx); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("List() failed. Expected ErrUnauthorized, got %v", err)
+	}
+	if _, err := client.New(server.URL, client.WithAPIKey("c1key")).List(ctx); !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("List() failed. Expected ErrForbidden, got %v", err)
+	}
+	if _, err := client.New(server.URL, client.WithAPIKey("agentkey")).List(ctx); err != nil {
+		t.Errorf("List() failed. %v", err)
+	}
+}
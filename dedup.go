@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// Duplicate policies, they decide what happens to a request of a customer who already has a request
+// in the queue. REJECT refuses it.
+const (
+	ALLOW   = "ALLOW"   // the customer may have many requests, it is the default
+	MERGE   = "MERGE"   // the request is merged into the earlier one, which keeps its EnqueueTime and takes the higher weight
+	REPLACE = "REPLACE" // the earlier request is dropped and the request is enqueued
+)
+
+var errDuplicateCustomer = errors.New("the customer already has a request in the queue")
+
+// This function returns the key customers are identified by
+func customerKey(cr *CustomerRequest) string {
+	return cr.CustomerName
+}
+
+// This method is for setting the duplicate policy of pq, it fails if a customer has many requests in the queue
+func setDuplicatePolicy(pq *PriorityQueue, policy string) error {
+	if policy != ALLOW && policy != REJECT && policy != MERGE && policy != REPLACE {
+		return errors.New("duplicate policy must be ALLOW, REJECT, MERGE or REPLACE")
+	}
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	var key func(cr *CustomerRequest) string
+	if policy != ALLOW {
+		key = customerKey
+	}
+	if err := pq.queue.SetUnique(key); err != nil {
+		return errors.New("a customer has many requests in the queue")
+	}
+	logger.Printf("duplicate policy of %s is %s", pq.queue.Name(), policy)
+	pq.duplicatePolicy = policy
+	return nil
+}
+
+// This function returns if customers may have only one request in pq
+func isUnique(pq *PriorityQueue) bool {
+	return pq.duplicatePolicy != "" && pq.duplicatePolicy != ALLOW
+}
+
+// This function returns the waiting, scheduled or overflowed request of the customer of cr. pq.mutex must be held.
+func findCustomer(pq *PriorityQueue, cr *CustomerRequest) (*CustomerRequest, bool) {
+	if !isUnique(pq) {
+		return nil, false
+	}
+	key := customerKey(cr)
+	if existing, err := pq.queue.Find(key); err == nil {
+		return existing, true
+	}
+	for _, existing := range pq.buffer {
+		if customerKey(existing) == key {
+			return existing, true
+		}
+	}
+	if pq.overflow != nil {
+		for _, existing := range append(pq.overflow.Items(), pq.overflow.ScheduledItems()...) {
+			if customerKey(existing) == key {
+				return existing, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// This function merges cr into the earlier request of the same customer, which keeps its EnqueueTime and takes
+// the higher PriorityWeight. pq.mutex must be held.
+func merge(pq *PriorityQueue, existing *CustomerRequest, cr *CustomerRequest) {
+	recordEvent(pq, "MERGED", existing, "")
+	raise := func(existing *CustomerRequest) {
+		if cr.PriorityWeight > existing.PriorityWeight {
+			existing.PriorityWeight = cr.PriorityWeight
+		}
+	}
+	// waiting requests are moved in their heap, scheduled and buffered ones are changed in place
+	if _, err := pq.queue.Update(existing.ID, raise); err == nil {
+		return
+	}
+	if pq.overflow != nil {
+		if _, err := pq.overflow.Update(existing.ID, raise); err == nil {
+			return
+		}
+	}
+	raise(existing)
+}
+
+// This function drops the earlier request of the same customer for the request replacing it. pq.mutex must be held.
+func replace(pq *PriorityQueue, existing *CustomerRequest) {
+	_, err := pq.queue.Remove(existing.ID)
+	if err != nil {
+		_, err = pq.queue.Cancel(existing.ID)
+	}
+	if err != nil {
+		_, err = removeOverflowed(pq, existing.ID)
+	}
+	if err != nil {
+		return
+	}
+	drop(pq, existing)
+	recordEvent(pq, "REPLACED", existing, "")
+	recordCompleted(pq, existing, REPLACED, "", time.Now())
+}
+
+// This function reports if a request of the client owner may be merged into or replace existing by the
+// duplicate policy. Requests of other clients are never changed, nor are offered requests replaced.
+// pq.mutex must be held.
+func mayDeduplicate(pq *PriorityQueue, existing *CustomerRequest, owner string) bool {
+	return pq.duplicatePolicy != REJECT && pq.owners[existing.ID] == owner &&
+		(pq.duplicatePolicy != REPLACE || statusOf(pq, existing, time.Now()).Status != OFFERED)
+}
+
+// This function applies the duplicate policy to cr of the client owner. It returns the request the enqueue ends up with, which is
+// cr unless it was merged, and the policy that was applied. pq.mutex must be held.
+func deduplicate(pq *PriorityQueue, cr *CustomerRequest, owner string) (*CustomerRequest, string, error) {
+	existing, ok := findCustomer(pq, cr)
+	if !ok {
+		return cr, "", nil
+	}
+	if !mayDeduplicate(pq, existing, owner) {
+		return nil, "", errDuplicateCustomer
+	}
+	if pq.duplicatePolicy == MERGE {
+		merge(pq, existing, cr)
+		return existing, MERGE, nil
+	}
+	replace(pq, existing)
+	return cr, REPLACE, nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Dispatch policies
+const (
+	LONGESTIDLE = "LONGEST_IDLE"
+	ROUNDROBIN  = "ROUND_ROBIN"
+)
+
+// DISPATCHPOLICY is the policy used to choose the agent for the next offer
+var DISPATCHPOLICY = LONGESTIDLE
+
+// OFFERTIMEOUT is how long an agent has to accept or reject an offer
+var OFFERTIMEOUT = 15 * time.Second
+
+// errOfferNotFound is returned for offers that were answered or expired, errNotOfferedAgent for a
+// response of anyone but the agent of the offer and errAgentNotAvailable if the agent is no longer
+// AVAILABLE or got another request since the offer was made
+var (
+	errOfferNotFound     = errors.New("offer not found or expired")
+	errNotOfferedAgent   = errors.New("agents may only respond to their own offers")
+	errAgentNotAvailable = errors.New("the agent is no longer available for the offer")
+)
+
+// DP is the dispatcher of PQ
+var DP = newDispatcher(&PQ, &AR, logNotifier{}, DISPATCHPOLICY, OFFERTIMEOUT)
+
+// logNotifier only logs offers, agents poll /api/v1.0/agents/{id}/offer to receive them
+type logNotifier struct{}
+
+func (logNotifier) Notify(offer Offer) error {
+	logger.Printf("offer %d of customer request %d to agent %s expires at %s", offer.ID, offer.CustomerRequest.ID, offer.AgentID, offer.ExpiresAt)
+	return nil
+}
+
+func newDispatcher(pq *PriorityQueue, ar *AgentRegistry, notifier Notifier, policy string, offerTimeout time.Duration) *Dispatcher {
+	return &Dispatcher{
+		pq:           pq,
+		ar:           ar,
+		notifier:     notifier,
+		policy:       policy,
+		offerTimeout: offerTimeout,
+		offers:       make(map[int]*pendingOffer),
+		agentOffers:  make(map[string]int)}
+}
+
+// This method is used as a goroutine to offer requests to idle agents and expire unanswered offers
+// until stop is closed
+func runDispatcher(d *Dispatcher, interval time.Duration, stop <-chan struct{}) {
+	logger.Printf("starting dispatcher with policy %s", d.policy)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			logger.Println("stopped dispatcher")
+			return
+		case now := <-ticker.C:
+			d.expireOffers(now)
+			d.dispatch()
+		}
+	}
+}
+
+// This function offers the highest priority requests to the idle agents that can handle them.
+// The mutexes are always locked in the order dispatcher, agent registry, priority queue.
+func (d *Dispatcher) dispatch() []Offer {
+	d.mutex.Lock()
+	d.ar.mutex.Lock()
+	d.pq.mutex.Lock()
+	made := make([]Offer, 0)
+	for _, agent := range d.idleAgents() {
+		if d.pq.queue.Len() <= 0 {
+			break
+		}
+		cr, err := peekForAgent(d.pq, agent)
+		if err != nil {
+			continue
+		}
+		_, _ = d.pq.queue.Hold(cr.ID) // the customer keeps its unique key while the request is offered
+		d.pq.offered++
+		recordEvent(d.pq, "OFFERED", cr, agent.ID)
+
+		now := time.Now()
+		offer := Offer{
+			ID:        d.nextOfferID,
+			AgentID:   agent.ID,
+			OfferedAt: now,
+			ExpiresAt: now.Add(d.offerTimeout),
+			CustomerRequest: &CustomerRequest{
+				ID:             cr.ID,
+				PriorityWeight: cr.PriorityWeight,
+				CustomerName:   cr.CustomerName,
+				Description:    cr.Description,
+				EnqueueTime:    cr.EnqueueTime,
+				Deadline:       cr.Deadline,
+				RequiredSkills: cr.RequiredSkills,
+				Account:        cr.Account}}
+		d.nextOfferID++
+		d.offers[offer.ID] = &pendingOffer{offer: offer, cr: cr}
+		d.agentOffers[agent.ID] = offer.ID
+		d.lastAgentID = agent.ID
+		made = append(made, offer)
+	}
+	d.pq.mutex.Unlock()
+	d.ar.mutex.Unlock()
+	d.mutex.Unlock()
+
+	// Notify without holding any lock, so notifiers may respond right away
+	for _, offer := range made {
+		if err := d.notifier.Notify(offer); err != nil {
+			logger.Printf("error notifying agent %s of offer %d. %s", offer.AgentID, offer.ID, err.Error())
+		}
+	}
+	return made
+}
+
+// This function returns the AVAILABLE agents without a pending offer in the order of the policy.
+// d.mutex and d.ar.mutex must be held.
+func (d *Dispatcher) idleAgents() []*Agent {
+	agents := make([]*Agent, 0)
+	for _, agent := range d.ar.agents {
+		if _, ok := d.agentOffers[agent.ID]; !ok && agent.State == AVAILABLE {
+			agents = append(agents, agent)
+		}
+	}
+	if d.policy == ROUNDROBIN {
+		sort.Slice(agents, func(i, j int) bool { return agents[i].ID < agents[j].ID })
+		// start right after the agent who got the last offer
+		start := sort.Search(len(agents), func(i int) bool { return agents[i].ID > d.lastAgentID })
+		return append(agents[start:], agents[:start]...)
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].StateSince.Before(agents[j].StateSince) })
+	return agents
+}
+
+// This function returns the pending offer of the agent with id=agentID
+func (d *Dispatcher) getOffer(agentID string) (Offer, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	offerID, ok := d.agentOffers[agentID]
+	if !ok {
+		return Offer{}, errors.New("no pending offer")
+	}
+	return d.offers[offerID].offer, nil
+}
+
+// This function handles the response of an agent to an offer. An accepted request is assigned
+// to the agent, a rejected one is put back in the heap and is not offered to the agent again.
+// mayRespond decides if the caller may answer for the agent of the offer. An accept is refused and the
+// request put back if the agent is no longer AVAILABLE or was assigned another request meanwhile.
+func (d *Dispatcher) respond(offerID int, accept bool, mayRespond func(agentID string) bool) (OfferResponseStruct, error) {
+	logger.Printf("response to offer %d, accepted: %t", offerID, accept)
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	po, ok := d.offers[offerID]
+	if !ok {
+		return OfferResponseStruct{}, errOfferNotFound
+	}
+	if !mayRespond(po.offer.AgentID) {
+		return OfferResponseStruct{}, errNotOfferedAgent
+	}
+	delete(d.offers, offerID)
+	delete(d.agentOffers, po.offer.AgentID)
+
+	d.ar.mutex.Lock()
+	defer d.ar.mutex.Unlock()
+	d.pq.mutex.Lock()
+	defer d.pq.mutex.Unlock()
+	cr := po.cr
+	agent, ok := d.ar.agents[po.offer.AgentID]
+	if !accept || !ok {
+		if d.pq.declined == nil {
+			d.pq.declined = make(map[int]map[string]bool)
+		}
+		if d.pq.declined[cr.ID] == nil {
+			d.pq.declined[cr.ID] = make(map[string]bool)
+		}
+		d.pq.declined[cr.ID][po.offer.AgentID] = true
+		d.requeue(cr, "REJECTED")
+		return OfferResponseStruct{OfferID: offerID, Accepted: false}, nil
+	}
+	if agent.State != AVAILABLE || agent.Assignment != nil {
+		d.requeue(cr, "UNAVAILABLE")
+		return OfferResponseStruct{}, errAgentNotAvailable
+	}
+
+	d.pq.offered--
+	if _, err := d.pq.queue.Serve(cr.ID); err != nil {
+		logger.Printf("error serving offered customer request %d. %s", cr.ID, err.Error())
+	}
+	forget(d.pq, cr)
+	recordEvent(d.pq, "SERVICED", cr, agent.ID)
+	now := time.Now()
+	agent.State = BUSY
+	agent.StateSince = now
+	agent.Assignment = &Assignment{RequestID: cr.ID, CustomerName: cr.CustomerName, AssignedAt: now}
+	s3Struct := Selection3Struct{ID: cr.ID,
+		PriorityWeight: cr.PriorityWeight,
+		CustomerName:   cr.CustomerName,
+		Description:    cr.Description,
+		EnqueueTime:    cr.EnqueueTime,
+		WaitTimeinSec:  now.Sub(cr.EnqueueTime).Seconds(),
+		RequiredSkills: cr.RequiredSkills,
+		AgentID:        agent.ID}
+	recordSample(d.pq, now, s3Struct.WaitTimeinSec, false)
+	recordCompleted(d.pq, cr, SERVICED, agent.ID, now)
+	return OfferResponseStruct{OfferID: offerID, Accepted: true, Request: s3Struct}, nil
+}
+
+// This function puts back the requests of offers that were not answered in time,
+// the agents who did not answer are set AWAY so they are not offered anything else.
+func (d *Dispatcher) expireOffers(now time.Time) int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.ar.mutex.Lock()
+	defer d.ar.mutex.Unlock()
+	d.pq.mutex.Lock()
+	defer d.pq.mutex.Unlock()
+	expired := 0
+	for offerID, po := range d.offers {
+		if po.offer.ExpiresAt.After(now) {
+			continue
+		}
+		delete(d.offers, offerID)
+		delete(d.agentOffers, po.offer.AgentID)
+		if agent, ok := d.ar.agents[po.offer.AgentID]; ok && agent.State == AVAILABLE {
+			agent.State = AWAY
+			agent.StateSince = now
+		}
+		d.requeue(po.cr, "TIMEOUT")
+		expired++
+	}
+	return expired
+}
+
+// This function puts an offered request back in the heap, its slot was reserved while offered.
+// d.pq.mutex must be held.
+func (d *Dispatcher) requeue(cr *CustomerRequest, reason string) {
+	d.pq.offered--
+	d.pq.queue.Restore(cr)
+	recordEvent(d.pq, "REQUEUED", cr, reason)
+}
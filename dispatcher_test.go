@@ -0,0 +1,138 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeNotifier records offers and answers them in-process
+type fakeNotifier struct {
+	d       *Dispatcher
+	offers  []Offer
+	answers map[string]string // answers maps an agent to ACCEPT, REJECT or nothing to let the offer time out
+}
+
+func (f *fakeNotifier) Notify(offer Offer) error {
+	f.offers = append(f.offers, offer)
+	switch f.answers[offer.AgentID] {
+	case "ACCEPT":
+		_, _ = f.d.respond(offer.ID, true, anyAgent)
+	case "REJECT":
+		_, _ = f.d.respond(offer.ID, false, anyAgent)
+	}
+	return nil
+}
+
+// anyAgent lets every caller respond to an offer
+func anyAgent(string) bool { return true }
+
+func newTestDispatcher(policy string, answers map[string]string, agentIDs ...string) (*Dispatcher, *fakeNotifier) {
+	pq := newPriorityQueue("DefaultQueue", "This queue is for demonstration of Priority Queue implementation", 10)
+	ar := &AgentRegistry{agents: make(map[string]*Agent)}
+	for _, id := range agentIDs {
+		_ = registerAgent(ar, &Agent{ID: id})
+		time.Sleep(time.Millisecond) // agents registered first have been idle longest
+	}
+	f := &fakeNotifier{answers: answers}
+	d := newDispatcher(pq, ar, f, policy, time.Minute)
+	f.d = d
+	return d, f
+}
+
+// This test checks that the longest idle agent is offered the top request and that a rejected
+// request is offered to the next agent
+func TestDispatchLongestIdle(t *testing.T) {
+	d, f := newTestDispatcher(LONGESTIDLE, map[string]string{"a1": "REJECT", "a2": "ACCEPT"}, "a1", "a2")
+	_ = insert(d.pq, &CustomerRequest{PriorityWeight: 5, CustomerName: "first", EnqueueTime: time.Now()}, false)
+
+	d.dispatch()
+	if len(f.offers) != 1 || f.offers[0].AgentID != "a1" {
+		t.Fatalf("dispatch() failed. Expected longest idle agent a1 to get the offer")
+	}
+	if d.pq.queue.Len() != 1 || d.pq.offered != 0 {
+		t.Errorf("respond() failed. Rejected request should be back in the heap")
+	}
+
+	d.dispatch()
+	if len(f.offers) != 2 || f.offers[1].AgentID != "a2" {
+		t.Fatalf("dispatch() failed. Rejected request should be offered to a2")
+	}
+	agent, _ := getAgentByID(d.ar, "a2")
+	if d.pq.queue.Len() != 0 || agent.State != BUSY || agent.Assignment.RequestID != f.offers[1].CustomerRequest.ID {
+		t.Errorf("respond() failed. Accepted request should be assigned to a2")
+	}
+}
+
+// This test checks that offers are spread in round robin and unanswered offers time out
+func TestDispatchRoundRobinTimeout(t *testing.T) {
+	d, f := newTestDispatcher(ROUNDROBIN, map[string]string{}, "b", "a")
+	for i := 0; i < 3; i++ {
+		_ = insert(d.pq, &CustomerRequest{PriorityWeight: i + 1, CustomerName: "name", EnqueueTime: time.Now()}, false)
+	}
+
+	d.dispatch()
+	if len(f.offers) != 2 || f.offers[0].AgentID != "a" || f.offers[1].AgentID != "b" {
+		t.Fatalf("dispatch() failed. Expected offers to a and b in order")
+	}
+	if d.pq.queue.Len() != 1 || d.pq.offered != 2 || occupancy(d.pq) != 3 {
+		t.Errorf("dispatch() failed. Offered requests should keep their slot")
+	}
+
+	if expired := d.expireOffers(time.Now().Add(2 * time.Minute)); expired != 2 {
+		t.Errorf("expireOffers() failed. Expected both offers to time out")
+	}
+	if info := countAgents(d.ar); info.Away != 2 || d.pq.queue.Len() != 3 || d.pq.offered != 0 {
+		t.Errorf("expireOffers() failed. Agents should be AWAY and requests back in the heap")
+	}
+}
+
+// This test checks that a customer whose request is offered can not enqueue another one
+func TestDispatchKeepsCustomerKey(t *testing.T) {
+	d, f := newTestDispatcher(LONGESTIDLE, map[string]string{}, "a1")
+	if err := setDuplicatePolicy(d.pq, REPLACE); err != nil {
+		t.Fatal(err)
+	}
+	_ = insert(d.pq, &CustomerRequest{PriorityWeight: 5, CustomerName: "first", EnqueueTime: time.Now()}, false)
+	d.dispatch()
+	if len(f.offers) != 1 {
+		t.Fatalf("dispatch() failed. Expected an offer")
+	}
+	if _, err := selection4(d.pq, &CustomerRequest{PriorityWeight: 9, CustomerName: "first", EnqueueTime: time.Now()}, "", false); err != errDuplicateCustomer {
+		t.Errorf("selection4() failed. Expected errDuplicateCustomer while the request is offered, got %v", err)
+	}
+	if _, err := d.respond(f.offers[0].ID, false, anyAgent); err != nil || d.pq.queue.Len() != 1 {
+		t.Fatalf("respond() failed. Expected the request to be back in the heap, %v", err)
+	}
+	if cr, err := d.pq.queue.Find("first"); err != nil || cr.ID != f.offers[0].CustomerRequest.ID {
+		t.Errorf("respond() failed. Expected the rejected request to keep the key, got %+v %v", cr, err)
+	}
+}
+
+// This test checks that only the agent of an offer may respond to it and that an accept is refused
+// once the agent is no longer AVAILABLE
+func TestRespondChecksAgent(t *testing.T) {
+	d, f := newTestDispatcher(LONGESTIDLE, map[string]string{}, "a1")
+	_ = insert(d.pq, &CustomerRequest{PriorityWeight: 5, CustomerName: "first", EnqueueTime: time.Now()}, false)
+	d.dispatch()
+	if len(f.offers) != 1 {
+		t.Fatalf("dispatch() failed. Expected an offer")
+	}
+	isA2 := func(agentID string) bool { return agentID == "a2" }
+	if _, err := d.respond(f.offers[0].ID, true, isA2); err != errNotOfferedAgent || d.pq.offered != 1 {
+		t.Fatalf("respond() failed. Expected errNotOfferedAgent and the offer to stay pending, got %v", err)
+	}
+
+	if _, err := setAgentState(d.ar, "a1", AWAY); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.respond(f.offers[0].ID, true, anyAgent); err != errAgentNotAvailable {
+		t.Fatalf("respond() failed. Expected errAgentNotAvailable, got %v", err)
+	}
+	agent, _ := getAgentByID(d.ar, "a1")
+	if d.pq.queue.Len() != 1 || d.pq.offered != 0 || agent.State != AWAY || agent.Assignment != nil {
+		t.Errorf("respond() failed. Expected the request back in the heap and the agent unassigned")
+	}
+	if _, err := d.respond(f.offers[0].ID, true, anyAgent); err != errOfferNotFound {
+		t.Errorf("respond() failed. Expected errOfferNotFound once the offer is answered, got %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import "time"
+
+// MAXEVENTS is the number of recent events kept for every queue
+const MAXEVENTS = 1000
+
+// This function records a lifecycle event in the audit log and in the recent events of pq
+func recordEvent(pq *PriorityQueue, eventType string, cr *CustomerRequest, reason string) {
+	e := Event{
+		Type:         eventType,
+		ID:           cr.ID,
+		CustomerName: cr.CustomerName,
+		Reason:       reason,
+		Time:         time.Now()}
+	logger.Printf("AUDIT queue=%s event=%s id=%d customer=%q reason=%s", pq.queue.Name(), e.Type, e.ID, e.CustomerName, e.Reason)
+	if len(pq.events) >= MAXEVENTS {
+		copy(pq.events, pq.events[1:])
+		pq.events = pq.events[:len(pq.events)-1]
+	}
+	pq.events = append(pq.events, e)
+}
+
+// This method is for listing the recent lifecycle events of pq
+func listEvents(pq *PriorityQueue) EventsStruct {
+	logger.Printf("listing events")
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	events := make([]Event, len(pq.events))
+	copy(events, pq.events)
+	return EventsStruct{QueueName: pq.queue.Name(), Events: events}
+}
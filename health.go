@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// startupComplete is set once main has loaded the capacity and the idempotency keys, seeded the queue and
+// started the flusher. The queue is not persisted, so there is nothing to replay before it is set.
+var startupComplete atomic.Bool
+
+// shuttingDown is set once the process has started to shut down
+var shuttingDown atomic.Bool
+
+// This function checks that a file can be created and written in the log directory
+func checkStorage() error {
+	probe := filepath.Join(logPath, ".readyz")
+	file, err := os.OpenFile(probe, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write([]byte("ok"))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	os.Remove(probe)
+	return err
+}
+
+// This function runs every readiness check, the instance is ready if all of them pass: startup is
+// complete, the log directory is writable and shutdown has not started. Ready does not mean requests of
+// a previous run were restored, see gracefulShutdown.
+func checkReadiness() ReadinessStruct {
+	readiness := ReadinessStruct{Status: "READY", Checks: make(map[string]string)}
+	readiness.Checks["startup"] = "OK"
+	if !startupComplete.Load() {
+		readiness.Checks["startup"] = "starting"
+	}
+	readiness.Checks["storage"] = "OK"
+	if err := checkStorage(); err != nil {
+		readiness.Checks["storage"] = err.Error()
+	}
+	readiness.Checks["shutdown"] = "OK"
+	if shuttingDown.Load() {
+		readiness.Checks["shutdown"] = "shutting down"
+	}
+	for _, result := range readiness.Checks {
+		if result != "OK" {
+			readiness.Status = "NOT_READY"
+		}
+	}
+	return readiness
+}
+
+// This method is for the liveness probe, it only shows that the process is serving requests
+func apiHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	enc.Encode(ReadinessStruct{Status: "ALIVE"})
+}
+
+// This method is for the readiness probe, it answers 503 until the instance can take traffic
+func apiReadyz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	readiness := checkReadiness()
+	if readiness.Status != "READY" {
+		logger.Printf("not ready. %v", readiness.Checks)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	enc.Encode(readiness)
+}
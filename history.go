@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MAXHISTORY is the number of completed requests kept for every queue, the oldest are dropped first
+var MAXHISTORY = 100000
+
+// Final states of Customer Requests
+const (
+	SERVICED  = "SERVICED"
+	RENEGED   = "RENEGED"
+	EXPIRED   = "EXPIRED"
+	CANCELLED = "CANCELLED"
+	EVICTED   = "EVICTED"
+	REPLACED  = "REPLACED"
+)
+
+var errRequestNotFound = errors.New("id not found")
+
+var errNotOwner = errors.New("customers may only see their own requests")
+
+// This function records that cr has left pq for good with the final status, agentID is the agent who serviced it.
+// The queue of the record is the overflow queue or buffer for requests that left while they overflowed.
+// pq.mutex must be held.
+func recordCompleted(pq *PriorityQueue, cr *CustomerRequest, status string, agentID string, now time.Time) {
+	queueName, ok := pq.overflowedIn[cr.ID]
+	if !ok {
+		queueName = pq.queue.Name()
+	}
+	delete(pq.overflowedIn, cr.ID)
+	record := CompletedRequest{ID: cr.ID,
+		QueueName:      queueName,
+		CustomerName:   cr.CustomerName,
+		PriorityWeight: cr.PriorityWeight,
+		Account:        cr.Account,
+		Status:         status,
+		AgentID:        agentID,
+		EnqueueTime:    cr.EnqueueTime,
+		CompletedAt:    now,
+		WaitTimeinSec:  now.Sub(cr.EnqueueTime).Seconds(),
+		Owner:          pq.owners[cr.ID]}
+	delete(pq.owners, cr.ID)
+	if record.WaitTimeinSec < 0 {
+		record.WaitTimeinSec = 0 // scheduled requests are cancelled before they start waiting
+	}
+	h := &pq.history
+	if h.byID == nil {
+		h.byID = make(map[int]int)
+	}
+	if len(h.records) < MAXHISTORY {
+		h.records = append(h.records, record)
+		h.byID[record.ID] = len(h.records) - 1
+		return
+	}
+	if h.byID[h.records[h.next].ID] == h.next {
+		delete(h.byID, h.records[h.next].ID)
+	}
+	h.records[h.next] = record
+	h.byID[record.ID] = h.next
+	h.next = (h.next + 1) % len(h.records)
+}
+
+// This function returns the completed requests of pq that completed from from until before to, oldest first.
+// pq.mutex must be held.
+func completedBetween(pq *PriorityQueue, from time.Time, to time.Time) []CompletedRequest {
+	h := &pq.history
+	records := make([]CompletedRequest, 0)
+	for _, part := range [][]CompletedRequest{h.records[h.next:], h.records[:h.next]} {
+		for _, record := range part {
+			if !record.CompletedAt.Before(from) && record.CompletedAt.Before(to) {
+				records = append(records, record)
+			}
+		}
+	}
+	return records
+}
+
+// This function returns the live request with id=ID, waiting, scheduled, overflowed or offered. pq.mutex must be held.
+func findLive(pq *PriorityQueue, ID int) (*CustomerRequest, bool) {
+	if cr, err := pq.queue.Get(ID); err == nil {
+		return cr, true
+	}
+	if cr, err := getOverflowed(pq, ID); err == nil {
+		return cr, true
+	}
+	// offered requests are only held by the dispatcher and the index by token
+	cr, ok := pq.byToken[pq.tokens[ID]]
+	return cr, ok
+}
+
+// This method is for getting the lifecycle status of the live or completed Customer Request with id=ID.
+// mayActFor decides if the caller may see a request of the given owner.
+func getRequest(pq *PriorityQueue, ID int, mayActFor func(owner string) bool, isConsole bool) (RequestStatusStruct, error) {
+	logger.Printf("getting request %d, isConsole: %t", ID, isConsole)
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	var rsStruct RequestStatusStruct
+	owner := ""
+	if cr, ok := findLive(pq, ID); ok {
+		rsStruct = statusOf(pq, cr, time.Now())
+		owner = pq.owners[cr.ID]
+	} else if i, ok := pq.history.byID[ID]; ok {
+		record := pq.history.records[i]
+		completedAt := record.CompletedAt
+		rsStruct = RequestStatusStruct{ID: record.ID,
+			CustomerName:    record.CustomerName,
+			PriorityWeight:  record.PriorityWeight,
+			Status:          record.Status,
+			PositionInQueue: -1,
+			EnqueueTime:     record.EnqueueTime,
+			WaitTimeinSec:   record.WaitTimeinSec,
+			CompletedAt:     &completedAt,
+			AgentID:         record.AgentID}
+		owner = record.Owner
+	} else {
+		return RequestStatusStruct{}, errRequestNotFound
+	}
+	if !mayActFor(owner) {
+		return RequestStatusStruct{}, errNotOwner
+	}
+	if isConsole {
+		jsonData, _ := json.MarshalIndent(rsStruct, "", "    ")
+		fmt.Println(string(jsonData))
+	}
+	return rsStruct, nil
+}
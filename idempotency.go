@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// IDEMPOTENCYWINDOW is how long a retried enqueue returns the original response
+var IDEMPOTENCYWINDOW = 24 * time.Hour
+
+// IDEMPOTENCYFLUSHDELAY is how long after an enqueue its idempotency key is persisted at most, a key
+// acknowledged within this delay before a crash is lost
+var IDEMPOTENCYFLUSHDELAY = 100 * time.Millisecond
+
+// IDEMPOTENCYFILE is the file in the log directory the idempotency keys are persisted to
+const IDEMPOTENCYFILE = "idempotency.json"
+
+// IK is the store of idempotency keys of PQ
+var IK = IdempotencyStore{entries: make(map[string]IdempotencyEntry), written: make(chan struct{}, 1)}
+
+// This function returns the key a retried enqueue is recognized by, the Idempotency-Key header
+// takes precedence over the external reference. The key is scoped to the client.
+func idempotencyKey(client string, header string, cr *CustomerRequest) string {
+	if header != "" {
+		return client + "|key:" + header
+	}
+	if cr.ExternalRef != "" {
+		return client + "|ref:" + cr.ExternalRef
+	}
+	return ""
+}
+
+func fingerprint(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// This function returns the stored entry of key if it is within the window. ik.mutex must be held.
+func lookupIdempotencyKey(ik *IdempotencyStore, key string, now time.Time) (IdempotencyEntry, bool) {
+	entry, ok := ik.entries[key]
+	if !ok || now.Sub(entry.CreatedAt) > IDEMPOTENCYWINDOW {
+		return IdempotencyEntry{}, false
+	}
+	return entry, true
+}
+
+// This function returns the stored entry of key if there is one. Otherwise key is marked as in flight
+// until releaseIdempotencyKey, so a concurrent retry waits for the enqueue instead of making it again
+// while the store is not locked during the enqueue.
+func reserveIdempotencyKey(ik *IdempotencyStore, key string, now time.Time) (IdempotencyEntry, bool) {
+	ik.mutex.Lock()
+	defer ik.mutex.Unlock()
+	for {
+		if entry, ok := lookupIdempotencyKey(ik, key, now); ok {
+			return entry, true
+		}
+		done, ok := ik.pending[key]
+		if !ok {
+			break
+		}
+		ik.mutex.Unlock()
+		<-done
+		ik.mutex.Lock()
+	}
+	if ik.pending == nil {
+		ik.pending = make(map[string]chan struct{})
+	}
+	ik.pending[key] = make(chan struct{})
+	return IdempotencyEntry{}, false
+}
+
+// This function ends the enqueue of the reserved key, entry is stored if the enqueue was successful.
+// The retries waiting for the key go on.
+func releaseIdempotencyKey(ik *IdempotencyStore, key string, entry *IdempotencyEntry) {
+	ik.mutex.Lock()
+	defer ik.mutex.Unlock()
+	if entry != nil {
+		storeIdempotencyKey(ik, key, *entry)
+	}
+	close(ik.pending[key])
+	delete(ik.pending, key)
+}
+
+// This function stores the response of a successful enqueue and wakes up the flusher. ik.mutex must be held.
+func storeIdempotencyKey(ik *IdempotencyStore, key string, entry IdempotencyEntry) {
+	ik.entries[key] = entry
+	ik.dirty = true
+	select {
+	case ik.written <- struct{}{}:
+	default: // a flush is already due
+	}
+}
+
+// This function drops the keys outside of the window and writes the rest to the log directory.
+// The keys are copied under the lock and written without it, so enqueues do not wait for the file.
+func saveIdempotencyKeys(ik *IdempotencyStore, now time.Time) error {
+	ik.saving.Lock()
+	defer ik.saving.Unlock()
+	ik.mutex.Lock()
+	for key, entry := range ik.entries {
+		if now.Sub(entry.CreatedAt) > IDEMPOTENCYWINDOW {
+			delete(ik.entries, key)
+			ik.dirty = true
+		}
+	}
+	if !ik.dirty {
+		ik.mutex.Unlock()
+		return nil
+	}
+	entries := make(map[string]IdempotencyEntry, len(ik.entries))
+	for key, entry := range ik.entries {
+		entries[key] = entry
+	}
+	ik.dirty = false
+	ik.mutex.Unlock()
+
+	if err := saveJSON(IDEMPOTENCYFILE, entries); err != nil {
+		logger.Printf("error saving idempotency keys. %s", err.Error())
+		ik.mutex.Lock()
+		ik.dirty = true
+		ik.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+// This function loads the idempotency keys persisted by a previous run. The queue is not persisted,
+// so a key is dropped unless its request is still live in pq; a retry of a lost request enqueues it again.
+func loadIdempotencyKeys(ik *IdempotencyStore, pq *PriorityQueue) error {
+	entries := make(map[string]IdempotencyEntry)
+	if err := loadJSON(IDEMPOTENCYFILE, &entries); err != nil {
+		return err
+	}
+	pq.mutex.Lock()
+	for key, entry := range entries {
+		if cr, ok := pq.byToken[entry.Response.Token]; !ok || cr.ID != entry.Response.ID {
+			delete(entries, key)
+		}
+	}
+	pq.mutex.Unlock()
+	ik.mutex.Lock()
+	defer ik.mutex.Unlock()
+	ik.entries = entries
+	logger.Printf("loaded %d idempotency keys", len(entries))
+	return nil
+}
+
+// This method is used as a goroutine to persist new idempotency keys delay after they are stored, so
+// the keys of enqueues made meanwhile are written at once, and to drop expired keys every interval.
+// It returns once stop is closed.
+func runIdempotencyFlusher(ik *IdempotencyStore, delay time.Duration, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			_ = saveIdempotencyKeys(ik, now)
+		case <-ik.written:
+			select {
+			case <-stop:
+				return
+			case <-time.After(delay):
+			}
+			_ = saveIdempotencyKeys(ik, time.Now())
+		}
+	}
+}
@@ -6,9 +6,14 @@ import (
 	"path/filepath"
 )
 
+// logPath is the directory of the log file
+var logPath = "C:\\PriorityQueue"
+
+// logFile is the file the logger writes to
+var logFile *os.File
+
 // This method is called from main.go
 func initLogger() *log.Logger {
-	logPath := "C:\\PriorityQueue"
 	fileName := "logs.txt"
 	if _, err := os.Stat(logPath); os.IsNotExist(err) {
 		os.Mkdir(logPath, os.ModeDir)
@@ -19,6 +24,7 @@ func initLogger() *log.Logger {
 	if err != nil {
 		log.Fatal(err)
 	}
+	logFile = file
 
 	logger := log.New(file, "", log.Ldate|log.Ltime|log.Lshortfile)
 
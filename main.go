@@ -4,8 +4,11 @@ package main
 import (
 	"fmt"
 	"math/rand"
+	"os"
 	"strconv"
 	"time"
+
+	"github.com/umerf52/ExpertFlow-Programming-Assignment/priorityqueue"
 )
 
 // Globals
@@ -15,28 +18,68 @@ var SIZE = 50000
 
 // PQ is the priority queue
 var PQ = PriorityQueue{
-	queueName:        "DefaultQueue",
-	queueDescription: "This queue is for demonstration of Priority Queue implementation",
-	capacity:         SIZE,
-	key:              0,
-	count:            0,
-	isInitialized:    false}
+	queue: priorityqueue.New(
+		priorityqueue.WithName("DefaultQueue"),
+		priorityqueue.WithDescription("This queue is for demonstration of Priority Queue implementation"),
+		priorityqueue.WithCapacity(SIZE),
+		priorityqueue.WithLogger(logger))}
 var logger = initLogger()
 
 // This example creates a Queue with some customerRequests, adds and manipulates an customerRequest,
 // and then removes the customerRequests in PriorityWeight order.
 func main() {
 	logger.Println("logger started")
+	if err := loadAuthConfig(os.Getenv("PQ_API_KEYS"), os.Getenv("PQ_JWT_SECRET")); err != nil {
+		logger.Fatal(err)
+	}
+	shares, err := parseAccountShares(os.Getenv("PQ_ACCOUNT_SHARES"))
+	if err != nil {
+		logger.Fatal(err)
+	}
+	ACCOUNTSHARES = shares
+	if ordering := os.Getenv("PQ_ORDERING"); ordering != "" {
+		if _, err := setOrdering(&PQ, ordering, false); err != nil {
+			logger.Fatal(err)
+		}
+	}
+	if policy := os.Getenv("PQ_OVERFLOW_POLICY"); policy != "" {
+		if err := setOverflowPolicy(&PQ, policy); err != nil {
+			logger.Fatal(err)
+		}
+	}
+	if policy := os.Getenv("PQ_DUPLICATE_POLICY"); policy != "" {
+		if err := setDuplicatePolicy(&PQ, policy); err != nil {
+			logger.Fatal(err)
+		}
+	}
+	quotas, err := parseQuotas(os.Getenv("PQ_RATE_LIMIT"), os.Getenv("PQ_RATE_BURST"), os.Getenv("PQ_MAX_OUTSTANDING"))
+	if err == nil {
+		_, err = setQuotas(&PQ, quotas)
+	}
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if delay := os.Getenv("PQ_IDEMPOTENCY_FLUSH_DELAY"); delay != "" {
+		d, err := time.ParseDuration(delay)
+		if err != nil || d < 0 {
+			logger.Fatal("PQ_IDEMPOTENCY_FLUSH_DELAY must be a duration like 100ms")
+		}
+		IDEMPOTENCYFLUSHDELAY = d
+	}
+	if err := loadCapacity(&PQ); err != nil {
+		logger.Printf("error loading capacity. %s", err.Error())
+	}
 	logger.Println("making database with dummy data")
 
 	// Make a slice of random integers from range 1 to 10
+	size := PQ.queue.Capacity()
 	priorities := make([]int, 0)
-	for i := 0; i < SIZE; i++ {
+	for i := 0; i < size; i++ {
 		priorities = append(priorities, rand.Intn(10)+1)
 	}
 
 	// Insert requests in the queue
-	for i := 0; i < SIZE; i++ {
+	for i := 0; i < size; i++ {
 		cr := &CustomerRequest{
 			PriorityWeight: priorities[i],
 			CustomerName:   "name" + strconv.Itoa(i),
@@ -45,9 +88,20 @@ func main() {
 		}
 		_ = insert(&PQ, cr, true)
 	}
+	if err := loadIdempotencyKeys(&IK, &PQ); err != nil {
+		logger.Printf("error loading idempotency keys. %s", err.Error())
+	}
+	startWorker(func(stop <-chan struct{}) { runIdempotencyFlusher(&IK, IDEMPOTENCYFLUSHDELAY, time.Minute, stop) })
+	startupComplete.Store(true)
 
 	// Start server to listen for REST API requests
-	go handleRequests()
+	server := newServer(":10000")
+	go handleRequests(server)
+	go handleSignals(server)
+	// Start timers to promote scheduled requests and abandon expired ones
+	startWorker(func(stop <-chan struct{}) { runTimers(&PQ, time.Second, stop) })
+	// Start dispatcher to offer requests to idle agents
+	startWorker(func(stop <-chan struct{}) { runDispatcher(DP, 200*time.Millisecond, stop) })
 
 	printHeader()
 	logger.Println("entering selection mode")
@@ -62,7 +116,13 @@ func main() {
 		case "2":
 			_ = selection2(&PQ, true)
 		case "3":
-			selection3(&PQ, true)
+			fmt.Printf("Agent ID (leave empty for any): ")
+			agentID := getInput()
+			if agentID == "" {
+				selection3(&PQ, nil, true)
+			} else {
+				serviceForAgent(&AR, &PQ, agentID, true)
+			}
 		case "4":
 			fmt.Println("Please enter following information: ")
 			fmt.Printf("Customer Name: ")
@@ -72,23 +132,43 @@ func main() {
 			fmt.Printf("Priority Weight: ")
 			priorityStr := getInput()
 			priorityInt, _ := strconv.Atoi(priorityStr)
+			fmt.Printf("TTL in seconds (leave empty for none): ")
+			ttlStr := getInput()
+			ttl, _ := strconv.ParseFloat(ttlStr, 64)
+			fmt.Printf("Schedule after seconds (leave empty for now): ")
+			delayStr := getInput()
+			delay, _ := strconv.ParseFloat(delayStr, 64)
 			cr := &CustomerRequest{
 				PriorityWeight: priorityInt,
 				CustomerName:   name,
 				Description:    desc,
 				EnqueueTime:    time.Now(),
+				TTLInSec:       ttl,
 			}
-			_, _ = selection4(&PQ, cr, true)
+			if delay > 0 {
+				notBefore := cr.EnqueueTime.Add(time.Duration(delay * float64(time.Second)))
+				cr.NotBefore = &notBefore
+			}
+			_, _ = selection4(&PQ, cr, "", true)
 		case "5":
 			fmt.Printf("Please enter customer ID: ")
 			tempStr := getInput()
 			delID, _ := strconv.Atoi(tempStr)
 			_, _ = selection5(&PQ, delID, true)
 		case "6":
-			selection6(&PQ, true)
+			selection6(&PQ, &AR, true)
+		case "7":
+			fmt.Printf("Bucket (day or week, leave empty for day): ")
+			bucket := getInput()
+			fmt.Printf("Group by (priorityWeight or queue, leave empty for none): ")
+			groupBy := getInput()
+			_, _ = selection7(&PQ, bucket, groupBy, true)
 		case "9":
 			printMenu()
 		case "0":
+			if err := gracefulShutdown(server, SHUTDOWNTIMEOUT); err != nil {
+				fmt.Println(err)
+			}
 			return
 		default:
 			fmt.Printf("Invalid selection/n")
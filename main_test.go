@@ -1,6 +1,13 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -9,54 +16,33 @@ import (
 func TestCreation(t *testing.T) {
 	name := "DefaultQueue"
 	desc := "This queue is for demonstration of Priority Queue implementation"
-	cap, k, cn := 10, 0, 0
-	isIn := false
-	pq := &PriorityQueue{
-		queueName:        name,
-		queueDescription: desc,
-		capacity:         cap,
-		key:              k,
-		count:            cn,
-		isInitialized:    isIn}
-
-	if pq == nil {
+	cap := 10
+	pq := newPriorityQueue(name, desc, cap)
+
+	if pq == nil || pq.queue == nil {
 		t.Errorf("Priority Queue creation failed")
 	}
 
-	if pq.queueName != name {
+	if pq.queue.Name() != name {
 		t.Errorf("Priority Queue creation failed. queueName does not match")
 	}
 
-	if pq.queueDescription != desc {
+	if pq.queue.Description() != desc {
 		t.Errorf("Priority Queue creation failed. queueDescription does not match")
 	}
 
-	if pq.capacity != cap {
+	if pq.queue.Capacity() != cap {
 		t.Errorf("Priority Queue creation failed. capacity does not match")
 	}
 
-	if pq.key != k {
-		t.Errorf("Priority Queue creation failed. key does not match")
-	}
-
-	if pq.count != cn {
+	if pq.queue.Len() != 0 || occupancy(pq) != 0 {
 		t.Errorf("Priority Queue creation failed. count does not match")
 	}
-
-	if pq.isInitialized != isIn {
-		t.Errorf("Priority Queue creation failed. isIntialized does not match")
-	}
 }
 
 // This test checks for insertion in Priority Queue
 func TestInsert(t *testing.T) {
-	pq := &PriorityQueue{
-		queueName:        "DefaultQueue",
-		queueDescription: "This queue is for demonstration of Priority Queue implementation",
-		capacity:         1,
-		key:              0,
-		count:            0,
-		isInitialized:    false}
+	pq := newPriorityQueue("DefaultQueue", "This queue is for demonstration of Priority Queue implementation", 1)
 
 	cr := &CustomerRequest{
 		PriorityWeight: 10,
@@ -66,26 +52,20 @@ func TestInsert(t *testing.T) {
 	}
 	result := insert(pq, cr, false)
 
-	if len(pq.harr) != pq.count || pq.count != 1 || !result {
+	if len(pq.queue.Items()) != pq.queue.Len() || pq.queue.Len() != 1 || !result {
 		t.Errorf("Insertion failed. count and heap array length do no match")
 	}
 
 	result = insert(pq, cr, false)
 
-	if pq.count > 1 || result {
+	if pq.queue.Len() > 1 || result {
 		t.Errorf("Heap array length exceeded")
 	}
 }
 
 // This test checks for removing items from PriorityQueue
 func TestRemoval(t *testing.T) {
-	pq := &PriorityQueue{
-		queueName:        "DefaultQueue",
-		queueDescription: "This queue is for demonstration of Priority Queue implementation",
-		capacity:         2,
-		key:              0,
-		count:            0,
-		isInitialized:    false}
+	pq := newPriorityQueue("DefaultQueue", "This queue is for demonstration of Priority Queue implementation", 2)
 
 	firstName := "first"
 	firstDesc := "this is first customer"
@@ -133,3 +113,852 @@ func TestRemoval(t *testing.T) {
 		t.Errorf("deleteCrById() failed. Received unxexpected value")
 	}
 }
+
+// This test checks that expired requests are abandoned and the rest are kept
+func TestReapExpired(t *testing.T) {
+	pq := newPriorityQueue("DefaultQueue", "This queue is for demonstration of Priority Queue implementation", 3)
+
+	now := time.Now()
+	_ = insert(pq, &CustomerRequest{PriorityWeight: 5, CustomerName: "short", EnqueueTime: now, TTLInSec: 1}, false)
+	_ = insert(pq, &CustomerRequest{PriorityWeight: 7, CustomerName: "none", EnqueueTime: now}, false)
+	_ = insert(pq, &CustomerRequest{PriorityWeight: 9, CustomerName: "long", EnqueueTime: now, TTLInSec: 60}, false)
+
+	expired := reapExpired(pq, now.Add(2*time.Second))
+	if len(expired) != 1 || expired[0].CustomerName != "short" {
+		t.Errorf("reapExpired() failed. Expected only the short lived request to expire")
+	}
+
+	if pq.queue.Len() != 2 || len(pq.queue.Items()) != 2 || pq.expiredCount != 1 {
+		t.Errorf("reapExpired() failed. Queue bookkeeping does not match")
+	}
+
+	last := pq.events[len(pq.events)-1]
+	if last.Type != "ABANDONED" || last.Reason != "EXPIRED" || last.ID != expired[0].ID {
+		t.Errorf("reapExpired() failed. Expected EXPIRED event")
+	}
+
+	if cr := extractMax(pq); cr.CustomerName != "long" || len(reapExpired(pq, now.Add(time.Hour))) != 0 {
+		t.Errorf("extractMax() failed. Deadline was not removed")
+	}
+}
+
+// This test checks that scheduled requests reserve capacity and are promoted when due
+func TestScheduledPromotion(t *testing.T) {
+	pq := newPriorityQueue("DefaultQueue", "This queue is for demonstration of Priority Queue implementation", 2)
+
+	now := time.Now()
+	notBefore := now.Add(time.Hour)
+	_ = insert(pq, &CustomerRequest{PriorityWeight: 5, CustomerName: "callback", EnqueueTime: now, NotBefore: &notBefore}, false)
+	_ = insert(pq, &CustomerRequest{PriorityWeight: 7, CustomerName: "now", EnqueueTime: now}, false)
+
+	if pq.queue.Len() != 1 || pq.queue.ScheduledLen() != 1 {
+		t.Errorf("insert() failed. Scheduled request should not be in the heap")
+	}
+
+	if insert(pq, &CustomerRequest{PriorityWeight: 1, CustomerName: "extra", EnqueueTime: now}, false) {
+		t.Errorf("insert() failed. Scheduled request should reserve its slot")
+	}
+
+	if promoted := promoteDue(pq, now.Add(time.Minute)); len(promoted) != 0 {
+		t.Errorf("promoteDue() failed. Request promoted before it was due")
+	}
+
+	promoted := promoteDue(pq, notBefore)
+	if len(promoted) != 1 || pq.queue.Len() != 2 || pq.queue.ScheduledLen() != 0 || !promoted[0].EnqueueTime.Equal(notBefore) {
+		t.Errorf("promoteDue() failed. Request was not promoted when due")
+	}
+}
+
+// This test checks that an agent is only given requests matching its skills
+func TestSkillsRouting(t *testing.T) {
+	pq := newPriorityQueue("DefaultQueue", "This queue is for demonstration of Priority Queue implementation", 3)
+
+	now := time.Now()
+	_ = insert(pq, &CustomerRequest{PriorityWeight: 10, CustomerName: "spanish", EnqueueTime: now, RequiredSkills: map[string]int{"lang:es": 5}}, false)
+	_ = insert(pq, &CustomerRequest{PriorityWeight: 4, CustomerName: "billing", EnqueueTime: now, RequiredSkills: map[string]int{"product:billing": 0}}, false)
+	_ = insert(pq, &CustomerRequest{PriorityWeight: 6, CustomerName: "anyone", EnqueueTime: now}, false)
+
+	agent := &Agent{ID: "a1", Skills: map[string]int{"lang:es": 3, "product:billing": 8}}
+	s3Struct, _, err := selection3(pq, agent, false)
+	if err != nil || s3Struct.CustomerName != "anyone" {
+		t.Errorf("selection3() failed. Expected highest priority request matching skills")
+	}
+
+	s3Struct, _, err = selection3(pq, agent, false)
+	if err != nil || s3Struct.CustomerName != "billing" {
+		t.Errorf("selection3() failed. Expected request needing billing")
+	}
+
+	if _, _, err = selection3(pq, agent, false); err == nil || pq.queue.Len() != 1 {
+		t.Errorf("selection3() failed. Agent is not proficient enough in spanish")
+	}
+}
+
+// This test checks that servicing by an agent records the assignment and the agent becomes BUSY
+func TestServiceForAgent(t *testing.T) {
+	pq := newPriorityQueue("DefaultQueue", "This queue is for demonstration of Priority Queue implementation", 2)
+	ar := &AgentRegistry{agents: make(map[string]*Agent)}
+
+	_ = insert(pq, &CustomerRequest{PriorityWeight: 3, CustomerName: "first", EnqueueTime: time.Now()}, false)
+	_ = insert(pq, &CustomerRequest{PriorityWeight: 2, CustomerName: "second", EnqueueTime: time.Now()}, false)
+	if err := registerAgent(ar, &Agent{ID: "a1", Name: "Agent One"}); err != nil {
+		t.Fatalf("registerAgent() failed. %s", err)
+	}
+
+	s3Struct, _, err := serviceForAgent(ar, pq, "a1", false)
+	if err != nil || s3Struct.AgentID != "a1" || s3Struct.CustomerName != "first" {
+		t.Errorf("serviceForAgent() failed. Serviced request does not record the agent")
+	}
+
+	agent, _ := getAgentByID(ar, "a1")
+	if agent.State != BUSY || agent.Assignment == nil || agent.Assignment.RequestID != s3Struct.ID {
+		t.Errorf("serviceForAgent() failed. Agent should be BUSY with the assignment")
+	}
+
+	if _, _, err = serviceForAgent(ar, pq, "a1", false); err == nil {
+		t.Errorf("serviceForAgent() failed. BUSY agent should not be given another request")
+	}
+
+	if info := countAgents(ar); info.Busy != 1 || info.Available != 0 {
+		t.Errorf("countAgents() failed. Expected one BUSY agent")
+	}
+
+	agent, _ = setAgentState(ar, "a1", AVAILABLE)
+	if agent.Assignment != nil {
+		t.Errorf("setAgentState() failed. Assignment should be cleared when AVAILABLE")
+	}
+}
+
+// This test checks the service level metrics of the sliding windows
+func TestComputeStats(t *testing.T) {
+	pq := newPriorityQueue("DefaultQueue", "", 1)
+	now := time.Now()
+	recordSample(pq, now.Add(-25*time.Hour), 1, false) // dropped, older than a day
+	recordSample(pq, now.Add(-2*time.Hour), 50, false)
+	recordSample(pq, now.Add(-30*time.Minute), 5, true) // abandoned within threshold
+	recordSample(pq, now.Add(-time.Minute), 10, false)
+	recordSample(pq, now.Add(-time.Minute), 30, true)
+	recordSample(pq, now, 15, false)
+
+	if pq.samples.len() != 5 {
+		t.Errorf("recordSample() failed. Old samples were not dropped")
+	}
+
+	windows := computeStats(pq, now, 20)
+	fiveMin, day := windows[0], windows[2]
+	if fiveMin.ServicedCount != 2 || fiveMin.AbandonedCount != 1 || fiveMin.AverageSpeedOfAnswerInSec != 12.5 {
+		t.Errorf("computeStats() failed. Unexpected 5m counts %+v", fiveMin)
+	}
+	// 2 answered in time out of 2 answered and 1 abandoned after the threshold
+	if math.Abs(fiveMin.ServiceLevelPercent-200.0/3) > 1e-9 || fiveMin.WaitP90InSec != 15 {
+		t.Errorf("computeStats() failed. Unexpected 5m service level %+v", fiveMin)
+	}
+	// 2 answered in time out of 3 answered and 1 abandoned after the threshold
+	if day.ServicedCount != 3 || day.ServiceLevelPercent != 50 || day.AbandonmentRatePercent != 40 {
+		t.Errorf("computeStats() failed. Unexpected day window %+v", day)
+	}
+}
+
+// This test checks that the metrics kept up to date between computations match those computed from
+// scratch, that dropped samples are cut off and that samples stay in time order
+func TestComputeStatsIncremental(t *testing.T) {
+	pq := newPriorityQueue("DefaultQueue", "", 1)
+	start := time.Now().Add(-48 * time.Hour)
+	for i := 0; i < 3000; i++ {
+		at := start.Add(time.Duration(i) * time.Minute)
+		recordSample(pq, at, float64((i*37)%60), i%7 == 0)
+		if i%5 != 0 {
+			continue
+		}
+		got := computeStats(pq, at, 20)
+		pq.statCache = nil
+		want := computeStats(pq, at, 20)
+		for w := range want {
+			if math.Abs(got[w].AverageSpeedOfAnswerInSec-want[w].AverageSpeedOfAnswerInSec) > 1e-6 {
+				t.Fatalf("computeStats() failed. Average %f instead of %f at sample %d", got[w].AverageSpeedOfAnswerInSec, want[w].AverageSpeedOfAnswerInSec, i)
+			}
+			got[w].AverageSpeedOfAnswerInSec = want[w].AverageSpeedOfAnswerInSec
+			if got[w] != want[w] {
+				t.Fatalf("computeStats() failed. %+v instead of %+v at sample %d", got[w], want[w], i)
+			}
+		}
+	}
+	if pq.samples.len() != 24*60 || len(pq.samples.ring) != 2048 {
+		t.Errorf("recordSample() failed. %d samples kept in a ring of %d", pq.samples.len(), len(pq.samples.ring))
+	}
+
+	// a sample taken before the last one is kept in time order
+	last := start.Add(2999 * time.Minute)
+	recordSample(pq, last.Add(-time.Hour), 1, false)
+	if got := computeStats(pq, last, 20)[0]; got.ServicedCount != 5 {
+		t.Errorf("recordSample() failed. Expected the late sample in the 5m window, got %+v", got)
+	}
+}
+
+// This test checks that every metric line follows the Prometheus text format
+func TestQueueMetrics(t *testing.T) {
+	pq := newPriorityQueue("Default \"Queue\"", "", 4)
+	now := time.Now()
+	_ = insert(pq, &CustomerRequest{PriorityWeight: 2, CustomerName: "a", EnqueueTime: now}, false)
+	_ = insert(pq, &CustomerRequest{PriorityWeight: 2, CustomerName: "b", EnqueueTime: now}, false)
+	_ = insert(pq, &CustomerRequest{PriorityWeight: 7, CustomerName: "c", EnqueueTime: now}, false)
+	_, _, _ = selection3(pq, nil, false)
+
+	var b strings.Builder
+	writeQueueMetrics(&b, pq)
+	out := b.String()
+
+	sample := regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*(\{([a-zA-Z_][a-zA-Z0-9_]*="([^"\\]|\\.)*",?)*\})? -?[0-9.e+Inf-]+$`)
+	comment := regexp.MustCompile(`^# (HELP|TYPE) [a-zA-Z_:][a-zA-Z0-9_:]* .+$`)
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if !sample.MatchString(line) && !comment.MatchString(line) {
+			t.Errorf("writeQueueMetrics() failed. Invalid line %q", line)
+		}
+	}
+
+	expected := []string{
+		`priority_queue_depth{queue="Default \"Queue\"",priority_weight="2"} 2`,
+		`priority_queue_capacity_utilization_ratio{queue="Default \"Queue\""} 0.5`,
+		`priority_queue_enqueued_total{queue="Default \"Queue\""} 3`,
+		`priority_queue_serviced_total{queue="Default \"Queue\""} 1`,
+		`priority_queue_wait_seconds_bucket{queue="Default \"Queue\"",le="+Inf"} 1`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(out, e+"\n") {
+			t.Errorf("writeQueueMetrics() failed. Missing %q", e)
+		}
+	}
+}
+
+// This test checks that system information of an empty queue is not an error
+func TestSystemInfoEmptyQueue(t *testing.T) {
+	pq := newPriorityQueue("DefaultQueue", "", 1)
+	ar := &AgentRegistry{agents: make(map[string]*Agent)}
+
+	s6Struct, _, err := selection6(pq, ar, false)
+	if err != nil || s6Struct.Status != "IN_SERVICE" || s6Struct.Queue.Size != "0" {
+		t.Errorf("selection6() failed. Empty queue should be IN_SERVICE")
+	}
+
+	shuttingDown.Store(true)
+	defer shuttingDown.Store(false)
+	if readiness := checkReadiness(); readiness.Status != "NOT_READY" || readiness.Checks["shutdown"] == "OK" {
+		t.Errorf("checkReadiness() failed. Instance shutting down should not be ready")
+	}
+}
+
+// This test checks the rate limit and the outstanding requests quota of clients
+func TestTakeQuota(t *testing.T) {
+	pq := newPriorityQueue("DefaultQueue", "", 10)
+	pq.rateLimit, pq.rateBurst, pq.maxOutstanding = 1, 2, 2
+	now := time.Now()
+
+	if code, _ := takeQuota(pq, "c1", now); code != "" {
+		t.Errorf("takeQuota() failed. First enqueue should be allowed")
+	}
+	_ = enqueue(pq, &CustomerRequest{PriorityWeight: 1, CustomerName: "c1", EnqueueTime: now}, "c1", false)
+	if code, _ := takeQuota(pq, "c1", now); code != "" {
+		t.Errorf("takeQuota() failed. Burst should allow a second enqueue")
+	}
+	if code, retryAfter := takeQuota(pq, "c1", now); code != "RATE_LIMITED" || retryAfter != 1 {
+		t.Errorf("takeQuota() failed. Expected RATE_LIMITED with Retry-After 1, got %s %d", code, retryAfter)
+	}
+	if code, _ := takeQuota(pq, "c2", now); code != "" {
+		t.Errorf("takeQuota() failed. Other clients should not be limited")
+	}
+
+	_ = enqueue(pq, &CustomerRequest{PriorityWeight: 1, CustomerName: "c1", EnqueueTime: now}, "c1", false)
+	if code, _ := takeQuota(pq, "c1", now.Add(time.Minute)); code != "QUOTA_EXCEEDED" {
+		t.Errorf("takeQuota() failed. Expected QUOTA_EXCEEDED with 2 outstanding requests")
+	}
+	_, _, _ = selection3(pq, nil, false)
+	if code, _ := takeQuota(pq, "c1", now.Add(time.Minute)); code != "" || pq.outstanding["c1"] != 1 {
+		t.Errorf("takeQuota() failed. Serviced request should release the quota")
+	}
+}
+
+// This test checks that quotas are off unless they are configured
+func TestSetQuotas(t *testing.T) {
+	pq := newPriorityQueue("DefaultQueue", "", 10)
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		if code, _ := takeQuota(pq, "c1", now); code != "" {
+			t.Fatalf("takeQuota() failed. Expected no limit by default, got %s", code)
+		}
+	}
+	for _, bad := range [][3]string{{"x", "", ""}, {"", "x", ""}, {"", "", "1.5"}} {
+		if _, err := parseQuotas(bad[0], bad[1], bad[2]); err == nil {
+			t.Errorf("parseQuotas() failed. Expected an error for %q", bad)
+		}
+	}
+	quotas, err := parseQuotas("0.5", "", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quotas, err = setQuotas(pq, quotas); err != nil || quotas.RateBurst != 1 {
+		t.Fatalf("setQuotas() failed. Expected a burst of 1, got %+v %v", quotas, err)
+	}
+	if code, _ := takeQuota(pq, "c1", now); code != "" {
+		t.Errorf("takeQuota() failed. First enqueue should be allowed")
+	}
+	if code, retryAfter := takeQuota(pq, "c1", now); code != "RATE_LIMITED" || retryAfter != 2 {
+		t.Errorf("takeQuota() failed. Expected RATE_LIMITED with Retry-After 2, got %s %d", code, retryAfter)
+	}
+	_ = enqueue(pq, &CustomerRequest{PriorityWeight: 1, CustomerName: "c2", EnqueueTime: now}, "c2", false)
+	if code, _ := takeQuota(pq, "c2", now); code != "QUOTA_EXCEEDED" {
+		t.Errorf("takeQuota() failed. Expected QUOTA_EXCEEDED, got %s", code)
+	}
+	if _, err := setQuotas(pq, QuotasJSON{MaxOutstanding: -1}); err == nil {
+		t.Errorf("setQuotas() failed. Expected an error for a negative quota")
+	}
+	if _, err := setQuotas(pq, QuotasJSON{}); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := takeQuota(pq, "c2", now); code != "" {
+		t.Errorf("takeQuota() failed. Expected quotas to be turned off, got %s", code)
+	}
+}
+
+// This test checks that a retried enqueue returns the original response and survives a restart
+func TestIdempotentEnqueue(t *testing.T) {
+	router := newRouter()
+	body := `{"customerName":"ivr","description":"retry","priorityWeight":4}`
+	headers := map[string]string{"Idempotency-Key": "call-1234"}
+
+	first := doRequest(router, "POST", "/api/v1.0/queue/enqueue", body, headers)
+	second := doRequest(router, "POST", "/api/v1.0/queue/enqueue", body, headers)
+	if first.Code != 200 || second.Code != 200 || first.Body.String() != second.Body.String() {
+		t.Fatalf("api4() failed. Retry should return the original response")
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("api4() failed. Retry should be marked as replayed")
+	}
+	if reused := doRequest(router, "POST", "/api/v1.0/queue/enqueue", `{"customerName":"other","priorityWeight":1}`, headers); reused.Code != 422 {
+		t.Errorf("api4() failed. Key reused for another request should be refused, got %d", reused.Code)
+	}
+
+	if err := saveIdempotencyKeys(&IK, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	IK.entries = make(map[string]IdempotencyEntry)
+	if err := loadIdempotencyKeys(&IK, &PQ); err != nil {
+		t.Fatal(err)
+	}
+	if third := doRequest(router, "POST", "/api/v1.0/queue/enqueue", body, headers); third.Body.String() != first.Body.String() {
+		t.Errorf("loadIdempotencyKeys() failed. Key was not persisted")
+	}
+}
+
+// This test checks that concurrent retries make one enqueue and that a key whose request is gone is
+// dropped when the keys are loaded
+func TestIdempotencyKeyInFlight(t *testing.T) {
+	router := newRouter()
+	body := `{"customerName":"ivr","description":"in flight","priorityWeight":4}`
+	headers := map[string]string{"Idempotency-Key": "call-5678"}
+	bodies := make(chan string, 8)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bodies <- doRequest(router, "POST", "/api/v1.0/queue/enqueue", body, headers).Body.String()
+		}()
+	}
+	wg.Wait()
+	close(bodies)
+	first := <-bodies
+	for b := range bodies {
+		if b != first {
+			t.Fatalf("api4() failed. Concurrent retries should return the same response, got %s and %s", first, b)
+		}
+	}
+
+	var s4Struct Selection4Struct
+	if err := json.Unmarshal([]byte(first), &s4Struct); err != nil {
+		t.Fatal(err)
+	}
+	if rr := doRequest(router, "DELETE", "/api/v1.0/queue/requests/"+s4Struct.Token, "", nil); rr.Code != 200 {
+		t.Fatalf("apiRenegeByToken() failed. Expected 200, got %d", rr.Code)
+	}
+	if err := saveIdempotencyKeys(&IK, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadIdempotencyKeys(&IK, &PQ); err != nil {
+		t.Fatal(err)
+	}
+	if retry := doRequest(router, "POST", "/api/v1.0/queue/enqueue", body, headers); retry.Header().Get("Idempotent-Replayed") == "true" {
+		t.Errorf("loadIdempotencyKeys() failed. Key of a reneged request should be dropped")
+	}
+}
+
+// This test checks that a stored idempotency key is persisted within the flush delay
+func TestIdempotencyFlusher(t *testing.T) {
+	ik := IdempotencyStore{entries: make(map[string]IdempotencyEntry), written: make(chan struct{}, 1)}
+	stop := make(chan struct{})
+	defer close(stop)
+	go runIdempotencyFlusher(&ik, 10*time.Millisecond, time.Hour, stop)
+
+	ik.mutex.Lock()
+	storeIdempotencyKey(&ik, "c1|key:flush", IdempotencyEntry{CreatedAt: time.Now()})
+	ik.mutex.Unlock()
+	for i := 0; i < 100; i++ {
+		entries := make(map[string]IdempotencyEntry)
+		if err := loadJSON(IDEMPOTENCYFILE, &entries); err == nil {
+			if _, ok := entries["c1|key:flush"]; ok {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("runIdempotencyFlusher() failed. Stored key was not persisted")
+}
+
+// This test checks that the ordering of a live queue can be switched and is reported in SystemInfo
+func TestSetOrdering(t *testing.T) {
+	pq := newPriorityQueue("DefaultQueue", "", 10)
+	now := time.Now()
+	soon, later := now.Add(time.Minute), now.Add(time.Hour)
+	_ = insert(pq, &CustomerRequest{CustomerName: "late", PriorityWeight: 9, EnqueueTime: now, Deadline: &later}, false)
+	_ = insert(pq, &CustomerRequest{CustomerName: "soon", PriorityWeight: 2, EnqueueTime: now, Deadline: &soon}, false)
+	ar := &AgentRegistry{agents: make(map[string]*Agent)}
+	if s6Struct, _, _ := selection6(pq, ar, false); s6Struct.Queue.Ordering != "PRIORITY" {
+		t.Errorf("selection6() failed. Expected PRIORITY, got %s", s6Struct.Queue.Ordering)
+	}
+
+	if _, err := setOrdering(pq, "RANDOM", false); err == nil {
+		t.Errorf("setOrdering() failed. Expected an error for an unknown ordering")
+	}
+	if result, err := setOrdering(pq, "EDF", false); err != nil || result.Ordering != "EDF" {
+		t.Fatalf("setOrdering() failed. %+v %v", result, err)
+	}
+	if s6Struct, _, _ := selection6(pq, ar, false); s6Struct.Queue.Ordering != "EDF" || s6Struct.Queue.Size != "2" {
+		t.Errorf("selection6() failed. %+v", s6Struct.Queue)
+	}
+	if s3Struct, _, _ := selection3(pq, nil, false); s3Struct.CustomerName != "soon" {
+		t.Errorf("selection3() failed. Expected the earliest deadline first, got %s", s3Struct.CustomerName)
+	}
+}
+
+// This test checks that an account enqueueing many high weight requests cannot monopolize service
+func TestFairShareAccounts(t *testing.T) {
+	ACCOUNTSHARES = map[string]float64{"enterprise": 2}
+	defer func() { ACCOUNTSHARES = map[string]float64{} }()
+	pq := newPriorityQueue("DefaultQueue", "", 100)
+	if _, err := setOrdering(pq, "FAIR_SHARE", false); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 30; i++ {
+		_ = insert(pq, &CustomerRequest{CustomerName: "big", PriorityWeight: 10, Account: "enterprise", EnqueueTime: time.Now()}, false)
+	}
+	_ = insert(pq, &CustomerRequest{CustomerName: "small1", PriorityWeight: 1, Account: "smb", EnqueueTime: time.Now()}, false)
+	_ = insert(pq, &CustomerRequest{CustomerName: "small2", PriorityWeight: 2, Account: "smb", EnqueueTime: time.Now()}, false)
+
+	served := make([]string, 0)
+	for i := 0; i < 6; i++ {
+		s3Struct, _, _ := selection3(pq, nil, false)
+		served = append(served, s3Struct.CustomerName)
+	}
+	if strings.Join(served, ",") != "big,small2,big,big,small1,big" {
+		t.Errorf("selection3() failed. Expected smb to get every third turn, got %v", served)
+	}
+}
+
+// This test checks the parsing of account shares
+func TestParseAccountShares(t *testing.T) {
+	if shares, err := parseAccountShares("acme:3, globex:0.5"); err != nil || shares["acme"] != 3 || shares["globex"] != 0.5 {
+		t.Errorf("parseAccountShares() failed. %v %v", shares, err)
+	}
+	for _, config := range []string{"acme", "acme:0", "acme:x"} {
+		if _, err := parseAccountShares(config); err == nil {
+			t.Errorf("parseAccountShares() failed. Expected an error for %s", config)
+		}
+	}
+}
+
+// This test checks that a full queue evicts the lowest priority, newest request for one that outranks it
+func TestOverflowEvict(t *testing.T) {
+	pq := newPriorityQueue("DefaultQueue", "", 3)
+	if err := setOverflowPolicy(pq, "DROP"); err == nil {
+		t.Errorf("setOverflowPolicy() failed. Expected an error for an unknown policy")
+	}
+	if err := setOverflowPolicy(pq, EVICT); err != nil {
+		t.Fatal(err)
+	}
+	for _, weight := range []int{5, 2, 2} {
+		_ = insert(pq, &CustomerRequest{CustomerName: "old", PriorityWeight: weight, EnqueueTime: time.Now()}, false)
+	}
+	if _, err := selection4(pq, &CustomerRequest{CustomerName: "low", PriorityWeight: 2, EnqueueTime: time.Now()}, "", false); err == nil {
+		t.Errorf("selection4() failed. Expected a request that does not outrank the lowest to be rejected")
+	}
+	if _, err := selection4(pq, &CustomerRequest{CustomerName: "high", PriorityWeight: 9, EnqueueTime: time.Now()}, "", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pq.queue.Get(2); err == nil {
+		t.Errorf("insert() failed. Expected the newest request of weight 2 to be evicted")
+	}
+	events := listEvents(pq).Events
+	if evicted := events[len(events)-2]; evicted.Type != "EVICTED" || evicted.ID != 2 {
+		t.Errorf("insert() failed. Expected an EVICTED event, got %+v", evicted)
+	}
+	ar := &AgentRegistry{agents: make(map[string]*Agent)}
+	s6Struct, _, _ := selection6(pq, ar, false)
+	if s6Struct.Queue.EvictedCount != 1 || s6Struct.Queue.OverflowPolicy != EVICT {
+		t.Errorf("selection6() failed. %+v", s6Struct.Queue)
+	}
+	// evictions are not abandonment, the customer did not give up
+	if s6Struct.Stats[0].AbandonedCount != 0 || s6Struct.Queue.AbandonedCount != 0 {
+		t.Errorf("selection6() failed. Evictions should not count as abandoned, got %+v", s6Struct.Stats[0])
+	}
+}
+
+// This test checks that a duplicate of a full queue is refused as a duplicate instead of overflowing
+func TestDuplicateDoesNotOverflow(t *testing.T) {
+	pq := newPriorityQueue("DefaultQueue", "", 2)
+	if err := setOverflowPolicy(pq, EVICT); err != nil {
+		t.Fatal(err)
+	}
+	if err := setDuplicatePolicy(pq, REJECT); err != nil {
+		t.Fatal(err)
+	}
+	_ = insert(pq, &CustomerRequest{CustomerName: "a", PriorityWeight: 1, EnqueueTime: time.Now()}, false)
+	_ = insert(pq, &CustomerRequest{CustomerName: "b", PriorityWeight: 1, EnqueueTime: time.Now()}, false)
+	if err := enqueue(pq, &CustomerRequest{CustomerName: "b", PriorityWeight: 9, EnqueueTime: time.Now()}, "", false); err != errDuplicateCustomer {
+		t.Errorf("enqueue() failed. Expected errDuplicateCustomer, got %v", err)
+	}
+	if pq.evictedCount != 0 || pq.queue.Len() != 2 {
+		t.Errorf("enqueue() failed. Expected nothing to be evicted, evicted %d", pq.evictedCount)
+	}
+	if err := enqueue(pq, &CustomerRequest{CustomerName: "c", PriorityWeight: 9, EnqueueTime: time.Now()}, "", false); err != nil || pq.evictedCount != 1 {
+		t.Errorf("enqueue() failed. Expected a new customer to evict, got %v", err)
+	}
+	if err := enqueue(pq, &CustomerRequest{CustomerName: "d", PriorityWeight: 1, EnqueueTime: time.Now()}, "", false); err != errCapacityReached {
+		t.Errorf("enqueue() failed. Expected errCapacityReached, got %v", err)
+	}
+}
+
+// This test checks that requests overflowing into the buffer or overflow queue take the slots that free up
+func TestOverflowSpillBuffer(t *testing.T) {
+	for _, policy := range []string{SPILL, BUFFER} {
+		pq := newPriorityQueue("DefaultQueue", "", 1)
+		if err := setOverflowPolicy(pq, policy); err != nil {
+			t.Fatal(err)
+		}
+		for _, weight := range []int{1, 3, 4, 9} {
+			if _, err := selection4(pq, &CustomerRequest{CustomerName: strconv.Itoa(weight), PriorityWeight: weight, EnqueueTime: time.Now()}, "", false); err != nil {
+				t.Fatal(err)
+			}
+		}
+		s4Struct, _ := selection4(pq, &CustomerRequest{CustomerName: "5", PriorityWeight: 5, EnqueueTime: time.Now()}, "", false)
+		if s4Struct.Overflow != policy || s4Struct.PositionInQueue != -1 || s4Struct.ID != 4 {
+			t.Errorf("selection4() failed. %+v", s4Struct)
+		}
+		if _, err := selection5(pq, 2, false); err != nil {
+			t.Errorf("selection5() failed. Expected overflowed requests to renege, %v", err)
+		}
+
+		served := make([]string, 0)
+		for i := 0; i < 4; i++ {
+			s3Struct, _, _ := selection3(pq, nil, false)
+			served = append(served, s3Struct.CustomerName)
+		}
+		expected := map[string]string{SPILL: "1,9,5,3", BUFFER: "1,3,9,5"}[policy]
+		if strings.Join(served, ",") != expected {
+			t.Errorf("selection3() failed with %s. Expected %s, got %v", policy, expected, served)
+		}
+		if pq.queue.Len() != 0 || len(pq.buffer) != 0 || spilledLen(pq) != 0 {
+			t.Errorf("refill() failed with %s. Expected every request to be serviced", policy)
+		}
+	}
+}
+
+// This test checks resizing the queue at runtime with the overflow policies and that the capacity is persisted
+func TestSetCapacity(t *testing.T) {
+	defer func(path string) { logPath = path }(logPath)
+	logPath = t.TempDir()
+	pq := newPriorityQueue("DefaultQueue", "", 4)
+	ar := &AgentRegistry{agents: make(map[string]*Agent)}
+	for _, weight := range []int{5, 1, 3, 2} {
+		_ = insert(pq, &CustomerRequest{CustomerName: strconv.Itoa(weight), PriorityWeight: weight, EnqueueTime: time.Now()}, false)
+	}
+	if s6Struct, _, _ := selection6(pq, ar, false); s6Struct.Status != "MAX_CAPACITY_REACHED" {
+		t.Errorf("selection6() failed. Expected MAX_CAPACITY_REACHED, got %s", s6Struct.Status)
+	}
+	if _, err := setCapacity(pq, 0, false); err == nil || err == errCapacityRefused {
+		t.Errorf("setCapacity() failed. Expected an invalid capacity, got %v", err)
+	}
+	if _, err := setCapacity(pq, 3, false); err != errCapacityRefused {
+		t.Errorf("setCapacity() failed. Expected REJECT to refuse, got %v", err)
+	}
+	if result, err := setCapacity(pq, 6, false); err != nil || result.Capacity != 6 {
+		t.Fatalf("setCapacity() failed. %+v %v", result, err)
+	}
+	if s6Struct, _, _ := selection6(pq, ar, false); s6Struct.Status != "IN_SERVICE" {
+		t.Errorf("selection6() failed. Expected IN_SERVICE, got %s", s6Struct.Status)
+	}
+
+	if err := setOverflowPolicy(pq, BUFFER); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := setCapacity(pq, 2, false); err != nil {
+		t.Fatal(err)
+	}
+	if pq.queue.Len() != 2 || len(pq.buffer) != 2 || pq.buffer[0].PriorityWeight != 1 || pq.buffer[1].PriorityWeight != 2 {
+		t.Errorf("setCapacity() failed. Expected the lowest weights to be buffered, got %d waiting and %v", pq.queue.Len(), pq.buffer)
+	}
+	if _, err := setCapacity(pq, 3, false); err != nil || pq.queue.Len() != 3 || len(pq.buffer) != 1 {
+		t.Errorf("setCapacity() failed. Expected one buffered request to be refilled, %v", err)
+	}
+	if err := setOverflowPolicy(pq, EVICT); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := setCapacity(pq, 1, false); err != nil || pq.queue.Len() != 1 || pq.evictedCount != 2 {
+		t.Errorf("setCapacity() failed. Expected two requests to be evicted, %v", err)
+	}
+	if cr, err := pq.queue.Peek(); err != nil || cr.PriorityWeight != 5 {
+		t.Errorf("setCapacity() failed. Expected the highest weight to stay, got %+v", cr)
+	}
+
+	restarted := newPriorityQueue("DefaultQueue", "", 4)
+	if err := loadCapacity(restarted); err != nil || restarted.queue.Capacity() != 1 {
+		t.Errorf("loadCapacity() failed. Expected the persisted capacity 1, got %d %v", restarted.queue.Capacity(), err)
+	}
+}
+
+// This test checks that a customer keeps one request in the queue with every duplicate policy
+func TestDuplicatePolicies(t *testing.T) {
+	pq := newPriorityQueue("DefaultQueue", "", 10)
+	if err := setDuplicatePolicy(pq, "IGNORE"); err == nil {
+		t.Errorf("setDuplicatePolicy() failed. Expected an error for an unknown policy")
+	}
+	if err := setDuplicatePolicy(pq, REJECT); err != nil {
+		t.Fatal(err)
+	}
+	earlier := time.Now().Add(-time.Minute)
+	_ = enqueue(pq, &CustomerRequest{CustomerName: "alice", PriorityWeight: 2, EnqueueTime: earlier}, "c1", false)
+	_ = enqueue(pq, &CustomerRequest{CustomerName: "bob", PriorityWeight: 5, EnqueueTime: time.Now()}, "c1", false)
+	if _, err := selection4(pq, &CustomerRequest{CustomerName: "alice", PriorityWeight: 9, EnqueueTime: time.Now()}, "c1", false); err != errDuplicateCustomer {
+		t.Errorf("selection4() failed. Expected errDuplicateCustomer, got %v", err)
+	}
+
+	if err := setDuplicatePolicy(pq, MERGE); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := selection4(pq, &CustomerRequest{CustomerName: "alice", PriorityWeight: 9, EnqueueTime: time.Now()}, "c2", false); err != errDuplicateCustomer {
+		t.Errorf("selection4() failed. Expected requests of other clients to be kept, got %v", err)
+	}
+	s4Struct, err := selection4(pq, &CustomerRequest{CustomerName: "alice", PriorityWeight: 9, EnqueueTime: time.Now()}, "c1", false)
+	if err != nil || s4Struct.ID != 0 || s4Struct.Deduplicated != MERGE || s4Struct.PriorityWeight != 9 || !s4Struct.EnqueueTime.Equal(earlier) || s4Struct.PositionInQueue != 0 {
+		t.Errorf("selection4() failed. Expected a merge into the earlier request, got %+v %v", s4Struct, err)
+	}
+	if pq.queue.Len() != 2 {
+		t.Errorf("selection4() failed. Expected 2 requests, got %d", pq.queue.Len())
+	}
+
+	if err := setDuplicatePolicy(pq, REPLACE); err != nil {
+		t.Fatal(err)
+	}
+	s4Struct, err = selection4(pq, &CustomerRequest{CustomerName: "bob", PriorityWeight: 1, EnqueueTime: time.Now()}, "c1", false)
+	if err != nil || s4Struct.Deduplicated != REPLACE {
+		t.Fatalf("selection4() failed. Expected a replacement, got %+v %v", s4Struct, err)
+	}
+	if _, err := pq.queue.Get(1); err == nil || pq.queue.Len() != 2 {
+		t.Errorf("selection4() failed. Expected the earlier request of bob to be dropped")
+	}
+	if events := listEvents(pq).Events; events[len(events)-2].Type != "REPLACED" || events[len(events)-2].ID != 1 {
+		t.Errorf("selection4() failed. Expected a REPLACED event, got %+v", events[len(events)-2])
+	}
+
+	// batches follow the duplicate policy like single enqueues
+	alice, _ := pq.queue.Find("alice")
+	bStruct := enqueueBatch(pq, []*CustomerRequest{{CustomerName: "carol", PriorityWeight: 3}, {CustomerName: "carol", PriorityWeight: 4},
+		{CustomerName: "alice", PriorityWeight: 4}}, BESTEFFORT, "c1")
+	if bStruct.Succeeded != 2 || bStruct.Results[1].Error == nil || bStruct.Results[1].Error.Error != "DUPLICATE_CUSTOMER" {
+		t.Fatalf("enqueueBatch() failed. Expected a duplicate within the batch to fail, got %+v", bStruct)
+	}
+	if s4Struct := bStruct.Results[2].Enqueued; s4Struct.Deduplicated != REPLACE || s4Struct.ID == alice.ID || pq.queue.Len() != 3 {
+		t.Errorf("enqueueBatch() failed. Expected the request of alice to be replaced, got %+v", s4Struct)
+	}
+	if _, err := pq.queue.Get(alice.ID); err == nil {
+		t.Errorf("enqueueBatch() failed. Expected the earlier request of alice to be dropped")
+	}
+
+	if err := setDuplicatePolicy(pq, MERGE); err != nil {
+		t.Fatal(err)
+	}
+	carol, _ := pq.queue.Find("carol")
+	bStruct = enqueueBatch(pq, []*CustomerRequest{{CustomerName: "carol", PriorityWeight: 8}, {CustomerName: "dave", PriorityWeight: 1}}, ALLORNOTHING, "c1")
+	if s4Struct := bStruct.Results[0].Enqueued; bStruct.Succeeded != 2 || s4Struct.Deduplicated != MERGE || s4Struct.ID != carol.ID || carol.PriorityWeight != 8 {
+		t.Errorf("enqueueBatch() failed. Expected a merge into the earlier request of carol, got %+v", bStruct)
+	}
+	if pq.queue.Len() != 4 || pq.outstanding["c1"] != 4 {
+		t.Errorf("enqueueBatch() failed. Expected only dave to be enqueued, got %d requests and %d outstanding", pq.queue.Len(), pq.outstanding["c1"])
+	}
+	if bStruct := enqueueBatch(pq, []*CustomerRequest{{CustomerName: "carol", PriorityWeight: 9}}, BESTEFFORT, "c2"); bStruct.Succeeded != 0 || carol.PriorityWeight != 8 {
+		t.Errorf("enqueueBatch() failed. Expected requests of other clients to be kept, got %+v", bStruct)
+	}
+}
+
+// This test checks looking up and reneging requests by their token and by customer
+func TestTokens(t *testing.T) {
+	pq := newPriorityQueue("DefaultQueue", "", 10)
+	later := time.Now().Add(time.Hour)
+	waiting, _ := selection4(pq, &CustomerRequest{CustomerName: "alice", PriorityWeight: 3, EnqueueTime: time.Now()}, "c1", false)
+	scheduled, _ := selection4(pq, &CustomerRequest{CustomerName: "alice", PriorityWeight: 3, EnqueueTime: time.Now(), NotBefore: &later}, "c1", false)
+	_, _ = selection4(pq, &CustomerRequest{CustomerName: "alice", PriorityWeight: 3, EnqueueTime: time.Now()}, "c2", false)
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(waiting.Token) || waiting.Token == scheduled.Token {
+		t.Errorf("selection4() failed. Expected a new UUID token, got %s and %s", waiting.Token, scheduled.Token)
+	}
+
+	if rsStruct, err := getByToken(pq, waiting.Token, false); err != nil || rsStruct.ID != 0 || rsStruct.Status != WAITING || rsStruct.PositionInQueue != 0 {
+		t.Errorf("getByToken() failed. %+v %v", rsStruct, err)
+	}
+	if rsStruct, err := getByToken(pq, scheduled.Token, false); err != nil || rsStruct.Status != SCHEDULED || rsStruct.PositionInQueue != -1 {
+		t.Errorf("getByToken() failed. %+v %v", rsStruct, err)
+	}
+	if _, err := getByToken(pq, "1", false); err != errTokenNotFound {
+		t.Errorf("getByToken() failed. Expected errTokenNotFound, got %v", err)
+	}
+	if s5Struct, err := renegeByToken(pq, scheduled.Token, false); err != nil || s5Struct.ID != 1 {
+		t.Errorf("renegeByToken() failed. %+v %v", s5Struct, err)
+	}
+	if _, err := renegeByToken(pq, scheduled.Token, false); err != errTokenNotFound {
+		t.Errorf("renegeByToken() failed. Expected errTokenNotFound, got %v", err)
+	}
+
+	ownedByC1 := func(owner string) bool { return owner == "c1" }
+	if crStruct := getByCustomer(pq, "alice", ownedByC1, false); len(crStruct.Requests) != 1 || crStruct.Requests[0].ID != 0 {
+		t.Errorf("getByCustomer() failed. Expected only the request of c1, got %+v", crStruct)
+	}
+	if reneged, err := renegeByCustomer(pq, "alice", ownedByC1, false); err != nil || len(reneged) != 1 || pq.queue.Len() != 1 {
+		t.Errorf("renegeByCustomer() failed. %+v %v", reneged, err)
+	}
+	if _, err := renegeByCustomer(pq, "alice", ownedByC1, false); err != errTokenNotFound {
+		t.Errorf("renegeByCustomer() failed. Expected errTokenNotFound, got %v", err)
+	}
+	if len(pq.byToken) != 1 {
+		t.Errorf("renegeByCustomer() failed. Expected the tokens of reneged requests to be dropped, %d left", len(pq.byToken))
+	}
+}
+
+// This test checks that the lifecycle status of requests is kept once they have left the queue
+func TestHistory(t *testing.T) {
+	defer func(max int) { MAXHISTORY = max }(MAXHISTORY)
+	MAXHISTORY = 2
+	pq := newPriorityQueue("DefaultQueue", "", 10)
+	anyone := func(owner string) bool { return true }
+	soon := time.Now().Add(time.Minute)
+	for i, weight := range []int{9, 5, 1} {
+		_ = enqueue(pq, &CustomerRequest{CustomerName: strconv.Itoa(i), PriorityWeight: weight, EnqueueTime: time.Now()}, "c1", false)
+	}
+	_ = enqueue(pq, &CustomerRequest{CustomerName: "3", PriorityWeight: 1, EnqueueTime: time.Now(), Deadline: &soon}, "c1", false)
+
+	if rsStruct, err := getRequest(pq, 1, anyone, false); err != nil || rsStruct.Status != WAITING || rsStruct.CompletedAt != nil {
+		t.Errorf("getRequest() failed. Expected a waiting request, got %+v %v", rsStruct, err)
+	}
+	_, _, _ = selection3(pq, &Agent{ID: "a1"}, false)
+	_, _ = selection5(pq, 1, false)
+	if rsStruct, err := getRequest(pq, 0, anyone, false); err != nil || rsStruct.Status != SERVICED || rsStruct.AgentID != "a1" || rsStruct.CompletedAt == nil {
+		t.Errorf("getRequest() failed. Expected a serviced request, got %+v %v", rsStruct, err)
+	}
+	if rsStruct, err := getRequest(pq, 1, anyone, false); err != nil || rsStruct.Status != RENEGED {
+		t.Errorf("getRequest() failed. Expected a reneged request, got %+v %v", rsStruct, err)
+	}
+	if _, err := getRequest(pq, 1, func(owner string) bool { return owner == "c2" }, false); err != errNotOwner {
+		t.Errorf("getRequest() failed. Expected errNotOwner, got %v", err)
+	}
+
+	_ = reapExpired(pq, soon)
+	if rsStruct, err := getRequest(pq, 3, anyone, false); err != nil || rsStruct.Status != EXPIRED {
+		t.Errorf("getRequest() failed. Expected an expired request, got %+v %v", rsStruct, err)
+	}
+	if _, err := getRequest(pq, 0, anyone, false); err != errRequestNotFound {
+		t.Errorf("getRequest() failed. Expected the oldest completed request to be dropped, got %v", err)
+	}
+	if records := completedBetween(pq, time.Time{}, soon.Add(time.Second)); len(records) != 2 || records[0].ID != 1 || records[1].ID != 3 {
+		t.Errorf("completedBetween() failed. Expected requests 1 and 3, got %+v", records)
+	}
+	if len(pq.owners) != 1 || pq.owners[2] != "c1" {
+		t.Errorf("recordCompleted() failed. Expected only the owner of the waiting request to be kept, got %v", pq.owners)
+	}
+}
+
+// This test checks that reports aggregate the request history by day or week and by priority weight or queue
+func TestReports(t *testing.T) {
+	pq := newPriorityQueue("DefaultQueue", "", 10)
+	monday := time.Date(2026, 10, 12, 10, 0, 0, 0, time.UTC)
+	tuesday := monday.Add(24 * time.Hour)
+	completed := []struct {
+		weight int
+		status string
+		at     time.Time
+		wait   time.Duration
+	}{
+		{1, SERVICED, monday, 10 * time.Second},
+		{1, SERVICED, monday.Add(time.Hour), 30 * time.Second},
+		{5, RENEGED, monday.Add(2 * time.Hour), 5 * time.Second},
+		{5, SERVICED, tuesday, 20 * time.Second},
+		{5, CANCELLED, tuesday.Add(time.Hour), 0},
+	}
+	for i, c := range completed {
+		cr := &CustomerRequest{ID: i, CustomerName: strconv.Itoa(i), PriorityWeight: c.weight, EnqueueTime: c.at.Add(-c.wait)}
+		recordCompleted(pq, cr, c.status, "", c.at)
+	}
+
+	report, err := newReport(pq, "2026-10-12T00:00:00Z", "2026-10-19T00:00:00Z", "", "", monday)
+	if err != nil || report.Bucket != DAY || len(report.Rows) != 2 {
+		t.Fatalf("newReport() failed. Expected 2 daily rows, got %+v %v", report, err)
+	}
+	if row := report.Rows[0]; !row.BucketStart.Equal(monday.Truncate(24*time.Hour)) || row.VolumeCount != 3 || row.ServicedCount != 2 ||
+		row.AbandonedCount != 1 || row.AverageWaitInSec != 20 || row.WaitP90InSec != 30 || int(row.AbandonmentRatePercent) != 33 {
+		t.Errorf("newReport() failed. Unexpected monday row %+v", row)
+	}
+	if row := report.Rows[1]; row.VolumeCount != 2 || row.ServicedCount != 1 || row.AbandonedCount != 0 || row.AbandonmentRatePercent != 0 {
+		t.Errorf("newReport() failed. Unexpected tuesday row %+v", row)
+	}
+
+	report, err = newReport(pq, "2026-10-01T00:00:00Z", "2026-10-19T00:00:00Z", WEEK, BYPRIORITY, monday)
+	if err != nil || len(report.Rows) != 2 || report.Rows[0].Group != "1" || report.Rows[1].Group != "5" {
+		t.Fatalf("newReport() failed. Expected a weekly row per priority weight, got %+v %v", report, err)
+	}
+	if row := report.Rows[1]; !row.BucketStart.Equal(monday.Truncate(24*time.Hour)) || row.VolumeCount != 3 || row.AbandonmentRatePercent != 50 {
+		t.Errorf("newReport() failed. Unexpected row of priority weight 5 %+v", row)
+	}
+
+	var buf bytes.Buffer
+	if err := writeReportCSV(&buf, report); err != nil {
+		t.Fatalf("writeReportCSV() failed. %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || lines[0] != strings.Join(reportColumns, ",") || lines[1] != "2026-10-12T00:00:00Z,1,2,2,0,0.00,20.00,30.00" {
+		t.Errorf("writeReportCSV() failed. Got %q", lines)
+	}
+
+	// a request that left while it waited in the overflow queue is reported in that queue
+	if err := setOverflowPolicy(pq, SPILL); err != nil {
+		t.Fatal(err)
+	}
+	spilled := &CustomerRequest{ID: len(completed), CustomerName: "spilled", PriorityWeight: 1, EnqueueTime: tuesday.Add(-time.Minute)}
+	hold(pq, spilled)
+	if _, err := removeOverflowed(pq, spilled.ID); err != nil {
+		t.Fatal(err)
+	}
+	recordCompleted(pq, spilled, RENEGED, "", tuesday)
+	report, err = newReport(pq, "2026-10-12T00:00:00Z", "2026-10-19T00:00:00Z", "", BYQUEUE, monday)
+	if err != nil || len(report.Rows) != 3 || report.Rows[1].Group != "DefaultQueue" || report.Rows[2].Group != "DefaultQueueOverflow" {
+		t.Fatalf("newReport() failed. Expected a daily row per queue, got %+v %v", report, err)
+	}
+	if row := report.Rows[2]; row.VolumeCount != 1 || row.AbandonedCount != 1 {
+		t.Errorf("newReport() failed. Unexpected row of the overflow queue %+v", row)
+	}
+
+	for _, params := range [][4]string{
+		{"", "", "month", ""},
+		{"", "", "", "agent"},
+		{"2026-10-19", "2026-10-12", "", ""},
+		{"yesterday", "", "", ""},
+	} {
+		if _, err := newReport(pq, params[0], params[1], params[2], params[3], monday); err == nil {
+			t.Errorf("newReport() failed. Expected an error for %q", params)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultBuckets are the histogram buckets in seconds
+var defaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600, 1800, 3600}
+
+// httpLatencies holds a histogram of handler latency for every route and method
+var httpLatencies = struct {
+	histograms map[string]*histogram
+	mutex      sync.Mutex
+}{histograms: make(map[string]*histogram)}
+
+func (h *histogram) observe(v float64) {
+	if h.buckets == nil {
+		h.buckets = defaultBuckets
+	}
+	if h.counts == nil {
+		h.counts = make([]uint64, len(h.buckets))
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// This function writes h in the Prometheus text format, labels must already be formatted
+func (h *histogram) write(w io.Writer, name string, labels string) {
+	buckets := h.buckets
+	if buckets == nil {
+		buckets = defaultBuckets
+	}
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+	for i, upper := range buckets {
+		var c uint64
+		if h.counts != nil {
+			c = h.counts[i]
+		}
+		fmt.Fprintf(w, "%s_bucket{%s%sle=\"%s\"} %d\n", name, labels, sep, formatFloat(upper), c)
+	}
+	fmt.Fprintf(w, "%s_bucket{%s%sle=\"+Inf\"} %d\n", name, labels, sep, h.count)
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "%s_sum%s %s\n", name, labels, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count%s %d\n", name, labels, h.count)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// This function escapes a label value as required by the Prometheus text format
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// This method is used as middleware to observe the latency of every route
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		key := fmt.Sprintf("route=\"%s\",method=\"%s\"", escapeLabel(route), escapeLabel(r.Method))
+		httpLatencies.mutex.Lock()
+		h, ok := httpLatencies.histograms[key]
+		if !ok {
+			h = &histogram{}
+			httpLatencies.histograms[key] = h
+		}
+		h.observe(time.Since(start).Seconds())
+		httpLatencies.mutex.Unlock()
+	})
+}
+
+// This function writes the metrics of pq in the Prometheus text format
+func writeQueueMetrics(w io.Writer, pq *PriorityQueue) {
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	queue := fmt.Sprintf("queue=\"%s\"", escapeLabel(pq.queue.Name()))
+
+	depths := make(map[int]int)
+	oldest := time.Time{}
+	for _, cr := range pq.queue.Items() {
+		depths[cr.PriorityWeight]++
+		if oldest.IsZero() || cr.EnqueueTime.Before(oldest) {
+			oldest = cr.EnqueueTime
+		}
+	}
+	weights := make([]int, 0, len(depths))
+	for weight := range depths {
+		weights = append(weights, weight)
+	}
+	sort.Ints(weights)
+	fmt.Fprintln(w, "# HELP priority_queue_depth Number of customer requests waiting in the queue.")
+	fmt.Fprintln(w, "# TYPE priority_queue_depth gauge")
+	for _, weight := range weights {
+		fmt.Fprintf(w, "priority_queue_depth{%s,priority_weight=\"%d\"} %d\n", queue, weight, depths[weight])
+	}
+
+	fmt.Fprintln(w, "# HELP priority_queue_capacity_utilization_ratio Slots in use, including scheduled and offered requests, divided by capacity.")
+	fmt.Fprintln(w, "# TYPE priority_queue_capacity_utilization_ratio gauge")
+	utilization := 0.0
+	if pq.queue.Capacity() > 0 {
+		utilization = float64(occupancy(pq)) / float64(pq.queue.Capacity())
+	}
+	fmt.Fprintf(w, "priority_queue_capacity_utilization_ratio{%s} %s\n", queue, formatFloat(utilization))
+
+	fmt.Fprintln(w, "# HELP priority_queue_oldest_wait_seconds Wait time of the oldest customer request in the queue.")
+	fmt.Fprintln(w, "# TYPE priority_queue_oldest_wait_seconds gauge")
+	oldestWait := 0.0
+	if !oldest.IsZero() {
+		oldestWait = time.Since(oldest).Seconds()
+	}
+	fmt.Fprintf(w, "priority_queue_oldest_wait_seconds{%s} %s\n", queue, formatFloat(oldestWait))
+
+	counters := []struct {
+		name, help string
+		value      int
+	}{
+		{"priority_queue_enqueued_total", "Customer requests accepted by the queue.", pq.enqueuedCount},
+		{"priority_queue_serviced_total", "Customer requests serviced by agents.", pq.servicedCount},
+		{"priority_queue_reneged_total", "Customer requests reneged by customers.", pq.renegedCount},
+		{"priority_queue_expired_total", "Customer requests abandoned after their deadline.", pq.expiredCount},
+		{"priority_queue_rejected_total", "Customer requests rejected because the queue was at capacity.", pq.rejectedCount},
+	}
+	for _, c := range counters {
+		fmt.Fprintf(w, "# HELP %s %s\n", c.name, c.help)
+		fmt.Fprintf(w, "# TYPE %s counter\n", c.name)
+		fmt.Fprintf(w, "%s{%s} %d\n", c.name, queue, c.value)
+	}
+
+	fmt.Fprintln(w, "# HELP priority_queue_wait_seconds Wait time of customer requests when serviced.")
+	fmt.Fprintln(w, "# TYPE priority_queue_wait_seconds histogram")
+	pq.waitTimes.write(w, "priority_queue_wait_seconds", queue)
+}
+
+// This function writes the handler latencies in the Prometheus text format
+func writeHTTPMetrics(w io.Writer) {
+	httpLatencies.mutex.Lock()
+	defer httpLatencies.mutex.Unlock()
+	keys := make([]string, 0, len(httpLatencies.histograms))
+	for key := range httpLatencies.histograms {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fmt.Fprintln(w, "# HELP http_request_duration_seconds Latency of API handlers.")
+	fmt.Fprintln(w, "# TYPE http_request_duration_seconds histogram")
+	for _, key := range keys {
+		httpLatencies.histograms[key].write(w, "http_request_duration_seconds", key)
+	}
+}
+
+// This method is for exposing metrics to Prometheus
+func apiMetrics(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /metrics")
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeQueueMetrics(w, &PQ)
+	writeHTTPMetrics(w)
+}
@@ -7,26 +7,69 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 )
 
-// This method is used as a goroutine to handle REST APIs
-func handleRequests() {
+// This method is used as a goroutine to handle REST APIs until server is shut down
+func handleRequests(server *http.Server) {
+	logger.Println("API server started listening at port" + server.Addr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	logger.Println("API server stopped listening")
+}
+
+// This function creates the API server listening at addr
+func newServer(addr string) *http.Server {
 	logger.Println("starting API server")
+	return &http.Server{Addr: addr, Handler: newRouter()}
+}
+
+// This function registers every route of the API
+func newRouter() *mux.Router {
 	// creates a new instance of a mux router
 	r := mux.NewRouter().StrictSlash(true)
-	r.HandleFunc("/api/v1.0/queue/list", api1)
-	r.HandleFunc("/api/v1.0/queue/detail", api2)
-	r.HandleFunc("/api/v1.0/queue/service", api3)
-	r.HandleFunc("/api/v1.0/queue/enqueue", api4).Methods("POST")
-	r.HandleFunc("/api/v1.0/queue/renege/{id}", api5).Methods("DELETE")
-	r.HandleFunc("/api/v1.0/SystemInfo", api6)
-	r.HandleFunc("/", allOther)
-	port := ":10000"
-	logger.Println("API server started listening at port" + port)
-	log.Fatal(http.ListenAndServe(port, r))
+	r.HandleFunc("/api/v1.0/queue/list", api1).Name("list")
+	r.HandleFunc("/api/v1.0/queue/detail", api2).Name("detail")
+	r.HandleFunc("/api/v1.0/queue/service", api3).Name("service")
+	r.HandleFunc("/api/v1.0/queue/enqueue", api4).Methods("POST").Name("enqueue")
+	r.HandleFunc("/api/v1.0/queue/renege/{id}", api5).Methods("DELETE").Name("renege")
+	r.HandleFunc("/api/v1.0/queue/enqueue:batch", apiEnqueueBatch).Methods("POST").Name("enqueueBatch")
+	r.HandleFunc("/api/v1.0/queue/renege:batch", apiRenegeBatch).Methods("POST").Name("renegeBatch")
+	r.HandleFunc("/api/v1.0/queue/{id}/priority", apiChangePriority).Methods("PUT").Name("priority")
+	r.HandleFunc("/api/v1.0/queue/ordering", apiSetOrdering).Methods("PUT").Name("ordering")
+	r.HandleFunc("/api/v1.0/queue/capacity", apiSetCapacity).Methods("PUT").Name("capacity")
+	r.HandleFunc("/api/v1.0/queue/quotas", apiSetQuotas).Methods("PUT").Name("quotas")
+	r.HandleFunc("/api/v1.0/queue/scheduled", apiListScheduled).Methods("GET").Name("listScheduled")
+	r.HandleFunc("/api/v1.0/queue/scheduled/{id}", apiCancelScheduled).Methods("DELETE").Name("cancelScheduled")
+	r.HandleFunc("/api/v1.0/queue/requests/{token}", apiGetByToken).Methods("GET").Name("requestStatus")
+	r.HandleFunc("/api/v1.0/queue/requests/{token}", apiRenegeByToken).Methods("DELETE").Name("renegeRequest")
+	r.HandleFunc("/api/v1.0/queue/customers/{customerName}", apiGetByCustomer).Methods("GET").Name("customerRequests")
+	r.HandleFunc("/api/v1.0/queue/customers/{customerName}", apiRenegeByCustomer).Methods("DELETE").Name("renegeCustomer")
+	r.HandleFunc("/api/v1.0/requests/{id}", apiGetRequest).Methods("GET").Name("request")
+	r.HandleFunc("/api/v1.0/agents", apiListAgents).Methods("GET").Name("listAgents")
+	r.HandleFunc("/api/v1.0/agents", apiRegisterAgent).Methods("POST").Name("registerAgent")
+	r.HandleFunc("/api/v1.0/agents/{id}", apiGetAgent).Methods("GET").Name("getAgent")
+	r.HandleFunc("/api/v1.0/agents/{id}", apiUnregisterAgent).Methods("DELETE").Name("unregisterAgent")
+	r.HandleFunc("/api/v1.0/agents/{id}/state", apiSetAgentState).Methods("PUT").Name("agentState")
+	r.HandleFunc("/api/v1.0/agents/{id}/offer", apiGetOffer).Methods("GET").Name("getOffer")
+	r.HandleFunc("/api/v1.0/offers/{id}/accept", apiAcceptOffer).Methods("POST").Name("acceptOffer")
+	r.HandleFunc("/api/v1.0/offers/{id}/reject", apiRejectOffer).Methods("POST").Name("rejectOffer")
+	r.HandleFunc("/api/v1.0/SystemInfo", api6).Name("systemInfo")
+	r.HandleFunc("/api/v1.0/stats", apiStats).Methods("GET").Name("stats")
+	r.HandleFunc("/api/v1.0/events", apiEvents).Name("events")
+	r.HandleFunc("/api/v1.0/reports", apiReports).Methods("GET").Name("reports")
+	r.HandleFunc("/api/openapi.json", apiOpenAPI).Methods("GET").Name("openapi")
+	r.HandleFunc("/metrics", apiMetrics).Methods("GET").Name("metrics")
+	r.HandleFunc("/healthz", apiHealthz).Methods("GET").Name("healthz")
+	r.HandleFunc("/readyz", apiReadyz).Methods("GET").Name("readyz")
+	r.HandleFunc("/", allOther).Name("allOther")
+	r.Use(metricsMiddleware)
+	r.Use(authMiddleware)
+	return r
 }
 
 // This method is for Listing Customers in Queue
@@ -49,13 +92,30 @@ func api2(w http.ResponseWriter, r *http.Request) {
 	enc.Encode(s2Struct)
 }
 
-// This method is for Servicing Customer Request
+// This method is for Servicing Customer Request, the optional agentId query parameter
+// restricts it to the requests the agent is skilled for and assigns the request to the agent
 func api3(w http.ResponseWriter, r *http.Request) {
 	logger.Printf("Endpoint Hit: /api/v1.0/queue/service")
 	w.Header().Set("Content-Type", "application/json")
-	s3Struct, errorStruct, err := selection3(&PQ, false)
 	enc := json.NewEncoder(w)
 	enc.SetIndent("", "    ")
+	var s3Struct Selection3Struct
+	var errorStruct ErrorStruct
+	var err error
+	principal := principalFrom(r)
+	agentID := r.URL.Query().Get("agentId")
+	if principal.Role == AGENT && agentID == "" {
+		agentID = principal.ClientID // authenticated agents always service for themselves
+	}
+	if !mayActFor(principal, agentID) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "agents may only service for themselves")
+		return
+	}
+	if agentID != "" {
+		s3Struct, errorStruct, err = serviceForAgent(&AR, &PQ, agentID, false)
+	} else {
+		s3Struct, errorStruct, err = selection3(&PQ, nil, false)
+	}
 	if err != nil {
 		enc.Encode(errorStruct)
 	} else {
@@ -80,13 +140,55 @@ func api4(w http.ResponseWriter, r *http.Request) {
 		enc.Encode(temp)
 		return
 	}
+	if err := validateTiming(&cr, tempTime); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		enc.Encode(Selection4ErrorStruct{Error: "INVALID_PARAMETERS", Msg: err.Error()})
+		return
+	}
 	cr.EnqueueTime = tempTime
+	owner := clientKey(r)
+
+	// retries of an enqueue return the original response, the key is reserved until the enqueue is
+	// done so that concurrent retries can not both get through
+	key := idempotencyKey(owner, r.Header.Get("Idempotency-Key"), &cr)
+	var stored *IdempotencyEntry
+	if key != "" {
+		if entry, ok := reserveIdempotencyKey(&IK, key, tempTime); ok {
+			if entry.Fingerprint != fingerprint(reqBody) {
+				writeError(w, http.StatusUnprocessableEntity, "IDEMPOTENCY_KEY_REUSED", "idempotency key was used for a different request")
+				return
+			}
+			logger.Printf("replaying enqueue of %d for idempotency key", entry.Response.ID)
+			w.Header().Set("Idempotent-Replayed", "true")
+			enc.Encode(entry.Response)
+			return
+		}
+		defer func() { releaseIdempotencyKey(&IK, key, stored) }()
+	}
+
+	PQ.mutex.Lock()
+	errorCode, retryAfter := takeQuota(&PQ, owner, tempTime)
+	PQ.mutex.Unlock()
+	if errorCode != "" {
+		logger.Printf("enqueue of %s refused. %s", owner, errorCode)
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		writeError(w, http.StatusTooManyRequests, errorCode, "too many requests, please try again later")
+		return
+	}
 
-	s4Struct, err := selection4(&PQ, &cr, false)
+	s4Struct, err := selection4(&PQ, &cr, owner, false)
 	if err != nil {
+		PQ.mutex.Lock()
+		refundQuota(&PQ, owner)
+		PQ.mutex.Unlock()
+	}
+	if err == errDuplicateCustomer {
+		writeError(w, http.StatusConflict, "DUPLICATE_CUSTOMER", err.Error())
+	} else if err != nil {
 		w.WriteHeader(http.StatusServiceUnavailable)
 		enc.Encode(Selection4ErrorStruct{Error: "MAX_CAPACITY_REACHED", Msg: err.Error()})
 	} else {
+		stored = &IdempotencyEntry{Response: s4Struct, Fingerprint: fingerprint(reqBody), CreatedAt: tempTime}
 		enc.Encode(s4Struct)
 	}
 }
@@ -104,6 +206,16 @@ func api5(w http.ResponseWriter, r *http.Request) {
 	// wish to delete
 	idStr := vars["id"]
 	idInt, _ := strconv.Atoi(idStr)
+	// IDs are sequential and can be guessed, so everyone but supervisors and admins must also give
+	// the token of the request, even while authentication is disabled
+	if !isStaff(r) && !mayRenegeWith(&PQ, idInt, r.URL.Query().Get("token")) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "the token of the request is required to renege it by id")
+		return
+	}
+	if owner, err := getOwner(&PQ, idInt); err == nil && !mayActFor(principalFrom(r), owner) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "customers may only renege their own requests")
+		return
+	}
 
 	s5Struct, err := selection5(&PQ, idInt, false)
 	if err != nil {
@@ -114,11 +226,55 @@ func api5(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// This method is for Enqueueing many Customer Requests at once
+func apiEnqueueBatch(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/queue/enqueue:batch")
+	enc := json.NewEncoder(w)
+	w.Header().Set("Content-Type", "application/json")
+	enc.SetIndent("", "    ")
+
+	reqBody, _ := ioutil.ReadAll(r.Body)
+	batch := BatchEnqueueJSON{}
+	err := json.Unmarshal(reqBody, &batch)
+	if err == nil {
+		batch.Mode, err = batchMode(batch.Mode)
+	}
+	if err != nil || len(batch.CustomerRequests) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		enc.Encode(Selection4ErrorStruct{Error: "INVALID_PARAMETERS", Msg: "body must have a mode and customerRequests"})
+		return
+	}
+	enc.Encode(enqueueBatch(&PQ, batch.CustomerRequests, batch.Mode, clientKey(r)))
+}
+
+// This method is for Reneging many Customer Requests at once
+func apiRenegeBatch(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/queue/renege:batch")
+	enc := json.NewEncoder(w)
+	w.Header().Set("Content-Type", "application/json")
+	enc.SetIndent("", "    ")
+
+	reqBody, _ := ioutil.ReadAll(r.Body)
+	batch := BatchRenegeJSON{}
+	err := json.Unmarshal(reqBody, &batch)
+	if err == nil {
+		batch.Mode, err = batchMode(batch.Mode)
+	}
+	if err != nil || len(batch.IDs) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		enc.Encode(Selection4ErrorStruct{Error: "INVALID_PARAMETERS", Msg: "body must have a mode and ids"})
+		return
+	}
+	principal := principalFrom(r)
+	mayRenege := func(owner string) bool { return mayActFor(principal, owner) }
+	enc.Encode(renegeBatch(&PQ, batch.IDs, batch.Mode, mayRenege))
+}
+
 // This method is for getting System Information
 func api6(w http.ResponseWriter, r *http.Request) {
 	logger.Printf("Endpoint Hit: /api/v1.0/SystemInfo")
 	w.Header().Set("Content-Type", "application/json")
-	s6Struct, errorStruct, err := selection6(&PQ, false)
+	s6Struct, errorStruct, err := selection6(&PQ, &AR, false)
 	enc := json.NewEncoder(w)
 	enc.SetIndent("", "    ")
 	if err != nil {
@@ -128,6 +284,415 @@ func api6(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// This method is for changing the PriorityWeight of a Customer Request
+func apiChangePriority(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/queue/{id}/priority")
+	enc := json.NewEncoder(w)
+	w.Header().Set("Content-Type", "application/json")
+	enc.SetIndent("", "    ")
+
+	idInt, _ := strconv.Atoi(mux.Vars(r)["id"])
+	reqBody, _ := ioutil.ReadAll(r.Body)
+	priorityJSON := PriorityJSON{}
+	if err := json.Unmarshal(reqBody, &priorityJSON); err != nil || priorityJSON.PriorityWeight <= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		enc.Encode(Selection4ErrorStruct{Error: "INVALID_PARAMETERS", Msg: "priorityWeight must be a positive number"})
+		return
+	}
+	cr, err := changePriority(&PQ, idInt, priorityJSON.PriorityWeight, false)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		enc.Encode(ErrorStruct{Msg: err.Error()})
+	} else {
+		enc.Encode(cr)
+	}
+}
+
+// This method is for switching the ordering of the queue
+func apiSetOrdering(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/queue/ordering")
+	enc := json.NewEncoder(w)
+	w.Header().Set("Content-Type", "application/json")
+	enc.SetIndent("", "    ")
+
+	reqBody, _ := ioutil.ReadAll(r.Body)
+	orderingJSON := OrderingJSON{}
+	if err := json.Unmarshal(reqBody, &orderingJSON); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_PARAMETERS", "ordering must be given")
+		return
+	}
+	result, err := setOrdering(&PQ, orderingJSON.Ordering, false)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_PARAMETERS", err.Error())
+		return
+	}
+	enc.Encode(result)
+}
+
+// This method is for resizing the queue
+func apiSetCapacity(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/queue/capacity")
+	enc := json.NewEncoder(w)
+	w.Header().Set("Content-Type", "application/json")
+	enc.SetIndent("", "    ")
+
+	reqBody, _ := ioutil.ReadAll(r.Body)
+	capacityJSON := CapacityJSON{}
+	if err := json.Unmarshal(reqBody, &capacityJSON); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_PARAMETERS", "capacity must be given")
+		return
+	}
+	result, err := setCapacity(&PQ, capacityJSON.Capacity, false)
+	if err == errCapacityRefused {
+		writeError(w, http.StatusConflict, "CAPACITY_REFUSED", err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_PARAMETERS", err.Error())
+		return
+	}
+	enc.Encode(result)
+}
+
+// This method is for setting the enqueue quotas of clients
+func apiSetQuotas(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/queue/quotas")
+	enc := json.NewEncoder(w)
+	w.Header().Set("Content-Type", "application/json")
+	enc.SetIndent("", "    ")
+
+	reqBody, _ := ioutil.ReadAll(r.Body)
+	quotasJSON := QuotasJSON{}
+	if err := json.Unmarshal(reqBody, &quotasJSON); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_PARAMETERS", "rateLimit, rateBurst and maxOutstanding must be numbers")
+		return
+	}
+	result, err := setQuotas(&PQ, quotasJSON)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_PARAMETERS", err.Error())
+		return
+	}
+	enc.Encode(result)
+}
+
+// This method is for Listing scheduled Customer Requests
+func apiListScheduled(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/queue/scheduled")
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	enc.Encode(listScheduled(&PQ, false))
+}
+
+// This method is for Cancelling a scheduled Customer Request
+func apiCancelScheduled(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/queue/scheduled/")
+	enc := json.NewEncoder(w)
+	w.Header().Set("Content-Type", "application/json")
+	enc.SetIndent("", "    ")
+
+	idInt, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if owner, err := getOwner(&PQ, idInt); err == nil && !mayActFor(principalFrom(r), owner) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "customers may only cancel their own requests")
+		return
+	}
+	s5Struct, err := cancelScheduled(&PQ, idInt, false)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		enc.Encode(ErrorStruct{Msg: err.Error()})
+	} else {
+		enc.Encode(s5Struct)
+	}
+}
+
+// This method is for getting the status of a Customer Request by its token
+func apiGetByToken(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/queue/requests/")
+	enc := json.NewEncoder(w)
+	w.Header().Set("Content-Type", "application/json")
+	enc.SetIndent("", "    ")
+
+	rsStruct, err := getByToken(&PQ, mux.Vars(r)["token"], false)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		enc.Encode(ErrorStruct{Msg: err.Error()})
+		return
+	}
+	enc.Encode(rsStruct)
+}
+
+// This method is for Reneging a Customer Request by its token, the token is proof enough of ownership
+func apiRenegeByToken(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/queue/requests/")
+	enc := json.NewEncoder(w)
+	w.Header().Set("Content-Type", "application/json")
+	enc.SetIndent("", "    ")
+
+	s5Struct, err := renegeByToken(&PQ, mux.Vars(r)["token"], false)
+	if err == errOffered {
+		writeError(w, http.StatusConflict, "OFFERED", err.Error())
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		enc.Encode(ErrorStruct{Msg: err.Error()})
+		return
+	}
+	enc.Encode(s5Struct)
+}
+
+// This method is for getting the status of the Customer Requests of a customer, customers only see their own
+func apiGetByCustomer(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/queue/customers/")
+	enc := json.NewEncoder(w)
+	w.Header().Set("Content-Type", "application/json")
+	enc.SetIndent("", "    ")
+
+	principal := principalFrom(r)
+	mayAct := func(owner string) bool { return mayActFor(principal, owner) }
+	enc.Encode(getByCustomer(&PQ, mux.Vars(r)["customerName"], mayAct, false))
+}
+
+// This method is for Reneging the Customer Requests of a customer, customers only renege their own
+func apiRenegeByCustomer(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/queue/customers/")
+	enc := json.NewEncoder(w)
+	w.Header().Set("Content-Type", "application/json")
+	enc.SetIndent("", "    ")
+
+	principal := principalFrom(r)
+	mayAct := func(owner string) bool { return mayActFor(principal, owner) }
+	reneged, err := renegeByCustomer(&PQ, mux.Vars(r)["customerName"], mayAct, false)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		enc.Encode(ErrorStruct{Msg: err.Error()})
+		return
+	}
+	enc.Encode(reneged)
+}
+
+// This method is for getting the lifecycle status of a live or completed Customer Request,
+// customers only see their own requests
+func apiGetRequest(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/requests/")
+	enc := json.NewEncoder(w)
+	w.Header().Set("Content-Type", "application/json")
+	enc.SetIndent("", "    ")
+
+	idInt, _ := strconv.Atoi(mux.Vars(r)["id"])
+	principal := principalFrom(r)
+	mayAct := func(owner string) bool { return principal.Role == AGENT || mayActFor(principal, owner) }
+	rsStruct, err := getRequest(&PQ, idInt, mayAct, false)
+	if err == errNotOwner {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		enc.Encode(ErrorStruct{Msg: err.Error()})
+		return
+	}
+	enc.Encode(rsStruct)
+}
+
+// This method is for Listing registered agents
+func apiListAgents(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/agents")
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	enc.Encode(listAgents(&AR))
+}
+
+// This method is for Registering an agent with its skills
+func apiRegisterAgent(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/agents")
+	enc := json.NewEncoder(w)
+	w.Header().Set("Content-Type", "application/json")
+	enc.SetIndent("", "    ")
+
+	reqBody, _ := ioutil.ReadAll(r.Body)
+	agent := Agent{}
+	if err := json.Unmarshal(reqBody, &agent); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		enc.Encode(Selection4ErrorStruct{Error: "INVALID_PARAMETERS", Msg: err.Error()})
+		return
+	}
+	if err := registerAgent(&AR, &agent); err != nil {
+		if err == errAgentExists {
+			w.WriteHeader(http.StatusConflict)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		enc.Encode(Selection4ErrorStruct{Error: "INVALID_AGENT", Msg: err.Error()})
+		return
+	}
+	enc.Encode(agent)
+}
+
+// This method is for getting an agent with its state and assignment
+func apiGetAgent(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/agents/{id}")
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	agent, err := getAgentByID(&AR, mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		enc.Encode(ErrorStruct{Msg: err.Error()})
+	} else {
+		enc.Encode(agent)
+	}
+}
+
+// This method is for Unregistering an agent
+func apiUnregisterAgent(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/agents/{id}")
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	agent, err := unregisterAgent(&AR, mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		enc.Encode(ErrorStruct{Msg: err.Error()})
+	} else {
+		enc.Encode(agent)
+	}
+}
+
+// This method is for changing the presence state of an agent
+func apiSetAgentState(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/agents/{id}/state")
+	enc := json.NewEncoder(w)
+	w.Header().Set("Content-Type", "application/json")
+	enc.SetIndent("", "    ")
+
+	if !mayActFor(principalFrom(r), mux.Vars(r)["id"]) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "agents may only change their own state")
+		return
+	}
+	reqBody, _ := ioutil.ReadAll(r.Body)
+	stateJSON := AgentStateJSON{}
+	json.Unmarshal(reqBody, &stateJSON)
+	agent, err := setAgentState(&AR, mux.Vars(r)["id"], stateJSON.State)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		enc.Encode(Selection4ErrorStruct{Error: "INVALID_PARAMETERS", Msg: err.Error()})
+	} else {
+		enc.Encode(agent)
+	}
+}
+
+// This method is for getting the pending offer of an agent
+func apiGetOffer(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/agents/{id}/offer")
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	if !mayActFor(principalFrom(r), mux.Vars(r)["id"]) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "agents may only see their own offers")
+		return
+	}
+	offer, err := DP.getOffer(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		enc.Encode(ErrorStruct{Msg: err.Error()})
+	} else {
+		enc.Encode(offer)
+	}
+}
+
+// This method is for accepting an offer
+func apiAcceptOffer(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/offers/{id}/accept")
+	respondToOffer(w, r, true)
+}
+
+// This method is for rejecting an offer
+func apiRejectOffer(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/offers/{id}/reject")
+	respondToOffer(w, r, false)
+}
+
+func respondToOffer(w http.ResponseWriter, r *http.Request, accept bool) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	idInt, _ := strconv.Atoi(mux.Vars(r)["id"])
+	principal := principalFrom(r)
+	response, err := DP.respond(idInt, accept, func(agentID string) bool { return mayActFor(principal, agentID) })
+	if err == errNotOfferedAgent {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+	} else if err == errAgentNotAvailable {
+		writeError(w, http.StatusConflict, "AGENT_NOT_AVAILABLE", err.Error())
+	} else if err != nil {
+		writeError(w, http.StatusNotFound, "OFFER_NOT_FOUND", err.Error())
+	} else {
+		enc.Encode(response)
+	}
+}
+
+// This method is for getting service level metrics, the optional threshold query parameter
+// overrides the service level threshold in seconds
+func apiStats(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/stats")
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	threshold := SLATHRESHOLD
+	if thresholdStr := r.URL.Query().Get("threshold"); thresholdStr != "" {
+		var err error
+		threshold, err = strconv.ParseFloat(thresholdStr, 64)
+		if err != nil || threshold < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			enc.Encode(Selection4ErrorStruct{Error: "INVALID_PARAMETERS", Msg: "threshold must be a positive number of seconds"})
+			return
+		}
+	}
+	enc.Encode(getStats(&PQ, threshold))
+}
+
+// This method is for listing recent lifecycle events such as abandoned requests
+func apiEvents(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/events")
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	enc.Encode(listEvents(&PQ))
+}
+
+// This method is for getting a report of completed requests by time bucket, and by priority weight or
+// queue with groupBy. It is CSV if format is csv or text/csv is accepted, JSON otherwise.
+func apiReports(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/v1.0/reports")
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" && strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		format = "csv"
+	}
+	if format != "" && format != "csv" && format != "json" {
+		writeError(w, http.StatusBadRequest, "INVALID_PARAMETERS", "format must be json or csv")
+		return
+	}
+	report, err := newReport(&PQ, query.Get("from"), query.Get("to"), query.Get("bucket"), query.Get("groupBy"), time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_PARAMETERS", err.Error())
+		return
+	}
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="report.csv"`)
+		if err := writeReportCSV(w, report); err != nil {
+			logger.Println(err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	enc.Encode(report)
+}
+
 // Method to handle all other requests
 func allOther(w http.ResponseWriter, r *http.Request) {
 	logger.Printf("Endpoint Hit: allOther")
@@ -137,5 +702,29 @@ func allOther(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "/api/v1.0/queue/service")
 	fmt.Fprintf(w, "/api/v1.0/queue/enqueue")
 	fmt.Fprintf(w, "/api/v1.0/queue/renege/{id}")
+	fmt.Fprintf(w, "/api/v1.0/queue/enqueue:batch")
+	fmt.Fprintf(w, "/api/v1.0/queue/renege:batch")
+	fmt.Fprintf(w, "/api/v1.0/queue/{id}/priority")
+	fmt.Fprintf(w, "/api/v1.0/queue/ordering")
+	fmt.Fprintf(w, "/api/v1.0/queue/capacity")
+	fmt.Fprintf(w, "/api/v1.0/queue/quotas")
+	fmt.Fprintf(w, "/api/v1.0/queue/scheduled")
+	fmt.Fprintf(w, "/api/v1.0/queue/scheduled/{id}")
+	fmt.Fprintf(w, "/api/v1.0/queue/requests/{token}")
+	fmt.Fprintf(w, "/api/v1.0/queue/customers/{customerName}")
+	fmt.Fprintf(w, "/api/v1.0/requests/{id}")
+	fmt.Fprintf(w, "/api/v1.0/agents")
+	fmt.Fprintf(w, "/api/v1.0/agents/{id}")
+	fmt.Fprintf(w, "/api/v1.0/agents/{id}/state")
+	fmt.Fprintf(w, "/api/v1.0/agents/{id}/offer")
+	fmt.Fprintf(w, "/api/v1.0/offers/{id}/accept")
+	fmt.Fprintf(w, "/api/v1.0/offers/{id}/reject")
 	fmt.Fprintf(w, "/api/v1.0/SystemInfo")
+	fmt.Fprintf(w, "/api/v1.0/stats")
+	fmt.Fprintf(w, "/api/v1.0/events")
+	fmt.Fprintf(w, "/api/v1.0/reports")
+	fmt.Fprintf(w, "/api/openapi.json")
+	fmt.Fprintf(w, "/metrics")
+	fmt.Fprintf(w, "/healthz")
+	fmt.Fprintf(w, "/readyz")
 }
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiOperation documents a route of handleRequests. Request and response types are turned into
+// schemas from their JSON tags, so the spec can not drift from structs.go.
+type apiOperation struct {
+	name, method, path, summary string              // name is the name of the route in newRouter
+	query                       []string            // query lists the optional query parameters
+	request                     interface{}         // request is the type of the JSON body, nil if there is none
+	responses                   map[int]interface{} // responses maps a status to its type, a slice of types for oneOf or a string for plain text
+	produces                    []string            // produces lists the other media types the 200 response can be served as
+}
+
+// apiOperations documents every route, TestOpenAPICoversRoutes fails if a route is missing
+var apiOperations = []apiOperation{
+	{name: "list", method: "GET", path: "/api/v1.0/queue/list", summary: "List customers in queue",
+		responses: map[int]interface{}{200: Selection1Struct{}}},
+	{name: "detail", method: "GET", path: "/api/v1.0/queue/detail", summary: "List customer details in queue",
+		responses: map[int]interface{}{200: Selection2Struct{}}},
+	{name: "service", method: "GET", path: "/api/v1.0/queue/service", summary: "Service the customer request with highest priority, for an agent if agentId is given",
+		query:     []string{"agentId"},
+		responses: map[int]interface{}{200: []interface{}{Selection3Struct{}, ErrorStruct{}}}},
+	{name: "enqueue", method: "POST", path: "/api/v1.0/queue/enqueue", summary: "Enqueue customer request, retries with the same Idempotency-Key header return the original response",
+		request: CustomerRequest{},
+		responses: map[int]interface{}{200: []interface{}{Selection4Struct{}, Selection4ErrorStruct{}}, 400: Selection4ErrorStruct{},
+			409: Selection4ErrorStruct{}, 422: Selection4ErrorStruct{}, 429: Selection4ErrorStruct{}, 503: Selection4ErrorStruct{}}},
+	{name: "renege", method: "DELETE", path: "/api/v1.0/queue/renege/{id}", summary: "Renege customer request, token is required unless the caller is a supervisor or admin",
+		query:     []string{"token"},
+		responses: map[int]interface{}{200: Selection5Struct{}, 403: Selection4ErrorStruct{}, 404: ErrorStruct{}}},
+	{name: "enqueueBatch", method: "POST", path: "/api/v1.0/queue/enqueue:batch", summary: "Enqueue many customer requests",
+		request:   BatchEnqueueJSON{},
+		responses: map[int]interface{}{200: BatchStruct{}, 400: Selection4ErrorStruct{}}},
+	{name: "renegeBatch", method: "POST", path: "/api/v1.0/queue/renege:batch", summary: "Renege many customer requests",
+		request:   BatchRenegeJSON{},
+		responses: map[int]interface{}{200: BatchStruct{}, 400: Selection4ErrorStruct{}}},
+	{name: "priority", method: "PUT", path: "/api/v1.0/queue/{id}/priority", summary: "Change the priority weight of a customer request",
+		request:   PriorityJSON{},
+		responses: map[int]interface{}{200: CustomerRequest{}, 400: Selection4ErrorStruct{}, 404: ErrorStruct{}}},
+	{name: "ordering", method: "PUT", path: "/api/v1.0/queue/ordering", summary: "Switch the ordering of the queue to PRIORITY, EDF, AGING, WEIGHTED_FAIR or FAIR_SHARE",
+		request:   OrderingJSON{},
+		responses: map[int]interface{}{200: OrderingJSON{}, 400: Selection4ErrorStruct{}}},
+	{name: "capacity", method: "PUT", path: "/api/v1.0/queue/capacity", summary: "Resize the queue, lowering it below the requests in the queue applies the overflow policy",
+		request:   CapacityJSON{},
+		responses: map[int]interface{}{200: CapacityJSON{}, 400: Selection4ErrorStruct{}, 409: Selection4ErrorStruct{}}},
+	{name: "quotas", method: "PUT", path: "/api/v1.0/queue/quotas", summary: "Set the rate limit and the outstanding requests quota of clients, zero turns a quota off",
+		request:   QuotasJSON{},
+		responses: map[int]interface{}{200: QuotasJSON{}, 400: Selection4ErrorStruct{}}},
+	{name: "listScheduled", method: "GET", path: "/api/v1.0/queue/scheduled", summary: "List scheduled customer requests",
+		responses: map[int]interface{}{200: ScheduledStruct{}}},
+	{name: "cancelScheduled", method: "DELETE", path: "/api/v1.0/queue/scheduled/{id}", summary: "Cancel scheduled customer request",
+		responses: map[int]interface{}{200: Selection5Struct{}, 404: ErrorStruct{}}},
+	{name: "requestStatus", method: "GET", path: "/api/v1.0/queue/requests/{token}", summary: "Get the status of a customer request by the token returned from enqueue",
+		responses: map[int]interface{}{200: RequestStatusStruct{}, 404: ErrorStruct{}}},
+	{name: "renegeRequest", method: "DELETE", path: "/api/v1.0/queue/requests/{token}", summary: "Renege a customer request by the token returned from enqueue",
+		responses: map[int]interface{}{200: Selection5Struct{}, 404: ErrorStruct{}, 409: Selection4ErrorStruct{}}},
+	{name: "customerRequests", method: "GET", path: "/api/v1.0/queue/customers/{customerName}", summary: "Get the status of the customer requests of a customer",
+		responses: map[int]interface{}{200: CustomerRequestsStruct{}}},
+	{name: "renegeCustomer", method: "DELETE", path: "/api/v1.0/queue/customers/{customerName}", summary: "Renege the customer requests of a customer",
+		responses: map[int]interface{}{200: []Selection5Struct{}, 404: ErrorStruct{}}},
+	{name: "request", method: "GET", path: "/api/v1.0/requests/{id}", summary: "Get the lifecycle status of a live or completed customer request",
+		responses: map[int]interface{}{200: RequestStatusStruct{}, 403: Selection4ErrorStruct{}, 404: ErrorStruct{}}},
+	{name: "listAgents", method: "GET", path: "/api/v1.0/agents", summary: "List agents",
+		responses: map[int]interface{}{200: []Agent{}}},
+	{name: "registerAgent", method: "POST", path: "/api/v1.0/agents", summary: "Register agent",
+		request:   Agent{},
+		responses: map[int]interface{}{200: Agent{}, 400: Selection4ErrorStruct{}, 409: Selection4ErrorStruct{}}},
+	{name: "getAgent", method: "GET", path: "/api/v1.0/agents/{id}", summary: "Get agent",
+		responses: map[int]interface{}{200: Agent{}, 404: ErrorStruct{}}},
+	{name: "unregisterAgent", method: "DELETE", path: "/api/v1.0/agents/{id}", summary: "Unregister agent",
+		responses: map[int]interface{}{200: Agent{}, 409: ErrorStruct{}}},
+	{name: "agentState", method: "PUT", path: "/api/v1.0/agents/{id}/state", summary: "Change presence state of agent",
+		request:   AgentStateJSON{},
+		responses: map[int]interface{}{200: Agent{}, 400: Selection4ErrorStruct{}}},
+	{name: "getOffer", method: "GET", path: "/api/v1.0/agents/{id}/offer", summary: "Get pending offer of agent",
+		responses: map[int]interface{}{200: Offer{}, 404: ErrorStruct{}}},
+	{name: "acceptOffer", method: "POST", path: "/api/v1.0/offers/{id}/accept", summary: "Accept offer",
+		responses: map[int]interface{}{200: OfferResponseStruct{}, 403: Selection4ErrorStruct{}, 404: Selection4ErrorStruct{}, 409: Selection4ErrorStruct{}}},
+	{name: "rejectOffer", method: "POST", path: "/api/v1.0/offers/{id}/reject", summary: "Reject offer",
+		responses: map[int]interface{}{200: OfferResponseStruct{}, 403: Selection4ErrorStruct{}, 404: Selection4ErrorStruct{}}},
+	{name: "systemInfo", method: "GET", path: "/api/v1.0/SystemInfo", summary: "System information",
+		responses: map[int]interface{}{200: []interface{}{Selection6Struct{}, ErrorStruct{}}}},
+	{name: "stats", method: "GET", path: "/api/v1.0/stats", summary: "Service level metrics, threshold overrides the service level threshold in seconds",
+		query:     []string{"threshold"},
+		responses: map[int]interface{}{200: StatsStruct{}, 400: Selection4ErrorStruct{}}},
+	{name: "reports", method: "GET", path: "/api/v1.0/reports", summary: "Report of completed requests by day or week bucket, grouped by priorityWeight or queue, as CSV if format is csv or text/csv is accepted",
+		query:     []string{"from", "to", "bucket", "groupBy", "format"},
+		produces:  []string{"text/csv"},
+		responses: map[int]interface{}{200: ReportStruct{}, 400: Selection4ErrorStruct{}}},
+	{name: "events", method: "GET", path: "/api/v1.0/events", summary: "Recent lifecycle events",
+		responses: map[int]interface{}{200: EventsStruct{}}},
+	{name: "openapi", method: "GET", path: "/api/openapi.json", summary: "This document",
+		responses: map[int]interface{}{200: map[string]interface{}{}}},
+	{name: "metrics", method: "GET", path: "/metrics", summary: "Prometheus metrics",
+		responses: map[int]interface{}{200: "text/plain"}},
+	{name: "healthz", method: "GET", path: "/healthz", summary: "Liveness probe",
+		responses: map[int]interface{}{200: ReadinessStruct{}}},
+	{name: "readyz", method: "GET", path: "/readyz", summary: "Readiness probe",
+		responses: map[int]interface{}{200: ReadinessStruct{}, 503: ReadinessStruct{}}},
+	{name: "allOther", method: "GET", path: "/", summary: "List of routes",
+		responses: map[int]interface{}{200: "text/plain"}},
+}
+
+// This function returns the JSON schema of t, structs are added to components and referenced
+func schemaFor(t reflect.Type, components map[string]interface{}) map[string]interface{} {
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := schemaFor(t.Elem(), components)
+		if _, ok := schema["$ref"]; ok {
+			return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), components), "nullable": true}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem(), components), "nullable": true}
+	case reflect.Interface:
+		return map[string]interface{}{}
+	case reflect.Struct:
+		if _, ok := components[t.Name()]; !ok {
+			components[t.Name()] = nil // placeholder for recursive types
+			properties := make(map[string]interface{})
+			required := make([]string, 0)
+			for i := 0; i < t.NumField(); i++ {
+				field := t.Field(i)
+				name, omitEmpty := jsonName(field)
+				if name == "" {
+					continue
+				}
+				properties[name] = schemaFor(field.Type, components)
+				if !omitEmpty {
+					required = append(required, name)
+				}
+			}
+			schema := map[string]interface{}{"type": "object", "properties": properties, "additionalProperties": false}
+			if len(required) > 0 {
+				schema["required"] = required
+			}
+			components[t.Name()] = schema
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return map[string]interface{}{}
+}
+
+// This function returns the JSON name of field as encoding/json does, or "" if it is not encoded
+func jsonName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false // unexported
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	omitEmpty := false
+	for _, option := range parts[1:] {
+		if option == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty
+}
+
+// This function returns the response schema of a documented response
+func responseSchema(response interface{}, components map[string]interface{}) map[string]interface{} {
+	if types, ok := response.([]interface{}); ok {
+		oneOf := make([]interface{}, 0, len(types))
+		for _, t := range types {
+			oneOf = append(oneOf, schemaFor(reflect.TypeOf(t), components))
+		}
+		return map[string]interface{}{"oneOf": oneOf}
+	}
+	return schemaFor(reflect.TypeOf(response), components)
+}
+
+// This function builds the OpenAPI 3 document of the API
+func openAPISpec() map[string]interface{} {
+	components := make(map[string]interface{})
+	paths := make(map[string]interface{})
+	for _, op := range apiOperations {
+		operation := map[string]interface{}{"summary": op.summary}
+		parameters := make([]interface{}, 0)
+		for _, segment := range strings.Split(op.path, "/") {
+			if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+				parameters = append(parameters, map[string]interface{}{
+					"name": strings.Trim(segment, "{}"), "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}})
+			}
+		}
+		for _, name := range op.query {
+			parameters = append(parameters, map[string]interface{}{
+				"name": name, "in": "query", "required": false, "schema": map[string]interface{}{"type": "string"}})
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if op.request != nil {
+			operation["requestBody"] = map[string]interface{}{"required": true, "content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": schemaFor(reflect.TypeOf(op.request), components)}}}
+		}
+
+		responses := make(map[string]interface{})
+		statuses := make([]int, 0, len(op.responses))
+		for status := range op.responses {
+			statuses = append(statuses, status)
+		}
+		if roles, ok := routeRoles[op.name]; ok {
+			operation["description"] = "Allowed roles: " + strings.Join(roles, ", ")
+			statuses = append(statuses, http.StatusUnauthorized, http.StatusForbidden)
+		}
+		sort.Ints(statuses)
+		for _, status := range statuses {
+			response, ok := op.responses[status]
+			if !ok {
+				response = Selection4ErrorStruct{}
+			}
+			content := map[string]interface{}{}
+			if mediaType, ok := response.(string); ok {
+				content[mediaType] = map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}
+			} else {
+				content["application/json"] = map[string]interface{}{"schema": responseSchema(response, components)}
+			}
+			if status == http.StatusOK {
+				for _, mediaType := range op.produces {
+					content[mediaType] = map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}
+				}
+			}
+			responses[strconv.Itoa(status)] = map[string]interface{}{"description": http.StatusText(status), "content": content}
+		}
+		operation["responses"] = responses
+
+		item, ok := paths[op.path].(map[string]interface{})
+		if !ok {
+			item = make(map[string]interface{})
+			paths[op.path] = item
+		}
+		item[strings.ToLower(op.method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "ExpertFlow Priority Queue",
+			"version": "1.0"},
+		"paths": paths,
+		"components": map[string]interface{}{"schemas": components, "securitySchemes": map[string]interface{}{
+			"apiKey": map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			"bearer": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"}}},
+		"security": []interface{}{map[string]interface{}{"apiKey": []interface{}{}}, map[string]interface{}{"bearer": []interface{}{}}},
+	}
+}
+
+// This method is for serving the OpenAPI document
+func apiOpenAPI(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("Endpoint Hit: /api/openapi.json")
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	enc.Encode(openAPISpec())
+}
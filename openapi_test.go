@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/umerf52/ExpertFlow-Programming-Assignment/priorityqueue"
+)
+
+// This function checks value against schema, resolving references with components
+func validateSchema(value interface{}, schema map[string]interface{}, components map[string]interface{}, at string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		target, _ := components[strings.TrimPrefix(ref, "#/components/schemas/")].(map[string]interface{})
+		return validateSchema(value, target, components, at)
+	}
+	if value == nil {
+		if schema["nullable"] == true {
+			return nil
+		}
+		if allOf, ok := schema["allOf"].([]interface{}); ok && len(allOf) == 1 && schema["nullable"] == true {
+			return nil
+		}
+		if len(schema) == 0 {
+			return nil
+		}
+		return fmt.Errorf("%s is null", at)
+	}
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			if err := validateSchema(value, sub.(map[string]interface{}), components, at); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		matches := 0
+		errs := make([]string, 0)
+		for _, sub := range oneOf {
+			if err := validateSchema(value, sub.(map[string]interface{}), components, at); err != nil {
+				errs = append(errs, err.Error())
+			} else {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fmt.Errorf("%s matches %d schemas of oneOf: %s", at, matches, strings.Join(errs, "; "))
+		}
+		return nil
+	}
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s is not an object", at)
+		}
+		if required, ok := schema["required"].([]string); ok {
+			for _, name := range required {
+				if _, ok := object[name]; !ok {
+					return fmt.Errorf("%s has no property %s", at, name)
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, v := range object {
+			if property, ok := properties[name]; ok {
+				if err := validateSchema(v, property.(map[string]interface{}), components, at+"."+name); err != nil {
+					return err
+				}
+			} else if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+				if err := validateSchema(v, additional, components, at+"."+name); err != nil {
+					return err
+				}
+			} else {
+				return fmt.Errorf("%s has undocumented property %s", at, name)
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s is not an array", at)
+		}
+		for i, v := range array {
+			if err := validateSchema(v, schema["items"].(map[string]interface{}), components, at+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s is not a string", at)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s is not a boolean", at)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s is not a number", at)
+		}
+	case "integer":
+		number, ok := value.(float64)
+		if !ok || number != float64(int64(number)) {
+			return fmt.Errorf("%s is not an integer", at)
+		}
+	}
+	return nil
+}
+
+// This test checks that every route of newRouter is documented and nothing else is
+func TestOpenAPICoversRoutes(t *testing.T) {
+	paths := openAPISpec()["paths"].(map[string]interface{})
+	documented := make(map[string]bool)
+	for path, item := range paths {
+		for method := range item.(map[string]interface{}) {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	routes := make(map[string]bool)
+	newRouter().Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, _ := route.GetPathTemplate()
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{"GET"} // routes without methods are documented as GET
+		}
+		for _, method := range methods {
+			routes[method+" "+path] = true
+			if !documented[method+" "+path] {
+				t.Errorf("openAPISpec() failed. Route %s %s is not documented", method, path)
+			}
+		}
+		return nil
+	})
+	for operation := range documented {
+		if !routes[operation] {
+			t.Errorf("openAPISpec() failed. %s is documented but not routed", operation)
+		}
+	}
+}
+
+// This test calls every JSON endpoint and checks the response against the served document
+func TestOpenAPIResponsesMatchSpec(t *testing.T) {
+	PQ = PriorityQueue{queue: priorityqueue.New(priorityqueue.WithName("DefaultQueue"), priorityqueue.WithCapacity(SIZE))}
+	AR = AgentRegistry{agents: make(map[string]*Agent)}
+	DP = newDispatcher(&PQ, &AR, logNotifier{}, DISPATCHPOLICY, OFFERTIMEOUT)
+	IK = IdempotencyStore{entries: make(map[string]IdempotencyEntry)}
+	router := newRouter()
+
+	rec := doRequest(router, "GET", "/api/openapi.json", "", nil)
+	spec := make(map[string]interface{})
+	if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("apiOpenAPI() failed. %s", err)
+	}
+	components := spec["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	paths := spec["paths"].(map[string]interface{})
+	for _, component := range components {
+		fixRequired(component.(map[string]interface{}))
+	}
+
+	cases := []struct {
+		method, url, template, body string
+	}{
+		{"GET", "/api/v1.0/queue/service", "/api/v1.0/queue/service", ""},
+		{"POST", "/api/v1.0/queue/enqueue", "/api/v1.0/queue/enqueue", ""},
+		{"POST", "/api/v1.0/queue/enqueue", "/api/v1.0/queue/enqueue", `{"customerName":"c1","description":"d","priorityWeight":5,"requiredSkills":{"english":3}}`},
+		{"POST", "/api/v1.0/queue/enqueue", "/api/v1.0/queue/enqueue", `{"customerName":"c2","description":"d","priorityWeight":5,"ttlInSec":-1}`},
+		{"POST", "/api/v1.0/queue/enqueue", "/api/v1.0/queue/enqueue", `{"customerName":"c3","description":"d","priorityWeight":7,"notBefore":"2100-01-01T00:00:00Z"}`},
+		{"GET", "/api/v1.0/queue/list", "/api/v1.0/queue/list", ""},
+		{"GET", "/api/v1.0/queue/detail", "/api/v1.0/queue/detail", ""},
+		{"PUT", "/api/v1.0/queue/1/priority", "/api/v1.0/queue/{id}/priority", `{"priorityWeight":9}`},
+		{"PUT", "/api/v1.0/queue/1/priority", "/api/v1.0/queue/{id}/priority", `{"priorityWeight":0}`},
+		{"PUT", "/api/v1.0/queue/99/priority", "/api/v1.0/queue/{id}/priority", `{"priorityWeight":9}`},
+		{"PUT", "/api/v1.0/queue/ordering", "/api/v1.0/queue/ordering", `{"ordering":"EDF"}`},
+		{"PUT", "/api/v1.0/queue/ordering", "/api/v1.0/queue/ordering", `{"ordering":"RANDOM"}`},
+		{"PUT", "/api/v1.0/queue/capacity", "/api/v1.0/queue/capacity", `{"capacity":1}`},
+		{"PUT", "/api/v1.0/queue/capacity", "/api/v1.0/queue/capacity", `{"capacity":0}`},
+		{"PUT", "/api/v1.0/queue/capacity", "/api/v1.0/queue/capacity", `{"capacity":100}`},
+		{"PUT", "/api/v1.0/queue/quotas", "/api/v1.0/queue/quotas", `{"rateLimit":-1}`},
+		{"PUT", "/api/v1.0/queue/quotas", "/api/v1.0/queue/quotas", `{}`},
+		{"GET", "/api/v1.0/queue/scheduled", "/api/v1.0/queue/scheduled", ""},
+		{"GET", "/api/v1.0/queue/customers/c1", "/api/v1.0/queue/customers/{customerName}", ""},
+		{"GET", "/api/v1.0/queue/requests/unknown", "/api/v1.0/queue/requests/{token}", ""},
+		{"DELETE", "/api/v1.0/queue/requests/unknown", "/api/v1.0/queue/requests/{token}", ""},
+		{"GET", "/api/v1.0/requests/0", "/api/v1.0/requests/{id}", ""},
+		{"GET", "/api/v1.0/requests/99", "/api/v1.0/requests/{id}", ""},
+		{"POST", "/api/v1.0/agents", "/api/v1.0/agents", `{"id":"a1","name":"Agent","skills":{"english":5}}`},
+		{"POST", "/api/v1.0/agents", "/api/v1.0/agents", `{"id":"a1","name":"Agent"}`},
+		{"GET", "/api/v1.0/agents", "/api/v1.0/agents", ""},
+		{"GET", "/api/v1.0/agents/a1", "/api/v1.0/agents/{id}", ""},
+		{"GET", "/api/v1.0/agents/a2", "/api/v1.0/agents/{id}", ""},
+		{"GET", "/api/v1.0/agents/a1/offer", "/api/v1.0/agents/{id}/offer", ""},
+		{"POST", "/api/v1.0/offers/1/accept", "/api/v1.0/offers/{id}/accept", ""},
+		{"GET", "/api/v1.0/queue/service?agentId=a1", "/api/v1.0/queue/service", ""},
+		{"GET", "/api/v1.0/agents/a1", "/api/v1.0/agents/{id}", ""},
+		{"DELETE", "/api/v1.0/agents/a1", "/api/v1.0/agents/{id}", ""},
+		{"PUT", "/api/v1.0/agents/a1/state", "/api/v1.0/agents/{id}/state", `{"state":"AVAILABLE"}`},
+		{"PUT", "/api/v1.0/agents/a1/state", "/api/v1.0/agents/{id}/state", `{"state":"SLEEPING"}`},
+		{"DELETE", "/api/v1.0/agents/a1", "/api/v1.0/agents/{id}", ""},
+		{"POST", "/api/v1.0/queue/enqueue:batch", "/api/v1.0/queue/enqueue:batch", `{"mode":"BEST_EFFORT","customerRequests":[{"customerName":"c4","description":"d","priorityWeight":2},{"customerName":"c5"}]}`},
+		{"POST", "/api/v1.0/queue/enqueue:batch", "/api/v1.0/queue/enqueue:batch", `{}`},
+		{"POST", "/api/v1.0/queue/renege:batch", "/api/v1.0/queue/renege:batch", `{"mode":"BEST_EFFORT","ids":[4,99]}`},
+		{"DELETE", "/api/v1.0/queue/renege/2", "/api/v1.0/queue/renege/{id}", ""},
+		{"DELETE", "/api/v1.0/queue/renege/2", "/api/v1.0/queue/renege/{id}", ""},
+		{"DELETE", "/api/v1.0/queue/scheduled/3", "/api/v1.0/queue/scheduled/{id}", ""},
+		{"DELETE", "/api/v1.0/queue/scheduled/3", "/api/v1.0/queue/scheduled/{id}", ""},
+		{"GET", "/api/v1.0/SystemInfo", "/api/v1.0/SystemInfo", ""},
+		{"GET", "/api/v1.0/stats", "/api/v1.0/stats", ""},
+		{"GET", "/api/v1.0/stats?threshold=x", "/api/v1.0/stats", ""},
+		{"GET", "/api/v1.0/reports?groupBy=priorityWeight", "/api/v1.0/reports", ""},
+		{"GET", "/api/v1.0/reports?groupBy=agent", "/api/v1.0/reports", ""},
+		{"GET", "/api/v1.0/events", "/api/v1.0/events", ""},
+		{"GET", "/healthz", "/healthz", ""},
+		{"GET", "/readyz", "/readyz", ""},
+	}
+	for _, c := range cases {
+		rec := doRequest(router, c.method, c.url, c.body, nil)
+		operation, ok := paths[c.template].(map[string]interface{})[strings.ToLower(c.method)].(map[string]interface{})
+		if !ok {
+			t.Errorf("%s %s is not documented", c.method, c.template)
+			continue
+		}
+		response, ok := operation["responses"].(map[string]interface{})[strconv.Itoa(rec.Code)].(map[string]interface{})
+		if !ok {
+			t.Errorf("%s %s failed. Status %d is not documented", c.method, c.url, rec.Code)
+			continue
+		}
+		schema := response["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"].(map[string]interface{})
+		var body interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Errorf("%s %s failed. Response is not JSON. %s", c.method, c.url, err)
+			continue
+		}
+		if err := validateSchema(body, schema, components, "response"); err != nil {
+			t.Errorf("%s %s failed. Response does not match the spec. %s", c.method, c.url, err)
+		}
+	}
+}
+
+// This function turns required lists decoded from JSON back into []string
+func fixRequired(schema map[string]interface{}) {
+	if required, ok := schema["required"].([]interface{}); ok {
+		names := make([]string, 0, len(required))
+		for _, name := range required {
+			names = append(names, name.(string))
+		}
+		sort.Strings(names)
+		schema["required"] = names
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/umerf52/ExpertFlow-Programming-Assignment/priorityqueue"
+)
+
+// AGINGRATE is the PriorityWeight a request gains for every second it waits with the AGING ordering
+var AGINGRATE = 1.0 / 60
+
+// HIGHWEIGHT is the lowest PriorityWeight of the high class of the WEIGHTED_FAIR ordering
+var HIGHWEIGHT = 8
+
+// HIGHSHARE is the share of service of the high class of the WEIGHTED_FAIR ordering
+var HIGHSHARE = 0.7
+
+// ACCOUNTSHARES maps accounts to their share of service with the FAIR_SHARE ordering, other accounts get 1
+var ACCOUNTSHARES = map[string]float64{}
+
+// This function parses comma separated account:share entries, e.g. "acme:3,globex:1"
+func parseAccountShares(config string) (map[string]float64, error) {
+	shares := make(map[string]float64)
+	for _, entry := range strings.Split(config, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) != 2 {
+			return nil, errors.New("account share must be account:share, got " + entry)
+		}
+		share, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || share <= 0 {
+			return nil, errors.New("share of account " + parts[0] + " must be a positive number")
+		}
+		shares[parts[0]] = share
+	}
+	return shares, nil
+}
+
+// This function returns the strategy of the ordering with name ordering
+func newStrategy(ordering string) (priorityqueue.Strategy[*CustomerRequest], error) {
+	switch ordering {
+	case priorityqueue.PRIORITY:
+		return priorityqueue.ByWeight(), nil
+	case priorityqueue.EDF:
+		return priorityqueue.EarliestDeadlineFirst(), nil
+	case priorityqueue.AGING:
+		return priorityqueue.Aging(AGINGRATE), nil
+	case priorityqueue.WEIGHTEDFAIR:
+		return priorityqueue.WeightedFair(HIGHWEIGHT, HIGHSHARE), nil
+	case priorityqueue.FAIRSHARE:
+		return priorityqueue.FairShare(ACCOUNTSHARES), nil
+	}
+	return nil, errors.New("ordering must be PRIORITY, EDF, AGING, WEIGHTED_FAIR or FAIR_SHARE")
+}
+
+// This method is for switching the ordering of a live queue, the waiting requests are re-heapified
+func setOrdering(pq *PriorityQueue, ordering string, isConsole bool) (OrderingJSON, error) {
+	logger.Printf("setting ordering to %s, isConsole: %t", ordering, isConsole)
+	strategy, err := newStrategy(ordering)
+	if err != nil {
+		logger.Printf("error setting ordering. %s", err.Error())
+		if isConsole {
+			fmt.Println(err)
+		}
+		return OrderingJSON{}, err
+	}
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	pq.queue.SetStrategy(strategy)
+	return OrderingJSON{Ordering: strategy.Name()}, nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/umerf52/ExpertFlow-Programming-Assignment/priorityqueue"
+)
+
+// Overflow policies, they decide what happens to a request enqueued while the queue is full
+const (
+	REJECT = "REJECT" // the request is refused
+	EVICT  = "EVICT"  // the lowest priority, newest request makes room if the new request outranks it
+	SPILL  = "SPILL"  // the request waits in the overflow queue, by priority, until there is room
+	BUFFER = "BUFFER" // the request waits in the buffer, first come first served, until there is room
+)
+
+// OVERFLOWCAPACITY is the number of requests the overflow queue of the SPILL policy holds
+var OVERFLOWCAPACITY = 100
+
+// BUFFERCAPACITY is the number of requests the buffer of the BUFFER policy holds
+var BUFFERCAPACITY = 100
+
+// This method is for setting the overflow policy of pq, requests that overflowed before are kept
+func setOverflowPolicy(pq *PriorityQueue, policy string) error {
+	if policy != REJECT && policy != EVICT && policy != SPILL && policy != BUFFER {
+		return errors.New("overflow policy must be REJECT, EVICT, SPILL or BUFFER")
+	}
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	if policy == SPILL && pq.overflow == nil {
+		pq.overflow = priorityqueue.New(
+			priorityqueue.WithName(pq.queue.Name()+"Overflow"),
+			priorityqueue.WithDescription("Overflow of "+pq.queue.Name()),
+			priorityqueue.WithCapacity(OVERFLOWCAPACITY),
+			priorityqueue.WithLogger(logger))
+	}
+	logger.Printf("overflow policy of %s is %s", pq.queue.Name(), policy)
+	pq.overflowPolicy = policy
+	return nil
+}
+
+// This function applies the overflow policy to cr, which found pq full. It returns the reason cr was
+// accepted for: EVICT when a request was evicted to make room, SPILL or BUFFER when cr waits outside
+// of the queue. An empty reason means cr is rejected. pq.mutex must be held.
+func overflow(pq *PriorityQueue, cr *CustomerRequest) string {
+	switch pq.overflowPolicy {
+	case EVICT:
+		lowest, err := pq.queue.Lowest()
+		if err != nil || !pq.queue.Less(cr, lowest) {
+			return ""
+		}
+		evict(pq, lowest)
+		if pq.queue.Enqueue(cr) != nil {
+			return ""
+		}
+		return EVICT
+	case SPILL, BUFFER:
+		if overflowRoom(pq) < 1 {
+			return ""
+		}
+		pq.queue.Reserve(cr)
+		hold(pq, cr)
+		return pq.overflowPolicy
+	}
+	return ""
+}
+
+// This function returns the number of requests the overflow queue or buffer of pq can take
+func overflowRoom(pq *PriorityQueue) int {
+	switch {
+	case pq.overflowPolicy == SPILL && pq.overflow != nil:
+		return OVERFLOWCAPACITY - spilledLen(pq)
+	case pq.overflowPolicy == BUFFER:
+		return BUFFERCAPACITY - len(pq.buffer)
+	}
+	return 0
+}
+
+// This function puts cr, which already has its ID, in the overflow queue or buffer of pq until there is room
+func hold(pq *PriorityQueue, cr *CustomerRequest) {
+	if pq.overflowedIn == nil {
+		pq.overflowedIn = make(map[int]string)
+	}
+	if pq.overflowPolicy == SPILL {
+		pq.overflowedIn[cr.ID] = pq.overflow.Name()
+		pq.overflow.Restore(cr)
+		return
+	}
+	pq.overflowedIn[cr.ID] = pq.queue.Name() + "Buffer"
+	if cr.EnqueueTime.IsZero() {
+		cr.EnqueueTime = time.Now()
+	}
+	// A buffered request expires like a waiting one, so its Deadline is calculated from TTLInSec now
+	cr.Deadline = priorityqueue.CustomerRequests.Deadline(cr)
+	pq.buffer = append(pq.buffer, cr)
+}
+
+// This function drops the waiting cr to make room for a request that outranks it
+func evict(pq *PriorityQueue, cr *CustomerRequest) {
+	if _, err := pq.queue.Remove(cr.ID); err != nil {
+		return
+	}
+	drop(pq, cr)
+	pq.evictedCount++
+	recordEvent(pq, "EVICTED", cr, "OVERFLOW")
+	// evictions are left out of the service level metrics like in SystemInfo and reports, only customers abandon requests
+	recordCompleted(pq, cr, EVICTED, "", time.Now())
+}
+
+// This function moves requests that overflowed into pq while there is room, the buffer is emptied
+// first come first served and the overflow queue by priority. pq.mutex must be held.
+func refill(pq *PriorityQueue) {
+	for !pq.queue.IsFull(pq.offered) {
+		if len(pq.buffer) > 0 {
+			cr := pq.buffer[0]
+			pq.buffer[0] = nil // avoid memory leak
+			pq.buffer = pq.buffer[1:]
+			delete(pq.overflowedIn, cr.ID)
+			pq.queue.Restore(cr)
+			recordEvent(pq, "ENQUEUED", cr, BUFFER)
+			continue
+		}
+		if pq.overflow == nil {
+			return
+		}
+		cr, err := pq.overflow.Dequeue()
+		if err != nil {
+			return
+		}
+		delete(pq.overflowedIn, cr.ID)
+		pq.queue.Restore(cr)
+		recordEvent(pq, "ENQUEUED", cr, SPILL)
+	}
+}
+
+// This function takes the request with id=ID out of the buffer or the overflow queue
+func removeOverflowed(pq *PriorityQueue, ID int) (*CustomerRequest, error) {
+	for i, cr := range pq.buffer {
+		if cr.ID == ID {
+			pq.buffer = append(pq.buffer[:i], pq.buffer[i+1:]...)
+			return cr, nil
+		}
+	}
+	if pq.overflow != nil {
+		if cr, err := pq.overflow.Remove(ID); err == nil {
+			return cr, nil
+		}
+		if cr, err := pq.overflow.Cancel(ID); err == nil {
+			return cr, nil
+		}
+	}
+	return nil, priorityqueue.ErrNotFound
+}
+
+// This function returns the request with id=ID from the buffer or the overflow queue
+func getOverflowed(pq *PriorityQueue, ID int) (*CustomerRequest, error) {
+	for _, cr := range pq.buffer {
+		if cr.ID == ID {
+			return cr, nil
+		}
+	}
+	if pq.overflow != nil {
+		return pq.overflow.Get(ID)
+	}
+	return nil, priorityqueue.ErrNotFound
+}
+
+// This function abandons the overflowed requests whose Deadline is not after now and promotes
+// the due scheduled requests of the overflow queue
+func reapOverflowed(pq *PriorityQueue, now time.Time) []*CustomerRequest {
+	expired := make([]*CustomerRequest, 0)
+	kept := pq.buffer[:0]
+	for _, cr := range pq.buffer {
+		if cr.Deadline != nil && !cr.Deadline.After(now) {
+			expired = append(expired, cr)
+		} else {
+			kept = append(kept, cr)
+		}
+	}
+	for i := len(kept); i < len(pq.buffer); i++ {
+		pq.buffer[i] = nil // avoid memory leak
+	}
+	pq.buffer = kept
+	if pq.overflow != nil {
+		pq.overflow.Promote(now)
+		expired = append(expired, pq.overflow.Expire(now)...)
+	}
+	return expired
+}
+
+// This function returns the overflow policy of pq
+func overflowPolicy(pq *PriorityQueue) string {
+	if pq.overflowPolicy == "" {
+		return REJECT
+	}
+	return pq.overflowPolicy
+}
+
+// This function returns the number of requests waiting in the overflow queue
+func spilledLen(pq *PriorityQueue) int {
+	if pq.overflow == nil {
+		return 0
+	}
+	return pq.overflow.Len() + pq.overflow.ScheduledLen()
+}
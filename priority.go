@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// This method is for changing the PriorityWeight of a waiting Customer Request
+func changePriority(pq *PriorityQueue, ID int, priorityWeight int, isConsole bool) (CustomerRequest, error) {
+	logger.Printf("changing priority of %d to %d, isConsole: %t", ID, priorityWeight, isConsole)
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	cr, err := pq.queue.Update(ID, func(cr *CustomerRequest) { cr.PriorityWeight = priorityWeight })
+	if err != nil {
+		logger.Printf("id %d not found", ID)
+		if isConsole {
+			fmt.Println(err)
+		}
+		return CustomerRequest{}, errors.New(err.Error())
+	}
+	recordEvent(pq, "PRIORITY_CHANGED", cr, "")
+
+	changed := CustomerRequest{
+		ID:             cr.ID,
+		PriorityWeight: cr.PriorityWeight,
+		CustomerName:   cr.CustomerName,
+		Description:    cr.Description,
+		EnqueueTime:    cr.EnqueueTime,
+		TTLInSec:       cr.TTLInSec,
+		Deadline:       cr.Deadline,
+		RequiredSkills: cr.RequiredSkills,
+		Account:        cr.Account}
+	if isConsole {
+		jsonData, _ := json.MarshalIndent(changed, "", "    ")
+		fmt.Println(string(jsonData))
+	}
+	return changed, nil
+}
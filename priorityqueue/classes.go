@@ -0,0 +1,171 @@
+package priorityqueue
+
+import "sort"
+
+// A class holds the waiting items of one class of the Strategy
+type class[T any] struct {
+	name string
+	heap entryHeap[T]
+	// start is the virtual time at which the next item of the class starts being served in a fluid system
+	// where every waiting class is served at the rate of its share at once. Of the classes that have started,
+	// the one that would finish its item first is served (WF2Q+).
+	start float64
+}
+
+// Strategy returns the strategy that orders the queue
+func (pq *PriorityQueue[T]) Strategy() Strategy[T] { return pq.strategy }
+
+// SetStrategy changes the strategy that orders the queue. The waiting items are split into the classes of s
+// and the heaps are rebuilt, so the strategy of a live queue can be switched at any time.
+func (pq *PriorityQueue[T]) SetStrategy(s Strategy[T]) {
+	waiting := pq.waiting()
+	pq.strategy = s
+	pq.classes = nil
+	pq.byClass = make(map[string]*class[T])
+	pq.vtime = 0
+	for _, e := range waiting {
+		e.class = pq.classOf(e.value)
+		e.class.heap.entries = append(e.class.heap.entries, e)
+	}
+	for _, c := range pq.classes {
+		c.heap.init()
+	}
+	pq.lowest.init() // the order of the lowest heap is the one of s now
+	pq.logger.Printf("ordering by %s", s.Name())
+}
+
+// Classes returns the number of waiting items of every class
+func (pq *PriorityQueue[T]) Classes() map[string]int {
+	classes := make(map[string]int, len(pq.classes))
+	for _, c := range pq.classes {
+		if c.heap.len() > 0 {
+			classes[c.name] = c.heap.len()
+		}
+	}
+	return classes
+}
+
+// This method returns the class of v, it is created if v is the first of its class
+func (pq *PriorityQueue[T]) classOf(v T) *class[T] {
+	name := pq.strategy.Class(v)
+	if c, ok := pq.byClass[name]; ok {
+		return c
+	}
+	less := pq.strategy.Less
+	c := &class[T]{name: name, start: pq.vtime, heap: entryHeap[T]{
+		less: func(a, b *entry[T]) bool { return less(a.value, b.value) },
+		at:   func(e *entry[T]) *int { return &e.index }}}
+	pq.byClass[name] = c
+	pq.classes = append(pq.classes, c)
+	return c
+}
+
+// This method puts e in the heap of its class. A class that was not waiting does not keep the turns
+// it missed, it starts at the virtual time.
+func (pq *PriorityQueue[T]) push(e *entry[T]) {
+	e.class = pq.classOf(e.value)
+	if e.class.heap.len() == 0 && e.class.start < pq.vtime {
+		e.class.start = pq.vtime
+	}
+	e.class.heap.push(e)
+	pq.lowest.push(e)
+	pq.count++
+}
+
+// This method takes the waiting e out of the heap of its class
+func (pq *PriorityQueue[T]) unlink(e *entry[T]) {
+	e.class.heap.remove(e.index)
+	pq.lowest.remove(e.lowIndex)
+	pq.count--
+}
+
+// This method reports if e is waiting
+func (pq *PriorityQueue[T]) isWaiting(e *entry[T]) bool {
+	return e.class != nil && e.class.heap.contains(e)
+}
+
+// This method returns the class that is served next, nil if no item is waiting.
+// Classes that are not waiting and have no turns to catch up are dropped.
+func (pq *PriorityQueue[T]) next() *class[T] {
+	classes := pq.byTurn()
+	if len(classes) == 0 {
+		return nil
+	}
+	return classes[0]
+}
+
+// This method moves c on by one item after one of its items was served. The virtual time moves on by the
+// time the item takes in the fluid system, where the waiting classes share the service.
+func (pq *PriorityQueue[T]) charge(c *class[T]) {
+	vtime, shares := pq.now(), pq.share(c)
+	for _, other := range pq.classes {
+		if other != c && other.heap.len() > 0 {
+			shares += pq.share(other)
+		}
+	}
+	c.start += 1 / pq.share(c)
+	pq.vtime = vtime + 1/shares
+}
+
+// This method returns the share of c, shares that are not positive count as 1
+func (pq *PriorityQueue[T]) share(c *class[T]) float64 {
+	if share := pq.strategy.Share(c.name); share > 0 {
+		return share
+	}
+	return 1
+}
+
+// This method returns the virtual time, it is never before the start of the waiting class that starts first
+func (pq *PriorityQueue[T]) now() float64 {
+	first := -1.0
+	for _, c := range pq.classes {
+		if c.heap.len() > 0 && (first < 0 || c.start < first) {
+			first = c.start
+		}
+	}
+	if first > pq.vtime {
+		return first
+	}
+	return pq.vtime
+}
+
+// This method returns the waiting classes in the order they are served: the classes that have started
+// by the time they would finish, then the others. Classes that are not waiting and have no turns to
+// catch up are dropped.
+func (pq *PriorityQueue[T]) byTurn() []*class[T] {
+	classes := make([]*class[T], 0, len(pq.classes))
+	kept := pq.classes[:0]
+	for _, c := range pq.classes {
+		if c.heap.len() == 0 && c.start <= pq.vtime {
+			delete(pq.byClass, c.name)
+			continue
+		}
+		kept = append(kept, c)
+		if c.heap.len() > 0 {
+			classes = append(classes, c)
+		}
+	}
+	for i := len(kept); i < len(pq.classes); i++ {
+		pq.classes[i] = nil // avoid memory leak
+	}
+	pq.classes = kept
+
+	vtime := pq.now()
+	started := func(c *class[T]) bool { return c.start <= vtime+1e-9 }
+	sort.SliceStable(classes, func(i, j int) bool {
+		if started(classes[i]) != started(classes[j]) {
+			return started(classes[i])
+		}
+		return classes[i].start+1/pq.share(classes[i]) < classes[j].start+1/pq.share(classes[j])-1e-9
+	})
+	return classes
+}
+
+// This method returns the waiting entries of all classes
+func (pq *PriorityQueue[T]) waiting() []*entry[T] {
+	entries := make([]*entry[T], 0, pq.count)
+	for _, c := range pq.classes {
+		entries = append(entries, c.heap.entries...)
+	}
+	return entries
+}
@@ -0,0 +1,49 @@
+package priorityqueue
+
+import "time"
+
+// An CustomerRequest is something we manage in a priority queue.
+type CustomerRequest struct {
+	ID             int       `json:"id"`
+	CustomerName   string    `json:"customerName"`
+	Description    string    `json:"description"`
+	PriorityWeight int       `json:"priorityWeight"`
+	EnqueueTime    time.Time `json:"enqueueTime"`
+	// TTLInSec and Deadline are optional, the request is abandoned automatically once Deadline has passed.
+	// If only TTLInSec is given, Deadline is calculated from EnqueueTime.
+	TTLInSec float64    `json:"ttlInSec,omitempty"`
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// NotBefore is optional, the request waits in the scheduled set until then (e.g. for callbacks).
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+	// RequiredSkills maps every skill needed to handle the request (e.g. "lang:es", "product:billing")
+	// to the minimum proficiency, 0 accepts any proficiency.
+	RequiredSkills map[string]int `json:"requiredSkills,omitempty"`
+	// ExternalRef is an optional reference of the client, enqueues retried with the same reference
+	// return the original response instead of enqueueing the customer twice
+	ExternalRef string `json:"externalRef,omitempty"`
+	// Account is the optional account (tenant) of the customer, FairShare serves the accounts in turns
+	Account string `json:"account,omitempty"`
+}
+
+// CustomerRequests is the Item of the default PriorityQueue. Requests with the highest PriorityWeight are
+// dequeued first, the queue gives them their ID and Deadline is calculated from TTLInSec if it is missing.
+var CustomerRequests = Item[*CustomerRequest]{
+	// We want Pop to give us the highest, not lowest, PriorityWeight so we use greater than here.
+	Less:        func(a, b *CustomerRequest) bool { return a.PriorityWeight > b.PriorityWeight },
+	Key:         func(cr *CustomerRequest) int { return cr.ID },
+	SetKey:      func(cr *CustomerRequest, id int) { cr.ID = id },
+	EnqueueTime: func(cr *CustomerRequest) *time.Time { return &cr.EnqueueTime },
+	Deadline: func(cr *CustomerRequest) *time.Time {
+		if cr.Deadline == nil && cr.TTLInSec > 0 {
+			deadline := cr.EnqueueTime.Add(time.Duration(cr.TTLInSec * float64(time.Second)))
+			cr.Deadline = &deadline
+		}
+		return cr.Deadline
+	},
+	NotBefore: func(cr *CustomerRequest) *time.Time { return cr.NotBefore },
+}
+
+// New returns an empty PriorityQueue of CustomerRequests
+func New(options ...Option) *PriorityQueue[*CustomerRequest] {
+	return NewOf(CustomerRequests, options...)
+}
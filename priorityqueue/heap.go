@@ -0,0 +1,130 @@
+// This is Priority Queue implementation of heap
+// The skeleton code was taken from Go's official container/heap package:
+// https://golang.org/pkg/container/heap/
+// It is typed over the entries of a PriorityQueue, so Push and Pop need no interface{} type assertions.
+
+package priorityqueue
+
+// An entryHeap holds entries ordered by less. at returns where an entry keeps its index in this heap,
+// as an entry can be in the heap of waiting items and in the deadline heap at the same time.
+type entryHeap[T any] struct {
+	entries []*entry[T]
+	less    func(a, b *entry[T]) bool
+	at      func(e *entry[T]) *int
+}
+
+func (h *entryHeap[T]) len() int { return len(h.entries) }
+
+func (h *entryHeap[T]) swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	*h.at(h.entries[i]) = i
+	*h.at(h.entries[j]) = j
+}
+
+// This method reports if e is in the heap
+func (h *entryHeap[T]) contains(e *entry[T]) bool {
+	i := *h.at(e)
+	return i >= 0 && i < len(h.entries) && h.entries[i] == e
+}
+
+// This method establishes the heap invariant after the entries were changed in place
+func (h *entryHeap[T]) init() {
+	n := len(h.entries)
+	for i, e := range h.entries {
+		*h.at(e) = i
+	}
+	for i := n/2 - 1; i >= 0; i-- {
+		h.down(i, n)
+	}
+}
+
+// push : Implementation of Heap's Push()
+func (h *entryHeap[T]) push(e *entry[T]) {
+	*h.at(e) = len(h.entries)
+	h.entries = append(h.entries, e)
+	h.up(len(h.entries) - 1)
+}
+
+// pop : Implementation of Heap's Pop()
+func (h *entryHeap[T]) pop() *entry[T] {
+	n := len(h.entries) - 1
+	h.swap(0, n)
+	h.down(0, n)
+	return h.truncate()
+}
+
+// This method removes and returns the entry at index i
+func (h *entryHeap[T]) remove(i int) *entry[T] {
+	n := len(h.entries) - 1
+	if n != i {
+		h.swap(i, n)
+		if !h.down(i, n) {
+			h.up(i)
+		}
+	}
+	return h.truncate()
+}
+
+// This method re-establishes the heap ordering after the entry at index i has changed its value
+func (h *entryHeap[T]) fix(i int) {
+	if !h.down(i, len(h.entries)) {
+		h.up(i)
+	}
+}
+
+// This method drops the removed entries in place, init has to be called afterwards
+func (h *entryHeap[T]) compact(removed map[*entry[T]]bool) {
+	kept := h.entries[:0]
+	for _, e := range h.entries {
+		if !removed[e] {
+			kept = append(kept, e)
+		} else if h.contains(e) {
+			*h.at(e) = -1
+		}
+	}
+	for i := len(kept); i < len(h.entries); i++ {
+		h.entries[i] = nil // avoid memory leak
+	}
+	h.entries = kept
+}
+
+// This method cuts the last entry off the heap
+func (h *entryHeap[T]) truncate() *entry[T] {
+	n := len(h.entries) - 1
+	e := h.entries[n]
+	h.entries[n] = nil // avoid memory leak
+	*h.at(e) = -1      // for safety
+	h.entries = h.entries[:n]
+	return e
+}
+
+func (h *entryHeap[T]) up(j int) {
+	for {
+		i := (j - 1) / 2 // parent
+		if i == j || !h.less(h.entries[j], h.entries[i]) {
+			break
+		}
+		h.swap(i, j)
+		j = i
+	}
+}
+
+func (h *entryHeap[T]) down(i0, n int) bool {
+	i := i0
+	for {
+		j1 := 2*i + 1
+		if j1 >= n || j1 < 0 { // j1 < 0 after int overflow
+			break
+		}
+		j := j1 // left child
+		if j2 := j1 + 1; j2 < n && h.less(h.entries[j2], h.entries[j1]) {
+			j = j2 // = 2*i + 2  // right child
+		}
+		if !h.less(h.entries[j], h.entries[i]) {
+			break
+		}
+		h.swap(i, j)
+		i = j
+	}
+	return i > i0
+}
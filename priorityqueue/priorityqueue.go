@@ -0,0 +1,676 @@
+// Package priorityqueue is a capacity bounded priority queue.
+//
+// Items are dequeued in the order of a comparator supplied by the caller. Every item has a unique ID,
+// given by the queue when it is enqueued or read with a key extractor, and can be looked up, updated
+// or removed (reneged) by it. Items may have a deadline, after which Expire abandons them, and a
+// NotBefore time, until which they wait in a scheduled set that counts towards capacity. A queue
+// created WithUnique also keeps a key of the items unique, e.g. to hold one request per customer.
+// Items being offered, e.g. to an agent, are taken out with Hold and finished with Serve or put back
+// with Restore.
+//
+// CustomerRequests are the default items, for other types an Item describes how they are handled:
+//
+//	pq := priorityqueue.New(priorityqueue.WithName("support"), priorityqueue.WithCapacity(100))
+//	_ = pq.Enqueue(&priorityqueue.CustomerRequest{CustomerName: "name", PriorityWeight: 5})
+//	cr, err := pq.Dequeue()
+//
+//	tickets := priorityqueue.NewOf(priorityqueue.Item[*Ticket]{
+//		Less: func(a, b *Ticket) bool { return a.Severity > b.Severity },
+//		Key:  func(t *Ticket) int { return t.Number }})
+//
+// A PriorityQueue is not safe for concurrent use, callers have to synchronize access.
+package priorityqueue
+
+import (
+	"errors"
+	"io"
+	"log"
+	"time"
+)
+
+// Errors returned by PriorityQueue
+var (
+	ErrFull      = errors.New("capacity reached")
+	ErrEmpty     = errors.New("queue is empty")
+	ErrNotFound  = errors.New("id not found")
+	ErrDuplicate = errors.New("id already in queue")
+	ErrExists    = errors.New("unique key already in queue")
+)
+
+// Item describes how a PriorityQueue handles values of type T. Less and Key are required.
+type Item[T any] struct {
+	// Less is the comparator, it reports if a is dequeued before b
+	Less func(a, b T) bool
+	// Key is the key extractor, it returns the ID of v
+	Key func(v T) int
+	// SetKey gives v the next ID when it is enqueued. If it is nil the IDs are left to the caller and
+	// enqueueing an ID that is already in the queue fails with ErrDuplicate.
+	SetKey func(v T, id int)
+	// EnqueueTime points to when v was enqueued, it is set to now if it is zero and to NotBefore when v
+	// is promoted. If it is nil the queue keeps the time itself. The oldest item is found by it.
+	EnqueueTime func(v T) *time.Time
+	// Deadline returns when v expires, nil if it never does. It is called when v starts waiting.
+	Deadline func(v T) *time.Time
+	// NotBefore returns until when v is scheduled, nil if it is due right away.
+	NotBefore func(v T) *time.Time
+}
+
+// An entry is what a PriorityQueue keeps about every value
+type entry[T any] struct {
+	value               T
+	id                  int
+	key                 string // key is the unique key of the value, see WithUnique
+	enqueued            time.Time
+	deadline, notBefore *time.Time
+	class               *class[T]
+	// The indexes are maintained by the heaps.
+	index          int // The index of the entry in the heap of its class.
+	deadlineIndex  int // The index of the entry in the deadline heap, -1 if it has no deadline.
+	scheduledIndex int // The index of the entry in the scheduled heap, -1 if it is not scheduled.
+	lowIndex       int // The index of the entry in the lowest heap while it is waiting.
+}
+
+// PriorityQueue holds waiting and scheduled items of type T
+type PriorityQueue[T any] struct {
+	item      Item[T]
+	strategy  Strategy[T]
+	classes   []*class[T]          // classes hold the waiting items ordered by the strategy
+	byClass   map[string]*class[T] // byClass holds the classes by name
+	vtime     float64              // vtime is the virtual time of the fair share between classes
+	count     int                  // count is the number of waiting items
+	deadlines entryHeap[T]         // deadlines holds the waiting items that have a Deadline
+	scheduled entryHeap[T]         // scheduled holds the items that are not due yet
+	lowest    entryHeap[T]         // lowest holds the waiting items, the one served last on top
+	byID      map[int]*entry[T]    // byID holds the waiting and scheduled items
+	unique    func(v T) string     // unique returns the unique key of an item, nil if keys need not be unique
+	byKey     map[string]*entry[T] // byKey holds the waiting, scheduled and held items by unique key
+	held      map[int]*entry[T]    // held holds the items taken out with Hold until they are served or restored
+	config
+}
+
+// config holds the settings shared by queues of every type
+type config struct {
+	name, description string
+	capacity, key     int // key is used to uniquely identify items
+	logger            *log.Logger
+	strategy          interface{} // strategy is the Strategy of the item type of the queue
+	unique            interface{} // unique is the unique key of the item type of the queue
+}
+
+// Option configures a PriorityQueue
+type Option func(*config)
+
+// WithName sets the name of the queue
+func WithName(name string) Option {
+	return func(c *config) { c.name = name }
+}
+
+// WithDescription sets the description of the queue
+func WithDescription(description string) Option {
+	return func(c *config) { c.description = description }
+}
+
+// WithCapacity sets the number of waiting and scheduled items the queue can hold, 0 means no limit
+func WithCapacity(capacity int) Option {
+	return func(c *config) { c.capacity = capacity }
+}
+
+// WithFirstID sets the ID of the first enqueued item, IDs are given in ascending order from there
+func WithFirstID(id int) Option {
+	return func(c *config) { c.key = id }
+}
+
+// WithLogger sets the logger of the queue, nothing is logged by default
+func WithLogger(logger *log.Logger) Option {
+	return func(c *config) { c.logger = logger }
+}
+
+// WithStrategy sets the strategy that orders the queue, ByWeight is used by default for CustomerRequests
+// and strict priority by Item.Less for other types. The strategy has to be of the item type of the queue.
+func WithStrategy[T any](s Strategy[T]) Option {
+	return func(c *config) { c.strategy = s }
+}
+
+// NewOf returns an empty PriorityQueue of values described by item
+func NewOf[T any](item Item[T], options ...Option) *PriorityQueue[T] {
+	pq := &PriorityQueue[T]{
+		item:   item,
+		byID:   make(map[int]*entry[T]),
+		config: config{logger: log.New(io.Discard, "", 0)}}
+	pq.deadlines = entryHeap[T]{
+		// Pop should give us the item that expires first.
+		less: func(a, b *entry[T]) bool { return a.deadline.Before(*b.deadline) },
+		at:   func(e *entry[T]) *int { return &e.deadlineIndex }}
+	pq.scheduled = entryHeap[T]{
+		// Pop should give us the item that is due first.
+		less: func(a, b *entry[T]) bool { return a.notBefore.Before(*b.notBefore) },
+		at:   func(e *entry[T]) *int { return &e.scheduledIndex }}
+	pq.lowest = entryHeap[T]{
+		// Pop should give us the item that is served last.
+		less: pq.lower,
+		at:   func(e *entry[T]) *int { return &e.lowIndex }}
+	for _, option := range options {
+		option(&pq.config)
+	}
+	s, ok := pq.config.strategy.(Strategy[T])
+	if !ok && pq.config.strategy != nil {
+		panic("priorityqueue: strategy is not of the item type of the queue")
+	} else if !ok {
+		s = StrategyFunc(PRIORITY, item.Less)
+	}
+	pq.SetStrategy(s)
+	unique, ok := pq.config.unique.(func(v T) string)
+	if !ok && pq.config.unique != nil {
+		panic("priorityqueue: unique key is not of the item type of the queue")
+	}
+	_ = pq.SetUnique(unique)
+	return pq
+}
+
+// Name returns the name of the queue
+func (pq *PriorityQueue[T]) Name() string { return pq.name }
+
+// Description returns the description of the queue
+func (pq *PriorityQueue[T]) Description() string { return pq.description }
+
+// Capacity returns the number of items the queue can hold, 0 means no limit
+func (pq *PriorityQueue[T]) Capacity() int { return pq.capacity }
+
+// SetCapacity changes the number of items the queue can hold, 0 means no limit. Items are never dropped,
+// a queue that holds more items than capacity is full until enough of them have left.
+func (pq *PriorityQueue[T]) SetCapacity(capacity int) {
+	pq.capacity = capacity
+	pq.logger.Printf("capacity set to %d", capacity)
+}
+
+// Len returns the number of waiting items, scheduled items are not included
+func (pq *PriorityQueue[T]) Len() int { return pq.count }
+
+// ScheduledLen returns the number of scheduled items
+func (pq *PriorityQueue[T]) ScheduledLen() int { return pq.scheduled.len() }
+
+// IsFull reports if there is no room for another item while reserved slots are taken outside of
+// the queue, e.g. by items that were dequeued but may be restored
+func (pq *PriorityQueue[T]) IsFull(reserved int) bool {
+	return pq.capacity > 0 && pq.count+pq.scheduled.len()+reserved >= pq.capacity
+}
+
+// Less reports if a is dequeued before b by the strategy, given that they are in the same class
+func (pq *PriorityQueue[T]) Less(a, b T) bool {
+	return pq.strategy.Less(a, b)
+}
+
+// Enqueue adds v to the queue, giving it the next ID if the Item has SetKey. v is scheduled if its
+// NotBefore is in the future. ErrFull is returned if the queue is at capacity and ErrExists if the unique
+// key of v is taken.
+func (pq *PriorityQueue[T]) Enqueue(v T) error {
+	if pq.IsFull(0) {
+		pq.logger.Printf("ERROR: inserting item. %s", ErrFull)
+		return ErrFull
+	}
+	if pq.isTaken(v) {
+		return ErrExists
+	}
+	if pq.item.SetKey == nil {
+		if _, ok := pq.byID[pq.item.Key(v)]; ok {
+			return ErrDuplicate
+		}
+	}
+	e := pq.newEntry(v)
+	if pq.schedule(e) {
+		pq.logger.Printf("successfully scheduled following:")
+		pq.logger.Println(e.id, e.value, e.notBefore)
+		return nil
+	}
+	pq.activate(e)
+	pq.logger.Printf("successfully inserted following:")
+	pq.logger.Println(e.id, e.value, e.enqueued)
+	return nil
+}
+
+// EnqueueAll enqueues all of vs like Enqueue, but with a single heap fix-up pass instead of one push each.
+// Nothing is enqueued if vs do not fit or one of their unique keys is taken.
+func (pq *PriorityQueue[T]) EnqueueAll(vs []T) error {
+	if pq.IsFull(len(vs) - 1) {
+		return ErrFull
+	}
+	if pq.item.SetKey == nil {
+		ids := make(map[int]bool, len(vs))
+		for _, v := range vs {
+			id := pq.item.Key(v)
+			if _, ok := pq.byID[id]; ok || ids[id] {
+				return ErrDuplicate
+			}
+			ids[id] = true
+		}
+	}
+	if pq.unique != nil {
+		keys := make(map[string]bool, len(vs))
+		for _, v := range vs {
+			if pq.isTaken(v) || keys[pq.unique(v)] {
+				return ErrExists
+			}
+			keys[pq.unique(v)] = true
+		}
+	}
+	changed := make(map[*class[T]]bool)
+	for _, v := range vs {
+		e := pq.newEntry(v)
+		if pq.schedule(e) {
+			continue
+		}
+		if e.deadline = pq.deadlineOf(v); e.deadline != nil {
+			pq.deadlines.entries = append(pq.deadlines.entries, e)
+		}
+		e.class = pq.classOf(v)
+		if e.class.heap.len() == 0 && e.class.start < pq.vtime {
+			e.class.start = pq.vtime
+		}
+		e.class.heap.entries = append(e.class.heap.entries, e)
+		pq.lowest.entries = append(pq.lowest.entries, e)
+		changed[e.class] = true
+		pq.count++
+	}
+	for c := range changed {
+		c.heap.init()
+	}
+	pq.deadlines.init()
+	pq.lowest.init()
+	pq.logger.Printf("successfully inserted %d items", len(vs))
+	return nil
+}
+
+// Restore puts v back in the queue after it was dequeued, keeping its ID and its EnqueueTime.
+// It is used to return an item that could not be handled, or to add an item that was given its ID
+// by Reserve. Capacity is not checked.
+func (pq *PriorityQueue[T]) Restore(v T) {
+	if e, ok := pq.held[pq.item.Key(v)]; ok {
+		delete(pq.held, e.id)
+		pq.unindex(e)
+	}
+	e := pq.entryOf(v)
+	if !pq.schedule(e) {
+		pq.activate(e)
+	}
+}
+
+// Reserve gives v the next ID without enqueueing it, e.g. while it is held elsewhere because the queue
+// is full. It can be added later with Restore. Nothing is done if the Item has no SetKey.
+func (pq *PriorityQueue[T]) Reserve(v T) {
+	if pq.item.SetKey != nil {
+		pq.item.SetKey(v, pq.key)
+		pq.key++
+	}
+}
+
+// Dequeue removes and returns the item that comes first by the strategy
+func (pq *PriorityQueue[T]) Dequeue() (T, error) {
+	c := pq.next()
+	if c == nil {
+		var zero T
+		return zero, ErrEmpty
+	}
+	e := c.heap.pop()
+	pq.lowest.remove(e.lowIndex)
+	pq.count--
+	pq.charge(c)
+	pq.forget(e)
+	return e.value, nil
+}
+
+// Peek returns the item that comes first by the strategy without removing it
+func (pq *PriorityQueue[T]) Peek() (T, error) {
+	c := pq.next()
+	if c == nil {
+		var zero T
+		return zero, ErrEmpty
+	}
+	return c.heap.entries[0].value, nil
+}
+
+// PeekFunc returns the item that comes first by the strategy among those for which match is true,
+// ErrNotFound is returned if there is none. Every waiting item may be visited.
+func (pq *PriorityQueue[T]) PeekFunc(match func(v T) bool) (T, error) {
+	if pq.count == 0 {
+		var zero T
+		return zero, ErrEmpty
+	}
+	for _, c := range pq.byTurn() {
+		var best *entry[T]
+		for _, e := range c.heap.entries {
+			if match(e.value) && (best == nil || pq.strategy.Less(e.value, best.value)) {
+				best = e
+			}
+		}
+		if best != nil {
+			return best.value, nil
+		}
+	}
+	var zero T
+	return zero, ErrNotFound
+}
+
+// Get returns the waiting or scheduled item with id
+func (pq *PriorityQueue[T]) Get(id int) (T, error) {
+	e, ok := pq.byID[id]
+	if !ok {
+		var zero T
+		return zero, ErrNotFound
+	}
+	return e.value, nil
+}
+
+// IsScheduled reports if v waits in the scheduled set
+func (pq *PriorityQueue[T]) IsScheduled(v T) bool {
+	e, ok := pq.byID[pq.item.Key(v)]
+	return ok && pq.scheduled.contains(e)
+}
+
+// Position returns the index of the waiting v in Items, or -1 if it is not waiting
+func (pq *PriorityQueue[T]) Position(v T) int {
+	e, ok := pq.byID[pq.item.Key(v)]
+	if !ok || !pq.isWaiting(e) {
+		return -1
+	}
+	position := e.index
+	for _, c := range pq.classes {
+		if c == e.class {
+			break
+		}
+		position += c.heap.len()
+	}
+	return position
+}
+
+// Remove removes the waiting item with id, e.g. when the customer reneges
+func (pq *PriorityQueue[T]) Remove(id int) (T, error) {
+	e, ok := pq.byID[id]
+	if !ok || !pq.isWaiting(e) {
+		var zero T
+		return zero, ErrNotFound
+	}
+	pq.unlink(e)
+	pq.forget(e)
+	return e.value, nil
+}
+
+// Take removes the waiting item with id to serve it, e.g. after it was found with PeekFunc.
+// Unlike Remove it counts towards the share of the class of the item.
+func (pq *PriorityQueue[T]) Take(id int) (T, error) {
+	e, ok := pq.byID[id]
+	if !ok || !pq.isWaiting(e) {
+		var zero T
+		return zero, ErrNotFound
+	}
+	pq.unlink(e)
+	pq.charge(e.class)
+	pq.forget(e)
+	return e.value, nil
+}
+
+// Hold takes the waiting item with id out of the queue while it is offered, e.g. to an agent. Its unique
+// key stays taken until it is served with Serve or put back with Restore, and it is only counted towards
+// the share of its class once it is served.
+func (pq *PriorityQueue[T]) Hold(id int) (T, error) {
+	e, ok := pq.byID[id]
+	if !ok || !pq.isWaiting(e) {
+		var zero T
+		return zero, ErrNotFound
+	}
+	pq.unlink(e)
+	if pq.deadlines.contains(e) {
+		pq.deadlines.remove(e.deadlineIndex)
+	}
+	delete(pq.byID, e.id)
+	if pq.held == nil {
+		pq.held = make(map[int]*entry[T])
+	}
+	pq.held[id] = e
+	return e.value, nil
+}
+
+// Serve ends the hold of the item with id after it was served, counting it towards the share of its class
+func (pq *PriorityQueue[T]) Serve(id int) (T, error) {
+	e, ok := pq.held[id]
+	if !ok {
+		var zero T
+		return zero, ErrNotFound
+	}
+	delete(pq.held, id)
+	pq.charge(e.class)
+	pq.unindex(e)
+	return e.value, nil
+}
+
+// RemoveAll removes the waiting items with ids with a single heap fix-up pass instead of one
+// removal each. The removed items are returned, unknown ids are ignored.
+func (pq *PriorityQueue[T]) RemoveAll(ids []int) []T {
+	removed := make(map[*entry[T]]bool, len(ids))
+	vs := make([]T, 0, len(ids))
+	for _, id := range ids {
+		if e, ok := pq.byID[id]; ok && !removed[e] && pq.isWaiting(e) {
+			removed[e] = true
+			vs = append(vs, e.value)
+		}
+	}
+	if len(vs) == 0 {
+		return vs
+	}
+	for _, c := range pq.classes {
+		c.heap.compact(removed)
+		c.heap.init()
+	}
+	pq.deadlines.compact(removed)
+	pq.deadlines.init()
+	pq.lowest.compact(removed)
+	pq.lowest.init()
+	pq.count -= len(vs)
+	for e := range removed {
+		delete(pq.byID, e.id)
+		pq.unindex(e)
+	}
+	return vs
+}
+
+// Cancel removes the scheduled item with id
+func (pq *PriorityQueue[T]) Cancel(id int) (T, error) {
+	e, ok := pq.byID[id]
+	if !ok || !pq.scheduled.contains(e) {
+		var zero T
+		return zero, ErrNotFound
+	}
+	pq.scheduled.remove(e.scheduledIndex)
+	pq.forget(e)
+	return e.value, nil
+}
+
+// Update lets change modify the waiting item with id, e.g. its priority, and restores the heap order.
+// The item moves to another class if the change puts it there.
+func (pq *PriorityQueue[T]) Update(id int, change func(v T)) (T, error) {
+	e, ok := pq.byID[id]
+	if !ok || !pq.isWaiting(e) {
+		var zero T
+		return zero, ErrNotFound
+	}
+	change(e.value)
+	if pq.unique != nil && pq.unique(e.value) != e.key {
+		pq.unindex(e)
+		pq.index(e)
+	}
+	if pq.strategy.Class(e.value) == e.class.name {
+		e.class.heap.fix(e.index)
+		pq.lowest.fix(e.lowIndex)
+	} else {
+		pq.unlink(e)
+		pq.push(e)
+	}
+	return e.value, nil
+}
+
+// Oldest returns the waiting item that was enqueued first
+func (pq *PriorityQueue[T]) Oldest() (T, error) {
+	waiting := pq.waiting()
+	if len(waiting) == 0 {
+		var zero T
+		return zero, ErrEmpty
+	}
+	oldest := waiting[0]
+	for _, e := range waiting[1:] {
+		if pq.enqueueTime(e).Before(pq.enqueueTime(oldest)) {
+			oldest = e
+		}
+	}
+	return oldest.value, nil
+}
+
+// Lowest returns the waiting item that would be served last by Less, the newest one of equal items
+func (pq *PriorityQueue[T]) Lowest() (T, error) {
+	if pq.lowest.len() == 0 {
+		var zero T
+		return zero, ErrEmpty
+	}
+	return pq.lowest.entries[0].value, nil
+}
+
+// LowestN returns up to n waiting items in the order Lowest returns them if they are removed one by one.
+// Only the top of the lowest heap is visited: a child can only come after its parent.
+func (pq *PriorityQueue[T]) LowestN(n int) []T {
+	var unused int // the candidates keep their index in the heaps of the queue
+	candidates := entryHeap[T]{less: pq.lower, at: func(*entry[T]) *int { return &unused }}
+	if n > 0 && pq.lowest.len() > 0 {
+		candidates.push(pq.lowest.entries[0])
+	}
+	vs := []T{}
+	for len(vs) < n && candidates.len() > 0 {
+		e := candidates.pop()
+		vs = append(vs, e.value)
+		for _, i := range []int{2*e.lowIndex + 1, 2*e.lowIndex + 2} {
+			if i < pq.lowest.len() {
+				candidates.push(pq.lowest.entries[i])
+			}
+		}
+	}
+	return vs
+}
+
+// This method reports if a is served after b, the newer one of equal items is lower
+func (pq *PriorityQueue[T]) lower(a, b *entry[T]) bool {
+	return pq.strategy.Less(b.value, a.value) ||
+		(!pq.strategy.Less(a.value, b.value) && pq.enqueueTime(a).After(pq.enqueueTime(b)))
+}
+
+// Items returns the waiting items in heap order class by class, the slice may be modified by the caller
+func (pq *PriorityQueue[T]) Items() []T {
+	return values(pq.waiting())
+}
+
+// ScheduledItems returns the scheduled items in heap order, the slice may be modified by the caller
+func (pq *PriorityQueue[T]) ScheduledItems() []T {
+	return values(pq.scheduled.entries)
+}
+
+// Promote moves every scheduled item that is due by now into the heap and returns them.
+// Their EnqueueTime becomes their NotBefore, as wait time is counted from when the customer asked to be served.
+func (pq *PriorityQueue[T]) Promote(now time.Time) []T {
+	promoted := make([]T, 0)
+	for pq.scheduled.len() > 0 && !pq.scheduled.entries[0].notBefore.After(now) {
+		e := pq.scheduled.pop()
+		e.enqueued = *e.notBefore
+		if pq.item.EnqueueTime != nil {
+			*pq.item.EnqueueTime(e.value) = e.enqueued
+		}
+		pq.activate(e)
+		pq.logger.Printf("promoted scheduled item %d", e.id)
+		promoted = append(promoted, e.value)
+	}
+	return promoted
+}
+
+// Expire removes and returns every waiting item whose Deadline is not after now.
+// Only the expired items are visited, as they are always at the top of the deadline heap.
+func (pq *PriorityQueue[T]) Expire(now time.Time) []T {
+	expired := make([]T, 0)
+	for pq.deadlines.len() > 0 && !pq.deadlines.entries[0].deadline.After(now) {
+		e := pq.deadlines.entries[0]
+		pq.unlink(e)
+		pq.forget(e)
+		pq.logger.Printf("item %d expired after %f seconds", e.id, now.Sub(pq.enqueueTime(e)).Seconds())
+		expired = append(expired, e.value)
+	}
+	return expired
+}
+
+// This method makes the entry of v, giving v the next ID if the Item has SetKey
+func (pq *PriorityQueue[T]) newEntry(v T) *entry[T] {
+	pq.Reserve(v)
+	return pq.entryOf(v)
+}
+
+// This method makes the entry of v with the ID v has and adds it to the index by ID
+func (pq *PriorityQueue[T]) entryOf(v T) *entry[T] {
+	e := &entry[T]{value: v, id: pq.item.Key(v), enqueued: time.Now(), index: -1, deadlineIndex: -1, scheduledIndex: -1}
+	if pq.item.EnqueueTime != nil {
+		if enqueued := pq.item.EnqueueTime(v); enqueued.IsZero() {
+			*enqueued = e.enqueued
+		} else {
+			e.enqueued = *enqueued
+		}
+	}
+	if pq.item.NotBefore != nil {
+		e.notBefore = pq.item.NotBefore(v)
+	}
+	pq.byID[e.id] = e
+	pq.index(e)
+	return e
+}
+
+// This method puts e in the scheduled set if it is not due yet and reports if it did
+func (pq *PriorityQueue[T]) schedule(e *entry[T]) bool {
+	if e.notBefore == nil || !e.notBefore.After(time.Now()) {
+		return false
+	}
+	pq.scheduled.push(e)
+	return true
+}
+
+// This method puts e in the heap of its class and in the deadline heap if it has a Deadline
+func (pq *PriorityQueue[T]) activate(e *entry[T]) {
+	if e.deadline = pq.deadlineOf(e.value); e.deadline != nil {
+		pq.deadlines.push(e)
+	}
+	pq.push(e)
+}
+
+// This method drops e, which was taken out of the heap, from the deadline heap and the index by ID
+func (pq *PriorityQueue[T]) forget(e *entry[T]) {
+	if pq.deadlines.contains(e) {
+		pq.deadlines.remove(e.deadlineIndex)
+	}
+	delete(pq.byID, e.id)
+	pq.unindex(e)
+}
+
+// This method returns the Deadline of v, nil if it has none
+func (pq *PriorityQueue[T]) deadlineOf(v T) *time.Time {
+	if pq.item.Deadline == nil {
+		return nil
+	}
+	return pq.item.Deadline(v)
+}
+
+// This method returns when e was enqueued, reading it from the value if the Item has EnqueueTime
+func (pq *PriorityQueue[T]) enqueueTime(e *entry[T]) time.Time {
+	if pq.item.EnqueueTime != nil {
+		return *pq.item.EnqueueTime(e.value)
+	}
+	return e.enqueued
+}
+
+// This function returns the values of entries
+func values[T any](entries []*entry[T]) []T {
+	vs := make([]T, len(entries))
+	for i, e := range entries {
+		vs[i] = e.value
+	}
+	return vs
+}
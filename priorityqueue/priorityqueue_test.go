@@ -0,0 +1,655 @@
+package priorityqueue
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// This function checks the heap invariants and the positions kept in the entries
+func checkHeap[T any](t *testing.T, pq *PriorityQueue[T]) {
+	t.Helper()
+	heaps := []*entryHeap[T]{&pq.deadlines, &pq.scheduled, &pq.lowest}
+	count := 0
+	for _, c := range pq.classes {
+		heaps = append(heaps, &c.heap)
+		count += c.heap.len()
+		for _, e := range c.heap.entries {
+			if e.class != c || pq.strategy.Class(e.value) != c.name {
+				t.Fatalf("%d is in the wrong class %s", e.id, c.name)
+			}
+		}
+	}
+	if count != pq.count || pq.lowest.len() != count {
+		t.Fatalf("count is %d and %d items are in the lowest heap, expected %d", pq.count, pq.lowest.len(), count)
+	}
+	for _, h := range heaps {
+		for i, e := range h.entries {
+			if *h.at(e) != i {
+				t.Fatalf("index of %d is %d, expected %d", e.id, *h.at(e), i)
+			}
+			if i > 0 && h.less(e, h.entries[(i-1)/2]) {
+				t.Fatalf("heap invariant broken at %d", i)
+			}
+		}
+	}
+	if len(pq.byID) != count+pq.scheduled.len() {
+		t.Fatalf("index by ID has %d items, expected %d", len(pq.byID), count+pq.scheduled.len())
+	}
+}
+
+// This test checks creation of PriorityQueue objects with options
+func TestNew(t *testing.T) {
+	pq := New(WithName("DefaultQueue"), WithDescription("desc"), WithCapacity(10), WithFirstID(5))
+	if pq.Name() != "DefaultQueue" || pq.Description() != "desc" || pq.Capacity() != 10 {
+		t.Errorf("New() failed. %s %s %d", pq.Name(), pq.Description(), pq.Capacity())
+	}
+	if pq.Len() != 0 || pq.ScheduledLen() != 0 || pq.IsFull(0) {
+		t.Errorf("New() failed. Queue is not empty")
+	}
+	cr := &CustomerRequest{CustomerName: "c1", PriorityWeight: 1}
+	if err := pq.Enqueue(cr); err != nil || cr.ID != 5 {
+		t.Errorf("Enqueue() failed. Expected ID 5, got %d %v", cr.ID, err)
+	}
+	if New().IsFull(1000) {
+		t.Errorf("New() failed. Capacity 0 should not have a limit")
+	}
+}
+
+// This test checks that requests are dequeued by PriorityWeight and capacity is enforced
+func TestEnqueueDequeue(t *testing.T) {
+	pq := New(WithCapacity(3))
+	for i, weight := range []int{2, 9, 5} {
+		cr := &CustomerRequest{PriorityWeight: weight}
+		if err := pq.Enqueue(cr); err != nil || cr.ID != i || cr.EnqueueTime.IsZero() {
+			t.Fatalf("Enqueue() failed. %+v %v", cr, err)
+		}
+	}
+	if err := pq.Enqueue(&CustomerRequest{PriorityWeight: 1}); !errors.Is(err, ErrFull) {
+		t.Errorf("Enqueue() failed. Expected ErrFull, got %v", err)
+	}
+	if !pq.IsFull(0) || pq.Len() != 3 {
+		t.Errorf("Enqueue() failed. Expected a full queue of 3, got %d", pq.Len())
+	}
+	checkHeap(t, pq)
+
+	if cr, err := pq.Peek(); err != nil || cr.PriorityWeight != 9 {
+		t.Errorf("Peek() failed. Expected weight 9, got %+v %v", cr, err)
+	}
+	for _, weight := range []int{9, 5, 2} {
+		if cr, err := pq.Dequeue(); err != nil || cr.PriorityWeight != weight {
+			t.Errorf("Dequeue() failed. Expected weight %d, got %+v %v", weight, cr, err)
+		}
+		checkHeap(t, pq)
+	}
+	if _, err := pq.Dequeue(); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Dequeue() failed. Expected ErrEmpty, got %v", err)
+	}
+	if _, err := pq.Peek(); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Peek() failed. Expected ErrEmpty, got %v", err)
+	}
+}
+
+// This test checks Get, Update, Remove, PeekFunc and Oldest
+func TestLookups(t *testing.T) {
+	pq := New()
+	now := time.Now()
+	for i, weight := range []int{3, 7, 4, 1} {
+		_ = pq.Enqueue(&CustomerRequest{PriorityWeight: weight, EnqueueTime: now.Add(time.Duration(i-2) * time.Second)})
+	}
+	if cr, err := pq.Get(2); err != nil || cr.PriorityWeight != 4 {
+		t.Errorf("Get() failed. %+v %v", cr, err)
+	}
+	if _, err := pq.Get(42); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() failed. Expected ErrNotFound, got %v", err)
+	}
+	if cr, err := pq.Oldest(); err != nil || cr.ID != 0 {
+		t.Errorf("Oldest() failed. Expected 0, got %+v %v", cr, err)
+	}
+
+	if _, err := pq.Update(3, func(cr *CustomerRequest) { cr.PriorityWeight = 10 }); err != nil {
+		t.Fatal(err)
+	}
+	checkHeap(t, pq)
+	if cr, _ := pq.Peek(); cr.ID != 3 {
+		t.Errorf("Update() failed. Expected 3 on top, got %d", cr.ID)
+	}
+	if _, err := pq.Update(42, func(cr *CustomerRequest) { cr.PriorityWeight = 10 }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update() failed. Expected ErrNotFound, got %v", err)
+	}
+
+	if cr, err := pq.PeekFunc(func(cr *CustomerRequest) bool { return cr.PriorityWeight < 5 }); err != nil || cr.ID != 2 {
+		t.Errorf("PeekFunc() failed. Expected 2, got %+v %v", cr, err)
+	}
+	if _, err := pq.PeekFunc(func(cr *CustomerRequest) bool { return false }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("PeekFunc() failed. Expected ErrNotFound, got %v", err)
+	}
+
+	if cr, err := pq.Remove(0); err != nil || cr.ID != 0 || pq.Position(cr) != -1 {
+		t.Errorf("Remove() failed. %+v %v", cr, err)
+	}
+	if _, err := pq.Remove(0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Remove() failed. Expected ErrNotFound, got %v", err)
+	}
+	checkHeap(t, pq)
+	if cr, _ := pq.Oldest(); cr.ID != 1 {
+		t.Errorf("Oldest() failed. Expected 1, got %d", cr.ID)
+	}
+}
+
+// This test checks that requests past their Deadline expire and leave the deadline heap when served
+func TestExpire(t *testing.T) {
+	pq := New()
+	now := time.Now()
+	deadline := now.Add(time.Minute)
+	_ = pq.Enqueue(&CustomerRequest{CustomerName: "short", PriorityWeight: 1, EnqueueTime: now, TTLInSec: 10})
+	_ = pq.Enqueue(&CustomerRequest{CustomerName: "long", PriorityWeight: 9, EnqueueTime: now, Deadline: &deadline})
+	_ = pq.Enqueue(&CustomerRequest{CustomerName: "none", PriorityWeight: 5, EnqueueTime: now})
+	if pq.deadlines.len() != 2 {
+		t.Fatalf("Enqueue() failed. Expected 2 deadlines, got %d", pq.deadlines.len())
+	}
+
+	expired := pq.Expire(now.Add(11 * time.Second))
+	if len(expired) != 1 || expired[0].CustomerName != "short" || pq.Len() != 2 || pq.deadlines.len() != 1 {
+		t.Errorf("Expire() failed. %d expired, %d left", len(expired), pq.Len())
+	}
+	checkHeap(t, pq)
+	if cr, _ := pq.Dequeue(); cr.CustomerName != "long" || pq.deadlines.len() != 0 {
+		t.Errorf("Dequeue() failed. Deadline was not removed")
+	}
+	if len(pq.Expire(now.Add(time.Hour))) != 0 || pq.Len() != 1 {
+		t.Errorf("Expire() failed. Request without deadline expired")
+	}
+}
+
+// This test checks that requests with a future NotBefore are scheduled, promoted and cancelled
+func TestScheduled(t *testing.T) {
+	pq := New(WithCapacity(3))
+	now := time.Now()
+	later := now.Add(time.Hour)
+	_ = pq.Enqueue(&CustomerRequest{CustomerName: "now", PriorityWeight: 1})
+	scheduled := &CustomerRequest{CustomerName: "later", PriorityWeight: 9, NotBefore: &later}
+	_ = pq.Enqueue(scheduled)
+	cancelled := &CustomerRequest{CustomerName: "cancelled", PriorityWeight: 9, NotBefore: &later}
+	_ = pq.Enqueue(cancelled)
+	if pq.Len() != 1 || pq.ScheduledLen() != 2 || !pq.IsScheduled(scheduled) || !pq.IsFull(0) {
+		t.Fatalf("Enqueue() failed. %d waiting and %d scheduled", pq.Len(), pq.ScheduledLen())
+	}
+	if _, err := pq.Remove(scheduled.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Remove() failed. Scheduled requests can only be cancelled")
+	}
+	if cr, err := pq.Cancel(cancelled.ID); err != nil || cr != cancelled || pq.ScheduledLen() != 1 {
+		t.Errorf("Cancel() failed. %v", err)
+	}
+	if _, err := pq.Cancel(cancelled.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Cancel() failed. Expected ErrNotFound, got %v", err)
+	}
+
+	if promoted := pq.Promote(now); len(promoted) != 0 {
+		t.Errorf("Promote() failed. Promoted before NotBefore")
+	}
+	promoted := pq.Promote(later)
+	if len(promoted) != 1 || promoted[0] != scheduled || !scheduled.EnqueueTime.Equal(later) || pq.IsScheduled(scheduled) {
+		t.Errorf("Promote() failed. %d promoted", len(promoted))
+	}
+	checkHeap(t, pq)
+	if cr, _ := pq.Peek(); cr != scheduled {
+		t.Errorf("Promote() failed. Expected the promoted request on top")
+	}
+}
+
+// This test checks that EnqueueAll and RemoveAll keep the heaps and the index by ID intact
+func TestEnqueueAllRemoveAll(t *testing.T) {
+	pq := New(WithCapacity(6))
+	now := time.Now()
+	_ = pq.Enqueue(&CustomerRequest{PriorityWeight: 4, EnqueueTime: now, TTLInSec: 30})
+	crs := make([]*CustomerRequest, 0)
+	for i := 0; i < 5; i++ {
+		crs = append(crs, &CustomerRequest{PriorityWeight: i * 2, EnqueueTime: now, TTLInSec: float64(60 - i)})
+	}
+	if err := pq.EnqueueAll(crs); err != nil {
+		t.Fatal(err)
+	}
+	if pq.Len() != 6 || crs[4].ID != 5 {
+		t.Errorf("EnqueueAll() failed. %d waiting, last ID %d", pq.Len(), crs[4].ID)
+	}
+	checkHeap(t, pq)
+	if err := pq.EnqueueAll([]*CustomerRequest{{PriorityWeight: 1}}); !errors.Is(err, ErrFull) {
+		t.Errorf("EnqueueAll() failed. Expected ErrFull, got %v", err)
+	}
+
+	removed := pq.RemoveAll([]int{0, 5, 5, 42})
+	if len(removed) != 2 || pq.Len() != 4 || pq.deadlines.len() != 4 {
+		t.Errorf("RemoveAll() failed. %d removed, %d left", len(removed), pq.Len())
+	}
+	checkHeap(t, pq)
+	if cr, _ := pq.Peek(); cr.ID != 4 {
+		t.Errorf("RemoveAll() failed. Expected 4 on top, got %d", cr.ID)
+	}
+}
+
+// This test checks that a dequeued request can be restored with its ID
+func TestRestore(t *testing.T) {
+	pq := New()
+	_ = pq.Enqueue(&CustomerRequest{PriorityWeight: 3})
+	_ = pq.Enqueue(&CustomerRequest{PriorityWeight: 5, TTLInSec: 60})
+	cr, _ := pq.Dequeue()
+	pq.Restore(cr)
+	if got, err := pq.Get(cr.ID); err != nil || got != cr || cr.ID != 1 || pq.deadlines.len() != 1 {
+		t.Errorf("Restore() failed. %+v %v", got, err)
+	}
+	checkHeap(t, pq)
+	if next := new(CustomerRequest); pq.Enqueue(next) != nil || next.ID != 2 {
+		t.Errorf("Restore() failed. IDs must not be reused")
+	}
+}
+
+// A ticket is an item with its own IDs and without times
+type ticket struct {
+	number, severity int
+}
+
+// This test checks a queue of another type with IDs given by the caller
+func TestNewOf(t *testing.T) {
+	pq := NewOf(Item[*ticket]{
+		Less: func(a, b *ticket) bool { return a.severity > b.severity },
+		Key:  func(t *ticket) int { return t.number }}, WithCapacity(3))
+	if err := pq.Enqueue(&ticket{number: 7, severity: 1}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond) // the queue keeps the enqueue times of tickets
+	if err := pq.Enqueue(&ticket{number: 7, severity: 2}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Enqueue() failed. Expected ErrDuplicate, got %v", err)
+	}
+	if err := pq.EnqueueAll([]*ticket{{number: 3, severity: 5}, {number: 3, severity: 2}}); !errors.Is(err, ErrDuplicate) || pq.Len() != 1 {
+		t.Errorf("EnqueueAll() failed. Expected ErrDuplicate, got %v", err)
+	}
+	if err := pq.EnqueueAll([]*ticket{{number: 3, severity: 5}, {number: 4, severity: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pq.Enqueue(&ticket{number: 9}); !errors.Is(err, ErrFull) {
+		t.Errorf("Enqueue() failed. Expected ErrFull, got %v", err)
+	}
+	checkHeap(t, pq)
+
+	if tk, err := pq.Oldest(); err != nil || tk.number != 7 {
+		t.Errorf("Oldest() failed. Expected 7, got %+v %v", tk, err)
+	}
+	if tk, err := pq.Remove(4); err != nil || tk.number != 4 {
+		t.Errorf("Remove() failed. %+v %v", tk, err)
+	}
+	if _, err := pq.Update(7, func(tk *ticket) { tk.severity = 9 }); err != nil {
+		t.Fatal(err)
+	}
+	for _, number := range []int{7, 3} {
+		if tk, err := pq.Dequeue(); err != nil || tk.number != number {
+			t.Errorf("Dequeue() failed. Expected %d, got %+v %v", number, tk, err)
+		}
+	}
+	if tk, err := pq.Dequeue(); !errors.Is(err, ErrEmpty) || tk != nil {
+		t.Errorf("Dequeue() failed. Expected ErrEmpty, got %+v %v", tk, err)
+	}
+	if len(pq.Expire(time.Now().Add(time.Hour))) != 0 || len(pq.Promote(time.Now().Add(time.Hour))) != 0 {
+		t.Errorf("Expire() failed. Items without times never expire")
+	}
+}
+
+// This test checks the order of the built-in strategies and that switching them keeps the heaps valid
+func TestStrategies(t *testing.T) {
+	now := time.Now()
+	soon, later := now.Add(time.Minute), now.Add(time.Hour)
+	pq := New(WithStrategy(EarliestDeadlineFirst()))
+	_ = pq.Enqueue(&CustomerRequest{CustomerName: "none", PriorityWeight: 9, EnqueueTime: now})
+	_ = pq.Enqueue(&CustomerRequest{CustomerName: "later", PriorityWeight: 1, EnqueueTime: now, Deadline: &later})
+	_ = pq.Enqueue(&CustomerRequest{CustomerName: "ttl", PriorityWeight: 1, EnqueueTime: now, TTLInSec: 120})
+	_ = pq.Enqueue(&CustomerRequest{CustomerName: "soon", PriorityWeight: 1, EnqueueTime: now, Deadline: &soon})
+	_ = pq.Enqueue(&CustomerRequest{CustomerName: "old", PriorityWeight: 6, EnqueueTime: now.Add(-5 * time.Minute)})
+	checkHeap(t, pq)
+	if cr, _ := pq.Peek(); cr.CustomerName != "soon" {
+		t.Errorf("EarliestDeadlineFirst() failed. Expected soon, got %s", cr.CustomerName)
+	}
+
+	orders := []struct {
+		strategy Strategy[*CustomerRequest]
+		names    []string
+	}{
+		{EarliestDeadlineFirst(), []string{"soon", "ttl", "later", "none", "old"}},
+		{ByWeight(), []string{"none", "old"}},
+		// old waited 5 minutes longer, at 1 weight per minute it outranks none
+		{Aging(1.0 / 60), []string{"old", "none"}},
+	}
+	for _, order := range orders {
+		pq.SetStrategy(order.strategy)
+		checkHeap(t, pq)
+		if pq.Strategy().Name() != order.strategy.Name() || pq.Len() != 5 {
+			t.Fatalf("SetStrategy() failed. %s with %d waiting", pq.Strategy().Name(), pq.Len())
+		}
+		items := pq.Items()
+		for i, name := range order.names {
+			cr := items[0]
+			for _, item := range items {
+				if pq.Less(item, cr) {
+					cr = item
+				}
+			}
+			if cr.CustomerName != name {
+				t.Errorf("%s failed. Expected %s at %d, got %s", order.strategy.Name(), name, i, cr.CustomerName)
+			}
+			for j := range items {
+				if items[j] == cr {
+					items = append(items[:j], items[j+1:]...)
+					break
+				}
+			}
+		}
+	}
+}
+
+// This test checks that WeightedFair serves 70% from the high class while both classes are waiting
+func TestWeightedFair(t *testing.T) {
+	pq := New(WithStrategy(WeightedFair(8, 0.7)))
+	crs := make([]*CustomerRequest, 0)
+	for i := 0; i < 100; i++ {
+		crs = append(crs, &CustomerRequest{PriorityWeight: i%10 + 1})
+	}
+	if err := pq.EnqueueAll(crs); err != nil {
+		t.Fatal(err)
+	}
+	checkHeap(t, pq)
+	if classes := pq.Classes(); classes["HIGH"] != 30 || classes["LOW"] != 70 {
+		t.Fatalf("Classes() failed. %v", classes)
+	}
+
+	high := 0
+	for i := 0; i < 40; i++ {
+		cr, err := pq.Dequeue()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cr.PriorityWeight >= 8 {
+			high++
+		}
+	}
+	if high != 28 {
+		t.Errorf("Dequeue() failed. Expected 28 of 40 from HIGH, got %d", high)
+	}
+	checkHeap(t, pq)
+
+	// The last HIGH request is served next by weight, and moves to LOW when its weight drops
+	cr, _ := pq.PeekFunc(func(cr *CustomerRequest) bool { return cr.PriorityWeight >= 8 })
+	if _, err := pq.Update(cr.ID, func(cr *CustomerRequest) { cr.PriorityWeight = 2 }); err != nil {
+		t.Fatal(err)
+	}
+	checkHeap(t, pq)
+	for pq.Len() > 0 {
+		if cr, _ := pq.Dequeue(); cr.PriorityWeight >= 8 {
+			high++
+		}
+	}
+	if high != 29 || len(pq.Classes()) != 0 {
+		t.Errorf("Dequeue() failed. Expected 29 from HIGH in total, got %d", high)
+	}
+}
+
+// This test checks that FairShare keeps every waiting account within one request of its share,
+// however many requests an account enqueues, and respects PriorityWeight within an account
+func TestFairShare(t *testing.T) {
+	pq := New(WithStrategy(FairShare(map[string]float64{"acme": 3, "globex": 1})))
+	crs := make([]*CustomerRequest, 0)
+	for i := 0; i < 1000; i++ {
+		crs = append(crs, &CustomerRequest{Account: "acme", PriorityWeight: 10})
+	}
+	for i := 0; i < 100; i++ {
+		crs = append(crs, &CustomerRequest{Account: "globex", PriorityWeight: i%10 + 1})
+		crs = append(crs, &CustomerRequest{Account: "initech", PriorityWeight: 1})
+	}
+	if err := pq.EnqueueAll(crs); err != nil {
+		t.Fatal(err)
+	}
+	checkHeap(t, pq)
+
+	// acme, globex and initech get 3/5, 1/5 and 1/5 while all of them are waiting
+	shares := map[string]float64{"acme": 0.6, "globex": 0.2, "initech": 0.2}
+	served := make(map[string]int)
+	lastWeight := 11
+	for n := 1; n <= 500; n++ {
+		cr, err := pq.Dequeue()
+		if err != nil {
+			t.Fatal(err)
+		}
+		served[cr.Account]++
+		if cr.Account == "globex" {
+			if cr.PriorityWeight > lastWeight {
+				t.Fatalf("Dequeue() failed. globex weight %d served after %d", cr.PriorityWeight, lastWeight)
+			}
+			lastWeight = cr.PriorityWeight
+		}
+		for account, share := range shares {
+			if diff := float64(served[account]) - share*float64(n); diff > 1 || diff < -1 {
+				t.Fatalf("Dequeue() failed. %s served %d of %d, more than one request off its share", account, served[account], n)
+			}
+		}
+	}
+	checkHeap(t, pq)
+
+	// An account that starts waiting later does not catch up on the turns it missed
+	for pq.Len() > 0 {
+		_, _ = pq.Dequeue()
+	}
+	for i := 0; i < 10; i++ {
+		_ = pq.Enqueue(&CustomerRequest{Account: "acme", PriorityWeight: 10})
+	}
+	for i := 0; i < 20; i++ {
+		_ = pq.Enqueue(&CustomerRequest{Account: "hooli", PriorityWeight: 1})
+	}
+	served = make(map[string]int)
+	for i := 0; i < 8; i++ {
+		cr, _ := pq.Dequeue()
+		served[cr.Account]++
+	}
+	if served["acme"] < 5 || served["hooli"] < 1 {
+		t.Errorf("Dequeue() failed. Expected acme to get 3 of every 4 turns, got %v", served)
+	}
+}
+
+// This test checks that LowestN returns the items Lowest finds when they are removed one by one
+func TestLowestN(t *testing.T) {
+	pq := New()
+	for i := 0; i < 200; i++ {
+		_ = pq.Enqueue(&CustomerRequest{PriorityWeight: i*7%10 + 1, EnqueueTime: time.Now().Add(time.Duration(i%13)*time.Second + time.Duration(i))})
+	}
+	if len(pq.LowestN(0)) != 0 || len(pq.LowestN(500)) != 200 {
+		t.Errorf("LowestN() failed. Expected at most the waiting items")
+	}
+	lowest := pq.LowestN(50)
+	for i, want := range lowest {
+		cr, err := pq.Lowest()
+		if err != nil || cr != want {
+			t.Fatalf("LowestN() failed. Item %d is %+v, Lowest returns %+v", i, want, cr)
+		}
+		_, _ = pq.Remove(cr.ID)
+	}
+	checkHeap(t, pq)
+}
+
+// This test checks that Lowest finds the item a scan of the waiting items finds while the queue changes
+func TestLowestIndex(t *testing.T) {
+	pq := New()
+	for i := 0; i < 100; i++ {
+		_ = pq.Enqueue(&CustomerRequest{PriorityWeight: i*7%10 + 1, EnqueueTime: time.Now().Add(time.Duration(i%13) * time.Second)})
+	}
+	for i := 0; pq.Len() > 0; i++ {
+		cr, err := pq.Lowest()
+		if err != nil {
+			t.Fatalf("Lowest() failed. Expected an item, got %v", err)
+		}
+		waiting := pq.waiting()
+		for _, e := range waiting {
+			if pq.lower(e, pq.byID[cr.ID]) {
+				t.Fatalf("Lowest() failed. %+v is lower than %+v", e.value, cr)
+			}
+		}
+		switch i % 4 {
+		case 0:
+			_, _ = pq.Remove(cr.ID)
+		case 1:
+			_, _ = pq.Dequeue()
+		case 2:
+			_ = pq.RemoveAll([]int{waiting[0].id, cr.ID})
+		default:
+			_, _ = pq.Update(waiting[len(waiting)/2].id, func(v *CustomerRequest) { v.PriorityWeight = 0 })
+			_ = pq.EnqueueAll([]*CustomerRequest{{PriorityWeight: 3}})
+		}
+		checkHeap(t, pq)
+	}
+}
+
+// This test checks that a reserved item keeps its ID when it is restored and Lowest finds the newest of the lowest
+func TestReserveLowest(t *testing.T) {
+	pq := New(WithCapacity(3))
+	now := time.Now()
+	_ = pq.Enqueue(&CustomerRequest{CustomerName: "old", PriorityWeight: 1, EnqueueTime: now.Add(-time.Minute)})
+	_ = pq.Enqueue(&CustomerRequest{CustomerName: "high", PriorityWeight: 9, EnqueueTime: now})
+	_ = pq.Enqueue(&CustomerRequest{CustomerName: "new", PriorityWeight: 1, EnqueueTime: now})
+	if cr, err := pq.Lowest(); err != nil || cr.CustomerName != "new" {
+		t.Errorf("Lowest() failed. Expected new, got %+v %v", cr, err)
+	}
+
+	later := now.Add(time.Hour)
+	held := &CustomerRequest{CustomerName: "held", PriorityWeight: 5, NotBefore: &later}
+	pq.Reserve(held)
+	if held.ID != 3 || pq.Len() != 3 {
+		t.Fatalf("Reserve() failed. Expected ID 3, got %d", held.ID)
+	}
+	if _, err := pq.Remove(2); err != nil {
+		t.Fatal(err)
+	}
+	pq.Restore(held)
+	if cr, err := pq.Get(3); err != nil || cr != held || !pq.IsScheduled(held) || held.EnqueueTime.IsZero() {
+		t.Errorf("Restore() failed. Expected the reserved request to be scheduled, %+v %v", cr, err)
+	}
+	checkHeap(t, pq)
+	if _, err := New().Lowest(); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Lowest() failed. Expected ErrEmpty, got %v", err)
+	}
+}
+
+// This test checks that a queue holding more items than its new capacity stays full until enough have left
+func TestSetCapacity(t *testing.T) {
+	pq := New(WithCapacity(3))
+	for i := 0; i < 3; i++ {
+		_ = pq.Enqueue(&CustomerRequest{PriorityWeight: i})
+	}
+	pq.SetCapacity(2)
+	if pq.Capacity() != 2 || pq.Len() != 3 || !pq.IsFull(0) || !errors.Is(pq.Enqueue(&CustomerRequest{}), ErrFull) {
+		t.Errorf("SetCapacity() failed. Expected the queue to keep its items and be full")
+	}
+	_, _ = pq.Dequeue()
+	_, _ = pq.Dequeue()
+	if pq.IsFull(0) {
+		t.Errorf("SetCapacity() failed. Expected room after two items left")
+	}
+	pq.SetCapacity(0)
+	if pq.IsFull(100) {
+		t.Errorf("SetCapacity() failed. Expected no limit for capacity 0")
+	}
+}
+
+// This test checks that unique keys are kept unique and indexed while items come and go
+func TestUnique(t *testing.T) {
+	byName := func(cr *CustomerRequest) string { return cr.CustomerName }
+	pq := New(WithUnique(byName))
+	_ = pq.Enqueue(&CustomerRequest{CustomerName: "a", PriorityWeight: 1})
+	_ = pq.Enqueue(&CustomerRequest{CustomerName: "b", PriorityWeight: 2})
+	if err := pq.Enqueue(&CustomerRequest{CustomerName: "a"}); !errors.Is(err, ErrExists) {
+		t.Errorf("Enqueue() failed. Expected ErrExists, got %v", err)
+	}
+	if err := pq.EnqueueAll([]*CustomerRequest{{CustomerName: "c"}, {CustomerName: "c"}}); !errors.Is(err, ErrExists) || pq.Len() != 2 {
+		t.Errorf("EnqueueAll() failed. Expected ErrExists, got %v", err)
+	}
+	if _, err := pq.Update(0, func(cr *CustomerRequest) { cr.CustomerName = "z" }); err != nil {
+		t.Fatal(err)
+	}
+	if cr, err := pq.Find("z"); err != nil || cr.ID != 0 {
+		t.Errorf("Find() failed. Expected the renamed request, got %+v %v", cr, err)
+	}
+	if _, err := pq.Dequeue(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pq.Find("b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Find() failed. Expected ErrNotFound after Dequeue, got %v", err)
+	}
+	if err := pq.Enqueue(&CustomerRequest{CustomerName: "b"}); err != nil {
+		t.Errorf("Enqueue() failed. Expected the key to be free again, got %v", err)
+	}
+
+	_ = pq.Enqueue(&CustomerRequest{CustomerName: "c"})
+	c, _ := pq.Find("c")
+	if removed := pq.RemoveAll([]int{c.ID}); len(removed) != 1 {
+		t.Fatalf("RemoveAll() failed. Expected c to be removed, got %+v", removed)
+	}
+	if err := pq.Enqueue(&CustomerRequest{CustomerName: "c"}); err != nil {
+		t.Errorf("Enqueue() failed. Expected the key to be free again after RemoveAll, got %v", err)
+	}
+	later := time.Now().Add(time.Hour)
+	_ = pq.Enqueue(&CustomerRequest{CustomerName: "d", NotBefore: &later})
+	d, _ := pq.Find("d")
+	if _, err := pq.Cancel(d.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := pq.Enqueue(&CustomerRequest{CustomerName: "d"}); err != nil {
+		t.Errorf("Enqueue() failed. Expected the key to be free again after Cancel, got %v", err)
+	}
+	for _, name := range []string{"c", "d"} {
+		cr, _ := pq.Find(name)
+		_, _ = pq.Remove(cr.ID)
+	}
+
+	_ = pq.Enqueue(&CustomerRequest{CustomerName: "b2"})
+	if err := pq.SetUnique(func(cr *CustomerRequest) string { return cr.CustomerName[:1] }); !errors.Is(err, ErrExists) {
+		t.Errorf("SetUnique() failed. Expected ErrExists, got %v", err)
+	}
+	if err := pq.SetUnique(nil); err != nil || pq.Enqueue(&CustomerRequest{CustomerName: "b"}) != nil {
+		t.Errorf("SetUnique() failed. Expected keys to be allowed twice, %v", err)
+	}
+}
+
+// This test checks that held items keep their unique key and are only charged to their class once served
+func TestHold(t *testing.T) {
+	byName := func(cr *CustomerRequest) string { return cr.CustomerName }
+	pq := New(WithUnique(byName), WithStrategy(FairShare(map[string]float64{"acme": 1, "globex": 1})))
+	_ = pq.EnqueueAll([]*CustomerRequest{{CustomerName: "a", Account: "acme"}, {CustomerName: "b", Account: "globex"}})
+	first, _ := pq.Peek()
+	held, err := pq.Hold(first.ID)
+	if err != nil || pq.Len() != 1 {
+		t.Fatalf("Hold() failed. %+v %v", held, err)
+	}
+	if err := pq.Enqueue(&CustomerRequest{CustomerName: held.CustomerName}); !errors.Is(err, ErrExists) {
+		t.Errorf("Enqueue() failed. Expected the key of the held request to be taken, got %v", err)
+	}
+	if cr, err := pq.Find(held.CustomerName); err != nil || cr != held {
+		t.Errorf("Find() failed. Expected the held request, got %+v %v", cr, err)
+	}
+
+	pq.Restore(held)
+	if cr, _ := pq.Peek(); cr != held {
+		t.Errorf("Restore() failed. A request that was not served should not be charged to its class, got %+v", cr)
+	}
+	if _, err := pq.Serve(held.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Serve() failed. Expected ErrNotFound for a restored request, got %v", err)
+	}
+	if cr, _ := pq.Find(held.CustomerName); cr != held {
+		t.Errorf("Restore() failed. Expected the restored request to keep its key, got %+v", cr)
+	}
+
+	_, _ = pq.Hold(held.ID)
+	if _, err := pq.Serve(held.ID); err != nil {
+		t.Fatal(err)
+	}
+	if cr, _ := pq.Peek(); cr.Account == held.Account {
+		t.Errorf("Serve() failed. Expected the other class to be next, got %+v", cr)
+	}
+	if err := pq.Enqueue(&CustomerRequest{CustomerName: held.CustomerName, Account: held.Account}); err != nil {
+		t.Errorf("Enqueue() failed. Expected the key to be free after Serve, got %v", err)
+	}
+}
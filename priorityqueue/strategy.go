@@ -0,0 +1,130 @@
+package priorityqueue
+
+// A Strategy orders the waiting items of a PriorityQueue. Items are split into classes, within a class
+// the item that is Less comes first and the classes take turns in proportion to their Share, e.g. a class
+// with share 0.7 gets 70% of the items served while the other classes with share 0.3 are waiting.
+// Strategies with a single class order all items by Less.
+type Strategy[T any] interface {
+	// Name identifies the strategy, e.g. in reports
+	Name() string
+	// Less reports if a is dequeued before b, a and b are in the same class
+	Less(a, b T) bool
+	// Class returns the class of v
+	Class(v T) string
+	// Share returns the share of the service of class, relative to the shares of the other classes
+	Share(class string) float64
+}
+
+// A strategy is a Strategy made of functions
+type strategy[T any] struct {
+	name  string
+	less  func(a, b T) bool
+	class func(v T) string
+	share func(class string) float64
+}
+
+func (s strategy[T]) Name() string { return s.name }
+
+func (s strategy[T]) Less(a, b T) bool { return s.less(a, b) }
+
+func (s strategy[T]) Class(v T) string {
+	if s.class == nil {
+		return ""
+	}
+	return s.class(v)
+}
+
+func (s strategy[T]) Share(class string) float64 {
+	if s.share == nil {
+		return 1
+	}
+	return s.share(class)
+}
+
+// StrategyFunc returns a Strategy called name that orders all items by less
+func StrategyFunc[T any](name string, less func(a, b T) bool) Strategy[T] {
+	return strategy[T]{name: name, less: less}
+}
+
+// FairStrategy returns a Strategy called name that orders the items of every class by less
+// and serves the classes in proportion to share
+func FairStrategy[T any](name string, less func(a, b T) bool, class func(v T) string, share func(class string) float64) Strategy[T] {
+	return strategy[T]{name: name, less: less, class: class, share: share}
+}
+
+// Names of the built-in strategies of CustomerRequests
+const (
+	PRIORITY     = "PRIORITY"
+	EDF          = "EDF"
+	AGING        = "AGING"
+	WEIGHTEDFAIR = "WEIGHTED_FAIR"
+	FAIRSHARE    = "FAIR_SHARE"
+)
+
+// ByWeight returns the strategy that serves the CustomerRequest with the highest PriorityWeight first.
+// It is the default strategy of New.
+func ByWeight() Strategy[*CustomerRequest] {
+	return StrategyFunc(PRIORITY, CustomerRequests.Less)
+}
+
+// EarliestDeadlineFirst returns the strategy that serves the CustomerRequest that expires first.
+// Requests without Deadline come after the ones with, requests are ordered by PriorityWeight otherwise.
+func EarliestDeadlineFirst() Strategy[*CustomerRequest] {
+	return StrategyFunc(EDF, func(a, b *CustomerRequest) bool {
+		switch {
+		case a.Deadline != nil && b.Deadline != nil && !a.Deadline.Equal(*b.Deadline):
+			return a.Deadline.Before(*b.Deadline)
+		case (a.Deadline == nil) != (b.Deadline == nil):
+			return a.Deadline != nil
+		}
+		return a.PriorityWeight > b.PriorityWeight
+	})
+}
+
+// Aging returns the strategy that serves the CustomerRequest with the highest PriorityWeight first, where
+// the weight grows by perSecond for every second a request waits. As all waiting requests age at the same
+// rate, the order of two requests never changes while they wait and the heap stays valid.
+func Aging(perSecond float64) Strategy[*CustomerRequest] {
+	return StrategyFunc(AGING, func(a, b *CustomerRequest) bool {
+		// a.PriorityWeight + perSecond*age(a) > b.PriorityWeight + perSecond*age(b)
+		return float64(a.PriorityWeight-b.PriorityWeight) > perSecond*a.EnqueueTime.Sub(b.EnqueueTime).Seconds()
+	})
+}
+
+// WeightedFair returns the strategy that splits CustomerRequests into the classes HIGH, with PriorityWeight of
+// at least minWeight, and LOW. HIGH gets share of the service and LOW the rest, requests are ordered
+// by PriorityWeight within a class. WeightedFair(8, 0.7) serves 70% from weights 8 to 10 and 30% from the rest.
+func WeightedFair(minWeight int, share float64) Strategy[*CustomerRequest] {
+	return FairStrategy(WEIGHTEDFAIR, CustomerRequests.Less,
+		func(cr *CustomerRequest) string {
+			if cr.PriorityWeight >= minWeight {
+				return "HIGH"
+			}
+			return "LOW"
+		},
+		func(class string) float64 {
+			if class == "HIGH" {
+				return share
+			}
+			return 1 - share
+		})
+}
+
+// FairShare returns the strategy that serves the Accounts of CustomerRequests in turns, in proportion to
+// their shares. Accounts missing from shares get a share of 1, requests are ordered by PriorityWeight within
+// an account. While accounts are waiting, none of them is ahead of or behind its share by more than one
+// request, however many requests it has enqueued.
+func FairShare(shares map[string]float64) Strategy[*CustomerRequest] {
+	copied := make(map[string]float64, len(shares))
+	for account, share := range shares {
+		copied[account] = share
+	}
+	return FairStrategy(FAIRSHARE, CustomerRequests.Less,
+		func(cr *CustomerRequest) string { return cr.Account },
+		func(account string) float64 {
+			if share, ok := copied[account]; ok {
+				return share
+			}
+			return 1
+		})
+}
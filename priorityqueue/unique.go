@@ -0,0 +1,67 @@
+package priorityqueue
+
+// WithUnique makes the key returned by key unique among the waiting, scheduled and held items, enqueueing an
+// item whose key is taken fails with ErrExists. Items can be found by their key with Find.
+func WithUnique[T any](key func(v T) string) Option {
+	return func(c *config) { c.unique = key }
+}
+
+// SetUnique changes the key that is unique among the waiting, scheduled and held items, nil turns uniqueness
+// off. ErrExists is returned and nothing is changed if two items of the queue have the same key.
+func (pq *PriorityQueue[T]) SetUnique(key func(v T) string) error {
+	byKey := make(map[string]*entry[T], len(pq.byID)+len(pq.held))
+	if key != nil {
+		for _, entries := range []map[int]*entry[T]{pq.byID, pq.held} {
+			for _, e := range entries {
+				k := key(e.value)
+				if _, ok := byKey[k]; ok {
+					return ErrExists
+				}
+				byKey[k] = e
+			}
+		}
+	}
+	pq.unique = key
+	pq.byKey = byKey
+	for k, e := range byKey {
+		e.key = k
+	}
+	return nil
+}
+
+// Find returns the waiting, scheduled or held item with the unique key, ErrNotFound is returned if there is
+// none or the queue has no unique key
+func (pq *PriorityQueue[T]) Find(key string) (T, error) {
+	e, ok := pq.byKey[key]
+	if !ok {
+		var zero T
+		return zero, ErrNotFound
+	}
+	return e.value, nil
+}
+
+// This method reports if the unique key of v is taken
+func (pq *PriorityQueue[T]) isTaken(v T) bool {
+	if pq.unique == nil {
+		return false
+	}
+	_, ok := pq.byKey[pq.unique(v)]
+	return ok
+}
+
+// This method adds e to the index by unique key. Restored and updated items take the key over if it
+// is taken, they are not checked.
+func (pq *PriorityQueue[T]) index(e *entry[T]) {
+	if pq.unique == nil {
+		return
+	}
+	e.key = pq.unique(e.value)
+	pq.byKey[e.key] = e
+}
+
+// This method removes e from the index by unique key
+func (pq *PriorityQueue[T]) unindex(e *entry[T]) {
+	if pq.byKey[e.key] == e {
+		delete(pq.byKey, e.key)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// QUOTARETRYAFTER is the Retry-After in seconds for clients with too many outstanding requests
+var QUOTARETRYAFTER = 30
+
+// MAXBUCKETS is the number of token buckets kept before idle ones are dropped
+const MAXBUCKETS = 10000
+
+// This method is for setting the enqueue quotas of the clients of pq. A rate limit without a burst allows
+// bursts of the enqueues of one second. The token buckets of the old rate limit are dropped.
+func setQuotas(pq *PriorityQueue, quotas QuotasJSON) (QuotasJSON, error) {
+	if quotas.RateLimit < 0 || quotas.RateBurst < 0 || quotas.MaxOutstanding < 0 {
+		return QuotasJSON{}, errors.New("rateLimit, rateBurst and maxOutstanding must not be negative")
+	}
+	if quotas.RateLimit == 0 {
+		quotas.RateBurst = 0
+	} else if quotas.RateBurst < 1 {
+		quotas.RateBurst = math.Max(1, quotas.RateLimit)
+	}
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	pq.rateLimit, pq.rateBurst, pq.maxOutstanding = quotas.RateLimit, quotas.RateBurst, quotas.MaxOutstanding
+	pq.buckets = nil
+	logger.Printf("quotas of %s are %+v", pq.queue.Name(), quotas)
+	return quotas, nil
+}
+
+// This function parses the quotas given by the PQ_RATE_LIMIT, PQ_RATE_BURST and PQ_MAX_OUTSTANDING settings,
+// quotas that are not given are off
+func parseQuotas(rateLimit string, rateBurst string, maxOutstanding string) (QuotasJSON, error) {
+	quotas := QuotasJSON{}
+	var err error
+	if rateLimit != "" {
+		if quotas.RateLimit, err = strconv.ParseFloat(rateLimit, 64); err != nil {
+			return QuotasJSON{}, errors.New("PQ_RATE_LIMIT must be a number of enqueues per second")
+		}
+	}
+	if rateBurst != "" {
+		if quotas.RateBurst, err = strconv.ParseFloat(rateBurst, 64); err != nil {
+			return QuotasJSON{}, errors.New("PQ_RATE_BURST must be a number of enqueues")
+		}
+	}
+	if maxOutstanding != "" {
+		if quotas.MaxOutstanding, err = strconv.Atoi(maxOutstanding); err != nil {
+			return QuotasJSON{}, errors.New("PQ_MAX_OUTSTANDING must be a number of requests")
+		}
+	}
+	return quotas, nil
+}
+
+// This function returns the key enqueues are limited by, the client ID if authenticated or the remote IP
+func clientKey(r *http.Request) string {
+	if principal := principalFrom(r); principal.ClientID != "" {
+		return principal.ClientID
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// This function checks the rate limit and the outstanding requests of client and takes a token
+// if the enqueue is allowed. Otherwise it returns the error code and seconds to wait before retrying.
+// pq.mutex must be held.
+func takeQuota(pq *PriorityQueue, client string, now time.Time) (string, int) {
+	if pq.maxOutstanding > 0 && pq.outstanding[client] >= pq.maxOutstanding {
+		return "QUOTA_EXCEEDED", QUOTARETRYAFTER
+	}
+	if pq.rateLimit <= 0 {
+		return "", 0
+	}
+	if pq.buckets == nil {
+		pq.buckets = make(map[string]*tokenBucket)
+	}
+	bucket, ok := pq.buckets[client]
+	if !ok {
+		if len(pq.buckets) >= MAXBUCKETS {
+			dropFullBuckets(pq, now)
+		}
+		bucket = &tokenBucket{tokens: pq.rateBurst, last: now}
+		pq.buckets[client] = bucket
+	}
+	bucket.tokens = math.Min(pq.rateBurst, bucket.tokens+now.Sub(bucket.last).Seconds()*pq.rateLimit)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return "RATE_LIMITED", int(math.Ceil((1 - bucket.tokens) / pq.rateLimit))
+	}
+	bucket.tokens--
+	return "", 0
+}
+
+// This function gives client back the token takeQuota took for an enqueue that was rolled back or refused
+func refundQuota(pq *PriorityQueue, client string) {
+	if bucket, ok := pq.buckets[client]; ok {
+		bucket.tokens = math.Min(pq.rateBurst, bucket.tokens+1)
+	}
+}
+
+// This function drops the buckets that have refilled, they are the same as new ones
+func dropFullBuckets(pq *PriorityQueue, now time.Time) {
+	for client, bucket := range pq.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*pq.rateLimit >= pq.rateBurst {
+			delete(pq.buckets, client)
+		}
+	}
+}
+
+// This function counts a request towards the outstanding requests of owner, the client who enqueued it
+func holdQuota(pq *PriorityQueue, owner string) {
+	if owner == "" {
+		return
+	}
+	if pq.outstanding == nil {
+		pq.outstanding = make(map[string]int)
+	}
+	pq.outstanding[owner]++
+}
+
+// This function is called once a request of owner has left the queue for good
+func releaseQuota(pq *PriorityQueue, owner string) {
+	if owner == "" {
+		return
+	}
+	if pq.outstanding[owner] <= 1 {
+		delete(pq.outstanding, owner)
+	} else {
+		pq.outstanding[owner]--
+	}
+}
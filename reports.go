@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// Time buckets of reports, weeks start on Monday
+const (
+	DAY  = "day"
+	WEEK = "week"
+)
+
+// Dimensions reports can be grouped by
+const (
+	BYPRIORITY = "priorityWeight"
+	BYQUEUE    = "queue"
+)
+
+// reportColumns is the header of CSV reports
+var reportColumns = []string{"bucketStart", "group", "volumeCount", "servicedCount", "abandonedCount",
+	"abandonmentRatePercent", "averageWaitInSec", "waitP90InSec"}
+
+// reportRow collects the completed requests of a row until it is summed up
+type reportRow struct {
+	ReportRow
+	waits []float64
+}
+
+// This function parses a report bound, given as RFC3339 or as a date which is midnight in loc
+func parseReportTime(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", s, loc)
+}
+
+// This function returns the start of the day or week t is in, in the location of t
+func bucketStart(t time.Time, bucket string) time.Time {
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if bucket == WEEK {
+		start = start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+	}
+	return start
+}
+
+// This method is for building the report of pq from the query parameters of a report, empty
+// parameters take their defaults: to is now, from is 7 days or 4 weeks before to, bucket is day
+// and rows are not grouped
+func newReport(pq *PriorityQueue, fromStr string, toStr string, bucket string, groupBy string, now time.Time) (ReportStruct, error) {
+	if bucket == "" {
+		bucket = DAY
+	}
+	if bucket != DAY && bucket != WEEK {
+		return ReportStruct{}, errors.New("bucket must be day or week")
+	}
+	if groupBy != "" && groupBy != BYPRIORITY && groupBy != BYQUEUE {
+		return ReportStruct{}, errors.New("groupBy must be priorityWeight or queue")
+	}
+	to := now
+	if toStr != "" {
+		var err error
+		if to, err = parseReportTime(toStr, now.Location()); err != nil {
+			return ReportStruct{}, errors.New("to must be a RFC3339 time or a date like 2006-01-02")
+		}
+	}
+	from := to.AddDate(0, 0, -7)
+	if bucket == WEEK {
+		from = to.AddDate(0, 0, -28)
+	}
+	if fromStr != "" {
+		var err error
+		if from, err = parseReportTime(fromStr, now.Location()); err != nil {
+			return ReportStruct{}, errors.New("from must be a RFC3339 time or a date like 2006-01-02")
+		}
+	}
+	if !from.Before(to) {
+		return ReportStruct{}, errors.New("from must be before to")
+	}
+	return buildReport(pq, from, to, bucket, groupBy), nil
+}
+
+// This method is for aggregating the requests of pq completed from from until before to by time
+// bucket, and by groupBy if it is not empty. Buckets are in the location of from, rows without
+// completed requests are left out.
+func buildReport(pq *PriorityQueue, from time.Time, to time.Time, bucket string, groupBy string) ReportStruct {
+	logger.Printf("building %s report from %s to %s grouped by %q", bucket, from.Format(time.RFC3339), to.Format(time.RFC3339), groupBy)
+	pq.mutex.Lock()
+	completed := completedBetween(pq, from, to)
+	pq.mutex.Unlock()
+
+	type rowKey struct {
+		start int64
+		group string
+	}
+	rows := make(map[rowKey]*reportRow)
+	for _, record := range completed {
+		key := rowKey{start: bucketStart(record.CompletedAt.In(from.Location()), bucket).Unix()}
+		switch groupBy {
+		case BYPRIORITY:
+			key.group = strconv.Itoa(record.PriorityWeight)
+		case BYQUEUE:
+			key.group = record.QueueName
+		}
+		row, ok := rows[key]
+		if !ok {
+			row = &reportRow{ReportRow: ReportRow{BucketStart: time.Unix(key.start, 0).In(from.Location()), Group: key.group}}
+			rows[key] = row
+		}
+		row.VolumeCount++
+		switch record.Status {
+		case SERVICED:
+			row.ServicedCount++
+			row.waits = append(row.waits, record.WaitTimeinSec)
+			row.AverageWaitInSec += record.WaitTimeinSec
+		case RENEGED, EXPIRED:
+			row.AbandonedCount++
+		}
+	}
+
+	report := ReportStruct{From: from, To: to, Bucket: bucket, GroupBy: groupBy, Rows: make([]ReportRow, 0, len(rows))}
+	for _, row := range rows {
+		if row.ServicedCount > 0 {
+			row.AverageWaitInSec /= float64(row.ServicedCount)
+			sort.Float64s(row.waits)
+			row.WaitP90InSec = percentile(row.waits, 90)
+		}
+		if row.ServicedCount+row.AbandonedCount > 0 {
+			row.AbandonmentRatePercent = 100 * float64(row.AbandonedCount) / float64(row.ServicedCount+row.AbandonedCount)
+		}
+		report.Rows = append(report.Rows, row.ReportRow)
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		a, b := report.Rows[i], report.Rows[j]
+		if !a.BucketStart.Equal(b.BucketStart) {
+			return a.BucketStart.Before(b.BucketStart)
+		}
+		if groupBy == BYPRIORITY {
+			x, _ := strconv.Atoi(a.Group)
+			y, _ := strconv.Atoi(b.Group)
+			return x < y
+		}
+		return a.Group < b.Group
+	})
+	return report
+}
+
+// This function returns the cells of row as they are written to CSV reports and report tables
+func reportCells(row ReportRow) []string {
+	return []string{row.BucketStart.Format(time.RFC3339), row.Group,
+		strconv.Itoa(row.VolumeCount), strconv.Itoa(row.ServicedCount), strconv.Itoa(row.AbandonedCount),
+		strconv.FormatFloat(row.AbandonmentRatePercent, 'f', 2, 64),
+		strconv.FormatFloat(row.AverageWaitInSec, 'f', 2, 64),
+		strconv.FormatFloat(row.WaitP90InSec, 'f', 2, 64)}
+}
+
+// This function writes report to w as CSV with a header line
+func writeReportCSV(w io.Writer, report ReportStruct) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(reportColumns); err != nil {
+		return err
+	}
+	for _, row := range report.Rows {
+		if err := cw.Write(reportCells(row)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// This function prints report as a table on the console
+func printReport(report ReportStruct) {
+	fmt.Printf("%s report from %s to %s\n", report.Bucket, report.From.Format(time.RFC3339), report.To.Format(time.RFC3339))
+	if len(report.Rows) == 0 {
+		fmt.Println("No completed requests")
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, column := range reportColumns {
+		if i > 0 {
+			fmt.Fprint(tw, "\t")
+		}
+		fmt.Fprint(tw, column)
+	}
+	fmt.Fprintln(tw)
+	for _, row := range report.Rows {
+		for i, cell := range reportCells(row) {
+			if i > 0 {
+				fmt.Fprint(tw, "\t")
+			}
+			fmt.Fprint(tw, cell)
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
+
+// This method is for printing the report of pq over the default range on the console
+func selection7(pq *PriorityQueue, bucket string, groupBy string, isConsole bool) (ReportStruct, error) {
+	report, err := newReport(pq, "", "", bucket, groupBy, time.Now())
+	if err != nil {
+		logger.Println(err)
+		if isConsole {
+			fmt.Println(err)
+		}
+		return report, err
+	}
+	if isConsole {
+		printReport(report)
+	}
+	return report, nil
+}
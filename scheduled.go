@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// This method is for Listing scheduled Customer Requests, the ones due first are listed first
+func listScheduled(pq *PriorityQueue, isConsole bool) ScheduledStruct {
+	logger.Printf("listing scheduled requests, isConsole: %t", isConsole)
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	tempArray := make([]*CustomerRequest, 0, pq.queue.ScheduledLen())
+	for _, cr := range pq.queue.ScheduledItems() {
+		tempArray = append(tempArray, &CustomerRequest{
+			ID:             cr.ID,
+			PriorityWeight: cr.PriorityWeight,
+			CustomerName:   cr.CustomerName,
+			Description:    cr.Description,
+			EnqueueTime:    cr.EnqueueTime,
+			TTLInSec:       cr.TTLInSec,
+			Deadline:       cr.Deadline,
+			NotBefore:      cr.NotBefore,
+			RequiredSkills: cr.RequiredSkills,
+			Account:        cr.Account,
+		})
+	}
+	sort.Slice(tempArray, func(i, j int) bool { return tempArray[i].NotBefore.Before(*tempArray[j].NotBefore) })
+	sStruct := ScheduledStruct{QueueName: pq.queue.Name(),
+		Size:             len(tempArray),
+		CustomerRequests: tempArray}
+
+	if isConsole {
+		jsonData, _ := json.MarshalIndent(sStruct, "", "    ")
+		fmt.Println(string(jsonData))
+	}
+	return sStruct
+}
+
+// This method is for Cancelling a scheduled Customer Request before it is due
+func cancelScheduled(pq *PriorityQueue, cancelID int, isConsole bool) (Selection5Struct, error) {
+	logger.Printf("cancelling scheduled request %d, isConsole: %t", cancelID, isConsole)
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	return cancel(pq, cancelID, isConsole)
+}
+
+// This function cancels the scheduled Customer Request with id=cancelID. pq.mutex must be held.
+func cancel(pq *PriorityQueue, cancelID int, isConsole bool) (Selection5Struct, error) {
+	cr, err := cancelByID(pq, cancelID, isConsole)
+	if err != nil {
+		if isConsole {
+			fmt.Println(err)
+		}
+		return Selection5Struct{}, errors.New(err.Error())
+	}
+	recordEvent(pq, "CANCELLED", cr, "")
+	recordCompleted(pq, cr, CANCELLED, "", time.Now())
+
+	s5Struct := Selection5Struct{
+		CustomerName: cr.CustomerName,
+		ID:           cr.ID,
+		EnqueueTime:  cr.EnqueueTime,
+		Message:      "Scheduled request cancelled successfully"}
+
+	if isConsole {
+		jsonData, _ := json.MarshalIndent(s5Struct, "", "    ")
+		fmt.Println(string(jsonData))
+	}
+	return s5Struct, nil
+}
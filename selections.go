@@ -11,14 +11,16 @@ import (
 // This method is for Listing Customers in Queue
 func selection1(pq *PriorityQueue, isConsole bool) Selection1Struct {
 	logger.Printf("getting selection 1, isConsole: %t", isConsole)
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
 	tempArray := make([]IDJSON, 0)
-	for i := 0; i < len(pq.harr); i++ {
-		tempArray = append(tempArray, IDJSON{ID: pq.harr[i].ID})
+	for _, cr := range pq.queue.Items() {
+		tempArray = append(tempArray, IDJSON{ID: cr.ID})
 	}
 	oldest, _ := getOldestTaskID(pq)
-	s1Struct := Selection1Struct{QueueName: pq.queueName,
-		QueueDescription: pq.queueDescription,
-		Size:             len(pq.harr),
+	s1Struct := Selection1Struct{QueueName: pq.queue.Name(),
+		QueueDescription: pq.queue.Description(),
+		Size:             pq.queue.Len(),
 		OldestTaskID:     oldest,
 		CustomerRequests: tempArray}
 
@@ -33,22 +35,28 @@ func selection1(pq *PriorityQueue, isConsole bool) Selection1Struct {
 // This method is for Listing Customers details in Queue
 func selection2(pq *PriorityQueue, isConsole bool) Selection2Struct {
 	logger.Printf("getting selection 2, isConsole: %t", isConsole)
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
 	tempArray := make([]*CustomerRequest, 0)
-	for i := 0; i < len(pq.harr); i++ {
+	for _, waiting := range pq.queue.Items() {
 		cr := &CustomerRequest{
-			ID:             pq.harr[i].ID,
-			PriorityWeight: pq.harr[i].PriorityWeight,
-			CustomerName:   pq.harr[i].CustomerName,
-			Description:    pq.harr[i].Description,
-			EnqueueTime:    pq.harr[i].EnqueueTime,
-			index:          pq.harr[i].index,
+			ID:             waiting.ID,
+			PriorityWeight: waiting.PriorityWeight,
+			CustomerName:   waiting.CustomerName,
+			Description:    waiting.Description,
+			EnqueueTime:    waiting.EnqueueTime,
+			TTLInSec:       waiting.TTLInSec,
+			Deadline:       waiting.Deadline,
+			RequiredSkills: waiting.RequiredSkills,
+			ExternalRef:    waiting.ExternalRef,
+			Account:        waiting.Account,
 		}
 		tempArray = append(tempArray, cr)
 	}
 	oldest, _ := getOldestTaskID(pq)
-	s2Struct := Selection2Struct{QueueName: pq.queueName,
-		QueueDescription: pq.queueDescription,
-		Size:             len(pq.harr),
+	s2Struct := Selection2Struct{QueueName: pq.queue.Name(),
+		QueueDescription: pq.queue.Description(),
+		Size:             pq.queue.Len(),
 		OldestTaskID:     oldest,
 		CustomerRequests: tempArray}
 
@@ -61,11 +69,15 @@ func selection2(pq *PriorityQueue, isConsole bool) Selection2Struct {
 	return s2Struct
 }
 
-// This method is for Servicing Customer Request
-func selection3(pq *PriorityQueue, isConsole bool) (Selection3Struct, ErrorStruct, error) {
+// This method is for Servicing Customer Request, agent is optional and restricts the
+// request to the ones matching its skills
+func selection3(pq *PriorityQueue, agent *Agent, isConsole bool) (Selection3Struct, ErrorStruct, error) {
 	logger.Printf("getting selection 3, isConsole: %t", isConsole)
-	if pq.count <= 0 {
-		errorMsg := "Queue is empty."
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	cr, err := peekForAgent(pq, agent)
+	if err != nil {
+		errorMsg := err.Error()
 		if isConsole {
 			fmt.Println(errorMsg)
 		}
@@ -73,13 +85,21 @@ func selection3(pq *PriorityQueue, isConsole bool) (Selection3Struct, ErrorStruc
 
 		return Selection3Struct{}, ErrorStruct{Msg: errorMsg}, errors.New(errorMsg)
 	}
-	cr := extractMax(pq)
+	_, _ = pq.queue.Take(cr.ID)
+	forget(pq, cr)
+	recordEvent(pq, "SERVICED", cr, "")
 	s3Struct := Selection3Struct{ID: cr.ID,
 		PriorityWeight: cr.PriorityWeight,
 		CustomerName:   cr.CustomerName,
 		Description:    cr.Description,
 		EnqueueTime:    cr.EnqueueTime,
-		WaitTimeinSec:  time.Since(cr.EnqueueTime).Seconds()}
+		WaitTimeinSec:  time.Since(cr.EnqueueTime).Seconds(),
+		RequiredSkills: cr.RequiredSkills}
+	if agent != nil {
+		s3Struct.AgentID = agent.ID
+	}
+	recordSample(pq, time.Now(), s3Struct.WaitTimeinSec, false)
+	recordCompleted(pq, cr, SERVICED, s3Struct.AgentID, time.Now())
 
 	if isConsole {
 		fmt.Println("Dequeuing Customer Request")
@@ -91,13 +111,24 @@ func selection3(pq *PriorityQueue, isConsole bool) (Selection3Struct, ErrorStruc
 }
 
 // This method is for Enqueueing Customer Request
-func selection4(pq *PriorityQueue, cr *CustomerRequest, isConsole bool) (Selection4Struct, error) {
+func selection4(pq *PriorityQueue, cr *CustomerRequest, owner string, isConsole bool) (Selection4Struct, error) {
 	logger.Printf("getting selection 4, isConsole: %t", isConsole)
 	logger.Printf("%s, %s, %d", cr.CustomerName, cr.Description, cr.PriorityWeight)
-	if !insert(pq, cr, isConsole) {
-		errorMsg := "The system is working at its peak capacity, please try again later."
-		logger.Printf("error getting selection 4. %s isConsole: %t", errorMsg, isConsole)
-		return Selection4Struct{}, errors.New(errorMsg)
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	cr, deduplicated, err := deduplicate(pq, cr, owner)
+	if err != nil {
+		if isConsole {
+			fmt.Println(err)
+		}
+		logger.Printf("error getting selection 4. %s isConsole: %t", err.Error(), isConsole)
+		return Selection4Struct{}, err
+	}
+	if deduplicated != MERGE {
+		if err := enqueue(pq, cr, owner, isConsole); err != nil {
+			logger.Printf("error getting selection 4. %s isConsole: %t", err.Error(), isConsole)
+			return Selection4Struct{}, err
+		}
 	}
 
 	s4Struct := Selection4Struct{ID: cr.ID,
@@ -105,7 +136,21 @@ func selection4(pq *PriorityQueue, cr *CustomerRequest, isConsole bool) (Selecti
 		CustomerName:    cr.CustomerName,
 		Description:     cr.Description,
 		EnqueueTime:     cr.EnqueueTime,
-		PositionInQueue: len(pq.harr) - 1}
+		PositionInQueue: pq.queue.Len() - 1,
+		ExternalRef:     cr.ExternalRef,
+		Token:           pq.tokens[cr.ID],
+		Deduplicated:    deduplicated}
+	if deduplicated == MERGE {
+		s4Struct.PositionInQueue = pq.queue.Position(cr)
+	}
+	if pq.queue.IsScheduled(cr) {
+		s4Struct.PositionInQueue = -1
+		s4Struct.NotBefore = cr.NotBefore
+	}
+	if _, err := getOverflowed(pq, cr.ID); err == nil {
+		s4Struct.PositionInQueue = -1
+		s4Struct.Overflow = pq.overflowPolicy
+	}
 
 	if isConsole {
 		fmt.Printf("\nCustomer Request is enqueued with following information:\n")
@@ -119,6 +164,13 @@ func selection4(pq *PriorityQueue, cr *CustomerRequest, isConsole bool) (Selecti
 // This method is for Reneging Customer Request
 func selection5(pq *PriorityQueue, delID int, isConsole bool) (Selection5Struct, error) {
 	logger.Printf("getting selection 5, isConsole: %t", isConsole)
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	return renege(pq, delID, isConsole)
+}
+
+// This function reneges the waiting or overflowed Customer Request with id=delID. pq.mutex must be held.
+func renege(pq *PriorityQueue, delID int, isConsole bool) (Selection5Struct, error) {
 	cr, err := deleteByID(pq, delID, isConsole)
 	if err != nil {
 		if isConsole {
@@ -127,6 +179,8 @@ func selection5(pq *PriorityQueue, delID int, isConsole bool) (Selection5Struct,
 		logger.Printf("error getting selection 5. %s, isConsole: %t", err.Error(), isConsole)
 		return Selection5Struct{}, errors.New(err.Error())
 	}
+	pq.renegedCount++
+	recordEvent(pq, "ABANDONED", cr, "RENEGED")
 
 	s5Struct := Selection5Struct{
 		CustomerName:  cr.CustomerName,
@@ -134,6 +188,8 @@ func selection5(pq *PriorityQueue, delID int, isConsole bool) (Selection5Struct,
 		EnqueueTime:   cr.EnqueueTime,
 		WaitTimeinSec: time.Since(cr.EnqueueTime).Seconds(),
 		Message:       "Request reneged successfully"}
+	recordSample(pq, time.Now(), s5Struct.WaitTimeinSec, true)
+	recordCompleted(pq, cr, RENEGED, "", time.Now())
 
 	if isConsole {
 		fmt.Println("Reneged following customer request sucessfully:")
@@ -145,35 +201,47 @@ func selection5(pq *PriorityQueue, delID int, isConsole bool) (Selection5Struct,
 }
 
 // This method is for getting System Information
-func selection6(pq *PriorityQueue, isConsole bool) (Selection6Struct, ErrorStruct, error) {
+func selection6(pq *PriorityQueue, ar *AgentRegistry, isConsole bool) (Selection6Struct, ErrorStruct, error) {
 	logger.Printf("getting selection 6, isConsole: %t", isConsole)
+	agentsInfo := countAgents(ar) // counted before locking pq, the agent registry is always locked first
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
 	status := "IN_SERVICE"
-	if len(pq.harr) >= pq.capacity {
+	if pq.queue.IsFull(pq.offered) {
 		status = "MAX_CAPACITY_REACHED"
 	}
-	oldest, err := getOldestTaskID(pq)
-	if err != nil {
-		if isConsole {
-			fmt.Println(err)
-		}
-		logger.Printf("error getting selection 6. %s, isConsole: %t", err.Error(), isConsole)
-		return Selection6Struct{}, ErrorStruct{Msg: err.Error()}, errors.New(err.Error())
-	}
-	oldestCr, err := getCrByID(pq, oldest)
-	if err != nil {
-		if isConsole {
-			fmt.Println(err)
+	// An empty queue is in service, the oldest request simply has no wait time
+	oldestWait := 0.0
+	if oldest, err := getOldestTaskID(pq); err == nil {
+		oldestCr, err := getCrByID(pq, oldest)
+		if err != nil {
+			if isConsole {
+				fmt.Println(err)
+			}
+			logger.Printf("error getting selection 6. %s, isConsole: %t", err.Error(), isConsole)
+			return Selection6Struct{}, ErrorStruct{Msg: err.Error()}, errors.New(err.Error())
 		}
-		logger.Printf("error getting selection 6. %s, isConsole: %t", err.Error(), isConsole)
-		return Selection6Struct{}, ErrorStruct{Msg: err.Error()}, errors.New(err.Error())
+		oldestWait = time.Since(oldestCr.EnqueueTime).Seconds()
 	}
 	queueInfo := QueueInfo{
-		Name:                           pq.queueName,
-		Size:                           strconv.Itoa(pq.count),
-		OldestCustomerRequestTimeInSec: time.Since(oldestCr.EnqueueTime).Seconds()}
+		Name:                           pq.queue.Name(),
+		Ordering:                       pq.queue.Strategy().Name(),
+		Size:                           strconv.Itoa(pq.queue.Len()),
+		OldestCustomerRequestTimeInSec: oldestWait,
+		RenegedCount:                   pq.renegedCount,
+		ExpiredCount:                   pq.expiredCount,
+		AbandonedCount:                 pq.renegedCount + pq.expiredCount,
+		ScheduledCount:                 pq.queue.ScheduledLen(),
+		OfferedCount:                   pq.offered,
+		OverflowPolicy:                 overflowPolicy(pq),
+		EvictedCount:                   pq.evictedCount,
+		SpilledCount:                   spilledLen(pq),
+		BufferedCount:                  len(pq.buffer)}
 	s6Struct := Selection6Struct{
 		Status: status,
-		Queue:  queueInfo}
+		Queue:  queueInfo,
+		Agents: agentsInfo,
+		Stats:  computeStats(pq, time.Now(), SLATHRESHOLD)}
 
 	if isConsole {
 		jsonData, _ := json.MarshalIndent(s6Struct, "", "    ")
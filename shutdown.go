@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// SHUTDOWNTIMEOUT is how long in-flight API requests may take to finish once shutdown has started
+var SHUTDOWNTIMEOUT = 10 * time.Second
+
+// workers are the goroutines that change the queue in the background, they return once stopWorkers closes stop
+var (
+	workers     sync.WaitGroup
+	workersStop = make(chan struct{})
+	stopOnce    sync.Once
+)
+
+// This function starts run as a background worker
+func startWorker(run func(stop <-chan struct{})) {
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		run(workersStop)
+	}()
+}
+
+// This function stops the background workers and waits until they have returned
+func stopWorkers() {
+	stopOnce.Do(func() { close(workersStop) })
+	workers.Wait()
+}
+
+// This function stops the API server from accepting connections, waits for in-flight
+// requests until the timeout, stops the timers and the dispatcher and flushes the idempotency keys
+// and the log. It is safe to call more than once.
+// An enqueue acknowledged before shutdown is in the queue when this returns, as no handler is cut off
+// mid-mutation. The queue itself is not persisted: the waiting, scheduled, offered and overflowed
+// requests are lost when the process exits.
+func gracefulShutdown(server *http.Server, timeout time.Duration) error {
+	if shuttingDown.Swap(true) {
+		return nil
+	}
+	logger.Println("shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := server.Shutdown(ctx)
+	if err != nil {
+		logger.Printf("error draining API requests. %s", err.Error())
+	}
+
+	// The timers and the dispatcher are stopped before the final flush so they do not change the queue
+	// while it is persisted, only a console selection in progress is left to wait for
+	stopWorkers()
+	PQ.mutex.Lock()
+	logger.Printf("shut down with %d customer requests in queue", PQ.queue.Len())
+	PQ.mutex.Unlock()
+	if saveErr := saveIdempotencyKeys(&IK, time.Now()); err == nil {
+		err = saveErr
+	}
+	if syncErr := logFile.Sync(); err == nil {
+		err = syncErr
+	}
+	return err
+}
+
+// This method is used as a goroutine to shut down gracefully on SIGINT and SIGTERM
+func handleSignals(server *http.Server) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+	logger.Printf("received signal %s", sig)
+	code := 0
+	if err := gracefulShutdown(server, SHUTDOWNTIMEOUT); err != nil {
+		code = 1
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// This test checks that every enqueue acknowledged to an in-flight HTTP request is in the queue after
+// shutdown and that the background workers have stopped before the final flush
+func TestGracefulShutdownKeepsAcknowledgedEnqueues(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: newRouter()}
+	go server.Serve(ln)
+	defer shuttingDown.Store(false)
+
+	url := "http://" + ln.Addr().String() + "/api/v1.0/queue/enqueue"
+	acknowledged := make(chan int, 1000)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				body, _ := json.Marshal(CustomerRequest{CustomerName: "name" + strconv.Itoa(i), Description: "desc", PriorityWeight: j%10 + 1})
+				resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+				if err != nil {
+					return // the server stopped accepting connections
+				}
+				s4Struct := Selection4Struct{}
+				decodeErr := json.NewDecoder(resp.Body).Decode(&s4Struct)
+				resp.Body.Close()
+				if resp.StatusCode == http.StatusOK && decodeErr == nil {
+					acknowledged <- s4Struct.ID
+				}
+			}
+		}(i)
+	}
+
+	var stopped atomic.Bool
+	startWorker(func(stop <-chan struct{}) {
+		<-stop
+		time.Sleep(10 * time.Millisecond)
+		stopped.Store(true)
+	})
+
+	time.Sleep(20 * time.Millisecond)
+	if err := gracefulShutdown(server, 5*time.Second); err != nil {
+		t.Fatalf("gracefulShutdown() failed. %s", err)
+	}
+	if !stopped.Load() {
+		t.Errorf("gracefulShutdown() failed. A background worker was still running")
+	}
+	wg.Wait()
+	close(acknowledged)
+
+	count := 0
+	for id := range acknowledged {
+		count++
+		if _, err := getCrByID(&PQ, id); err != nil {
+			t.Errorf("gracefulShutdown() failed. Acknowledged request %d was lost", id)
+		}
+	}
+	if count == 0 {
+		t.Errorf("gracefulShutdown() failed. No enqueue was acknowledged before shutdown")
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// SLATHRESHOLD is the wait time in seconds within which a request counts as answered in time
+var SLATHRESHOLD = 20.0
+
+// statWindow is a sliding window the metrics are computed over
+type statWindow struct {
+	name   string
+	length time.Duration
+}
+
+var statWindows = []statWindow{
+	{name: "5m", length: 5 * time.Minute},
+	{name: "1h", length: time.Hour},
+	{name: "day", length: 24 * time.Hour},
+}
+
+// statRebuild is the number of added and removed samples above which the wait times of a window are
+// sorted again rather than updated one by one
+const statRebuild = 256
+
+// This function records a serviced or abandoned request, samples older than the longest window are dropped.
+// Samples are kept in time order, a sample taken before the last one counts as recorded at the same time.
+// Serviced requests are also counted for the Prometheus metrics. pq.mutex must be held.
+func recordSample(pq *PriorityQueue, at time.Time, waitInSec float64, abandoned bool) {
+	if !abandoned {
+		pq.servicedCount++
+		pq.waitTimes.observe(waitInSec)
+	}
+	if s := &pq.samples; s.n > 0 && at.Before(s.at(s.first+s.n-1).at) {
+		at = s.at(s.first + s.n - 1).at
+	}
+	pq.samples.add(statSample{at: at, waitInSec: waitInSec, abandoned: abandoned}, at.Add(-statWindows[len(statWindows)-1].length))
+}
+
+// This method returns the sample with the absolute index i
+func (s *statSamples) at(i int) statSample {
+	return s.ring[(s.head+i-s.first)&(len(s.ring)-1)]
+}
+
+// This method is for dropping the samples up to cutoff and appending sample, the ring is doubled if it is full
+func (s *statSamples) add(sample statSample, cutoff time.Time) {
+	for s.n > 0 && !s.ring[s.head].at.After(cutoff) {
+		s.ring[s.head] = statSample{}
+		s.head = (s.head + 1) & (len(s.ring) - 1)
+		s.n--
+		s.first++
+	}
+	if s.n == len(s.ring) {
+		size := 16
+		if len(s.ring) > 0 {
+			size = 2 * len(s.ring)
+		}
+		ring := make([]statSample, size)
+		for i := 0; i < s.n; i++ {
+			ring[i] = s.at(s.first + i)
+		}
+		s.ring, s.head = ring, 0
+	}
+	s.ring[(s.head+s.n)&(len(s.ring)-1)] = sample
+	s.n++
+}
+
+// This method returns the absolute index of the oldest sample after cutoff
+func (s *statSamples) since(cutoff time.Time) int {
+	return s.first + sort.Search(s.n, func(i int) bool { return s.at(s.first + i).at.After(cutoff) })
+}
+
+// This method returns the number of samples that are kept
+func (s *statSamples) len() int {
+	return s.n
+}
+
+// This function inserts v into sorted
+func insertSorted(sorted []float64, v float64) []float64 {
+	i := sort.SearchFloat64s(sorted, v)
+	sorted = append(sorted, 0)
+	copy(sorted[i+1:], sorted[i:])
+	sorted[i] = v
+	return sorted
+}
+
+// This function removes one v from sorted
+func removeSorted(sorted []float64, v float64) []float64 {
+	i := sort.SearchFloat64s(sorted, v)
+	if i < len(sorted) && sorted[i] == v {
+		sorted = append(sorted[:i], sorted[i+1:]...)
+	}
+	return sorted
+}
+
+// This method is for bringing the wait times of c to the samples with absolute indexes from from until
+// before to. Only the samples dropped and recorded since the last update are removed and added, unless
+// there are many of them or the dropped ones were already cut off.
+func (c *windowCache) update(s *statSamples, from int, to int) {
+	changed := (from - c.from) + (to - c.to)
+	if from < c.from || to < c.to || c.from < s.first || changed > statRebuild || 2*changed > to-from {
+		c.serviced, c.abandoned, c.sum = c.serviced[:0], c.abandoned[:0], 0
+		for i := from; i < to; i++ {
+			if sample := s.at(i); sample.abandoned {
+				c.abandoned = append(c.abandoned, sample.waitInSec)
+			} else {
+				c.serviced = append(c.serviced, sample.waitInSec)
+				c.sum += sample.waitInSec
+			}
+		}
+		sort.Float64s(c.serviced)
+		sort.Float64s(c.abandoned)
+	} else {
+		for i := c.from; i < from; i++ {
+			if sample := s.at(i); sample.abandoned {
+				c.abandoned = removeSorted(c.abandoned, sample.waitInSec)
+			} else {
+				c.serviced = removeSorted(c.serviced, sample.waitInSec)
+				c.sum -= sample.waitInSec
+			}
+		}
+		for i := c.to; i < to; i++ {
+			if sample := s.at(i); sample.abandoned {
+				c.abandoned = insertSorted(c.abandoned, sample.waitInSec)
+			} else {
+				c.serviced = insertSorted(c.serviced, sample.waitInSec)
+				c.sum += sample.waitInSec
+			}
+		}
+	}
+	c.from, c.to = from, to
+}
+
+// This function computes the metrics of every window ending at now.
+// Service level is the percentage of requests serviced within threshold out of all requests
+// except those abandoned within threshold. pq.mutex must be held.
+func computeStats(pq *PriorityQueue, now time.Time, threshold float64) []WindowStats {
+	if len(pq.statCache) != len(statWindows) {
+		pq.statCache = make([]windowCache, len(statWindows))
+	}
+	s := &pq.samples
+	to := s.first + s.n
+	windows := make([]WindowStats, 0, len(statWindows))
+	for i, w := range statWindows {
+		from := s.since(now.Add(-w.length))
+		c := &pq.statCache[i]
+		c.update(s, from, to)
+		ws := WindowStats{Window: w.name, ServicedCount: len(c.serviced), AbandonedCount: len(c.abandoned)}
+		inTime := sort.Search(len(c.serviced), func(i int) bool { return c.serviced[i] > threshold })
+		abandonedLate := len(c.abandoned) - sort.Search(len(c.abandoned), func(i int) bool { return c.abandoned[i] > threshold })
+		if ws.ServicedCount > 0 {
+			ws.AverageSpeedOfAnswerInSec = c.sum / float64(ws.ServicedCount)
+			ws.WaitP50InSec = percentile(c.serviced, 50)
+			ws.WaitP90InSec = percentile(c.serviced, 90)
+			ws.WaitP99InSec = percentile(c.serviced, 99)
+		}
+		if ws.ServicedCount+abandonedLate > 0 {
+			ws.ServiceLevelPercent = 100 * float64(inTime) / float64(ws.ServicedCount+abandonedLate)
+		}
+		if ws.ServicedCount+ws.AbandonedCount > 0 {
+			ws.AbandonmentRatePercent = 100 * float64(ws.AbandonedCount) / float64(ws.ServicedCount+ws.AbandonedCount)
+		}
+		ws.ThroughputPerMin = float64(ws.ServicedCount) / w.length.Minutes()
+		windows = append(windows, ws)
+	}
+	return windows
+}
+
+// This function returns the p-th percentile of sorted using the nearest rank method
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// This method is for getting the service level metrics of pq
+func getStats(pq *PriorityQueue, threshold float64) StatsStruct {
+	logger.Printf("getting stats with threshold %f", threshold)
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	return StatsStruct{
+		QueueName:                  pq.queue.Name(),
+		ServiceLevelThresholdInSec: threshold,
+		Windows:                    computeStats(pq, time.Now(), threshold)}
+}
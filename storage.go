@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// This function writes v as JSON to the file name in the log directory.
+// It writes a temporary file first so a crash never leaves a half written file behind.
+func saveJSON(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(logPath, name)
+	if err := ioutil.WriteFile(path+".tmp", data, 0666); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// This function reads v from the JSON file name in the log directory, a missing file is not an error
+func loadJSON(name string, v interface{}) error {
+	data, err := ioutil.ReadFile(filepath.Join(logPath, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -1,27 +1,134 @@
 package main
 
-import "time"
+import (
+	"sync"
+	"time"
 
-// An CustomerRequest is something we manage in a priority queue.
-type CustomerRequest struct {
-	ID             int       `json:"id"`
-	CustomerName   string    `json:"customerName"`
-	Description    string    `json:"description"`
-	PriorityWeight int       `json:"priorityWeight"`
-	EnqueueTime    time.Time `json:"enqueueTime"`
-	// The index is needed by update and is maintained by the heap.Interface methods.
-	index int // The index of the customerRequest in the heap.
+	"github.com/umerf52/ExpertFlow-Programming-Assignment/priorityqueue"
+)
+
+// CustomerRequest is the request of a customer waiting in a PriorityQueue
+type CustomerRequest = priorityqueue.CustomerRequest
+
+// Agent is someone who services CustomerRequests
+type Agent struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Skills maps every skill of the agent to its proficiency, from 1 to 10
+	Skills map[string]int `json:"skills"`
+	// State is one of AVAILABLE, BUSY, WRAP_UP and AWAY
+	State      string      `json:"state"`
+	StateSince time.Time   `json:"stateSince"`
+	Assignment *Assignment `json:"assignment,omitempty"`
 }
 
-// A Queue implements heap.Interface and holds CustomerRequests.
-type Queue []*CustomerRequest
+// Assignment is the CustomerRequest an Agent is currently working on
+type Assignment struct {
+	RequestID    int       `json:"requestId"`
+	CustomerName string    `json:"customerName"`
+	AssignedAt   time.Time `json:"assignedAt"`
+}
+
+// AgentStateJSON is used to change the State of an Agent
+type AgentStateJSON struct {
+	State string `json:"state"`
+}
+
+// AgentRegistry holds the registered Agents
+type AgentRegistry struct {
+	agents map[string]*Agent
+	mutex  sync.Mutex
+}
+
+// Offer is a CustomerRequest offered to an Agent by the Dispatcher, the agent has to accept
+// or reject it before ExpiresAt
+type Offer struct {
+	ID              int              `json:"id"`
+	AgentID         string           `json:"agentId"`
+	OfferedAt       time.Time        `json:"offeredAt"`
+	ExpiresAt       time.Time        `json:"expiresAt"`
+	CustomerRequest *CustomerRequest `json:"customerRequest"`
+}
+
+// Notifier delivers offers to agents, e.g. through a push channel or a softphone
+type Notifier interface {
+	Notify(offer Offer) error
+}
+
+// Dispatcher offers the CustomerRequests of a PriorityQueue to the idle Agents of an AgentRegistry
+type Dispatcher struct {
+	pq           *PriorityQueue
+	ar           *AgentRegistry
+	notifier     Notifier
+	policy       string        // policy is either LONGEST_IDLE or ROUND_ROBIN
+	offerTimeout time.Duration // offerTimeout is how long an agent has to respond to an offer
+	offers       map[int]*pendingOffer
+	agentOffers  map[string]int // agentOffers maps an agent to its pending offer
+	nextOfferID  int
+	lastAgentID  string // lastAgentID is used by ROUND_ROBIN
+	mutex        sync.Mutex
+}
+
+// pendingOffer is an Offer waiting for the response of the agent
+type pendingOffer struct {
+	offer Offer
+	cr    *CustomerRequest
+}
+
+// OfferResponseStruct is the struct to represent the response to an offer
+type OfferResponseStruct struct {
+	OfferID  int              `json:"offerId"`
+	Accepted bool             `json:"accepted"`
+	Request  Selection3Struct `json:"request"`
+}
+
+// Event is used to record changes in the lifecycle of a CustomerRequest
+type Event struct {
+	Type         string    `json:"type"`
+	ID           int       `json:"id"`
+	CustomerName string    `json:"customerName"`
+	Reason       string    `json:"reason,omitempty"`
+	Time         time.Time `json:"time"`
+}
 
 // PriorityQueue wraps the actual priority queue and provides additional functionality
 type PriorityQueue struct {
-	harr                        Queue // harr is a Queue that implements heap interface
-	queueName, queueDescription string
-	capacity, count, key        int  // key is used to uniquely identify CustomerRequests
-	isInitialized               bool // it is used to check if the at least one item has been inserted in harr or not
+	queue                        *priorityqueue.PriorityQueue[*CustomerRequest] // queue holds the waiting and scheduled CustomerRequests
+	offered                      int                                            // offered is the number of CustomerRequests taken out of queue while offered to an agent
+	declined                     map[int]map[string]bool                        // declined maps a CustomerRequest ID to the agents who rejected an offer of it
+	samples                      statSamples                                    // samples holds the serviced and abandoned requests of the last day, oldest first
+	statCache                    []windowCache                                  // statCache holds the sorted wait times of every window between computations
+	renegedCount, expiredCount   int
+	enqueuedCount, servicedCount int
+	rejectedCount                int // rejectedCount is the number of inserts refused at capacity
+	// overflowPolicy decides what happens to inserts at capacity, an empty policy is REJECT.
+	// overflow and buffer hold the requests of the SPILL and BUFFER policies until there is room.
+	overflowPolicy string
+	history        historyStore // history holds the requests that have left the queue for good
+	// byToken holds the waiting, scheduled, offered and overflowed requests by their token, tokens holds
+	// the token of these requests by ID
+	byToken map[string]*CustomerRequest
+	tokens  map[int]string
+	// owners holds the ID of the client who enqueued a request by the ID of the request, until the
+	// request is recorded in the history
+	owners map[int]string
+	// duplicatePolicy decides what happens to a request of a customer who has one in the queue, an empty policy is ALLOW
+	duplicatePolicy string
+	overflow        *priorityqueue.PriorityQueue[*CustomerRequest]
+	buffer          []*CustomerRequest
+	// overflowedIn holds the name of the overflow queue or buffer a request waits in by its ID, until
+	// the request is moved to the queue or recorded in the history
+	overflowedIn map[int]string
+	evictedCount int
+	waitTimes    histogram // waitTimes holds the wait times of serviced requests
+	events       []Event   // events holds the most recent lifecycle events
+	// rateLimit is the number of enqueues per second a client may make with bursts of up to rateBurst,
+	// maxOutstanding is the number of requests a client may have in the queue. Zero means no limit.
+	rateLimit, rateBurst float64
+	maxOutstanding       int
+	buckets              map[string]*tokenBucket // buckets holds the rate limit of every client
+	outstanding          map[string]int          // outstanding counts the requests of every client in the queue
+	mutex                sync.Mutex              // mutex guards everything above, the reaper runs alongside the console and API
 }
 
 // IDJSON is used to in Selection1Struct
@@ -49,12 +156,14 @@ type Selection2Struct struct {
 
 // Selection3Struct is the struct to represent selection 3
 type Selection3Struct struct {
-	ID             int       `json:"id"`
-	CustomerName   string    `json:"customerName"`
-	Description    string    `json:"description"`
-	PriorityWeight int       `json:"priorityWeight"`
-	EnqueueTime    time.Time `json:"enqueueTime"`
-	WaitTimeinSec  float64   `json:"waitTimeinSec"`
+	ID             int            `json:"id"`
+	CustomerName   string         `json:"customerName"`
+	Description    string         `json:"description"`
+	PriorityWeight int            `json:"priorityWeight"`
+	EnqueueTime    time.Time      `json:"enqueueTime"`
+	WaitTimeinSec  float64        `json:"waitTimeinSec"`
+	RequiredSkills map[string]int `json:"requiredSkills,omitempty"`
+	AgentID        string         `json:"agentId,omitempty"`
 }
 
 // Selection4Struct is the struct to represent selection 4
@@ -65,6 +174,16 @@ type Selection4Struct struct {
 	ID              int       `json:"id"`
 	EnqueueTime     time.Time `json:"enqueueTime"`
 	PositionInQueue int       `json:"positionInQueue"`
+	ExternalRef     string    `json:"externalRef,omitempty"`
+	// NotBefore is only set for scheduled requests, PositionInQueue is -1 until they are due.
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+	// Overflow is SPILL or BUFFER if the queue was full and the request waits for room, PositionInQueue is -1
+	Overflow string `json:"overflow,omitempty"`
+	// Deduplicated is MERGE if the request was merged into the earlier request of the customer, whose ID is
+	// returned, or REPLACE if it replaced it
+	Deduplicated string `json:"deduplicated,omitempty"`
+	// Token is the reference the status of the request is looked up and the request is reneged by
+	Token string `json:"token,omitempty"`
 }
 
 // Selection5Struct is the struct to represent selection 5
@@ -79,14 +198,250 @@ type Selection5Struct struct {
 // QueueInfo is used in Selection6Struct
 type QueueInfo struct {
 	Name                           string  `json:"name"`
+	Ordering                       string  `json:"ordering"`
 	Size                           string  `json:"size"`
 	OldestCustomerRequestTimeInSec float64 `json:"oldestCustomerRequestTimeInSec"`
+	RenegedCount                   int     `json:"renegedCount"`
+	ExpiredCount                   int     `json:"expiredCount"`
+	AbandonedCount                 int     `json:"abandonedCount"`
+	ScheduledCount                 int     `json:"scheduledCount"`
+	OfferedCount                   int     `json:"offeredCount"`
+	OverflowPolicy                 string  `json:"overflowPolicy"`
+	EvictedCount                   int     `json:"evictedCount"`
+	SpilledCount                   int     `json:"spilledCount"`
+	BufferedCount                  int     `json:"bufferedCount"`
+}
+
+// AgentsInfo is used in Selection6Struct
+type AgentsInfo struct {
+	Available int `json:"available"`
+	Busy      int `json:"busy"`
+	WrapUp    int `json:"wrapUp"`
+	Away      int `json:"away"`
 }
 
 // Selection6Struct is the struct to represent selection 6
 type Selection6Struct struct {
-	Status string    `json:"status"`
-	Queue  QueueInfo `json:"queue"`
+	Status string        `json:"status"`
+	Queue  QueueInfo     `json:"queue"`
+	Agents AgentsInfo    `json:"agents"`
+	Stats  []WindowStats `json:"stats"`
+}
+
+// histogram counts observations in cumulative buckets like a Prometheus histogram
+type histogram struct {
+	buckets []float64 // upper bounds in ascending order, defaultBuckets if nil
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+// statSample is a serviced or abandoned CustomerRequest used for the service level metrics
+type statSample struct {
+	at        time.Time
+	waitInSec float64
+	abandoned bool
+}
+
+// statSamples holds the samples of the last day, oldest first, in a ring buffer that grows when it is full.
+// Samples are numbered by an absolute index in the order they are recorded.
+type statSamples struct {
+	ring  []statSample // ring holds the samples from head on, wrapping around, its length is a power of two
+	head  int          // head is the index in ring of the oldest sample
+	n     int          // n is the number of samples
+	first int          // first is the absolute index of the oldest sample
+}
+
+// windowCache holds the wait times of the samples of a window from the last computation, so that only
+// the samples recorded or dropped since have to be added or removed
+type windowCache struct {
+	from, to  int       // from and to are the absolute indexes of the first sample and after the last sample
+	serviced  []float64 // serviced holds the wait times of the serviced samples, sorted
+	abandoned []float64 // abandoned holds the wait times of the abandoned samples, sorted
+	sum       float64   // sum is the sum of serviced
+}
+
+// WindowStats is the struct to represent the service level metrics of a sliding window
+type WindowStats struct {
+	Window                    string  `json:"window"`
+	ServicedCount             int     `json:"servicedCount"`
+	AbandonedCount            int     `json:"abandonedCount"`
+	ServiceLevelPercent       float64 `json:"serviceLevelPercent"`
+	AverageSpeedOfAnswerInSec float64 `json:"averageSpeedOfAnswerInSec"`
+	WaitP50InSec              float64 `json:"waitP50InSec"`
+	WaitP90InSec              float64 `json:"waitP90InSec"`
+	WaitP99InSec              float64 `json:"waitP99InSec"`
+	AbandonmentRatePercent    float64 `json:"abandonmentRatePercent"`
+	ThroughputPerMin          float64 `json:"throughputPerMin"`
+}
+
+// StatsStruct is the struct to represent the service level metrics of a queue
+type StatsStruct struct {
+	QueueName                  string        `json:"queueName"`
+	ServiceLevelThresholdInSec float64       `json:"serviceLevelThresholdInSec"`
+	Windows                    []WindowStats `json:"windows"`
+}
+
+// ScheduledStruct is the struct to represent scheduled requests
+type ScheduledStruct struct {
+	QueueName        string             `json:"queueName"`
+	Size             int                `json:"size"`
+	CustomerRequests []*CustomerRequest `json:"customerRequests"`
+}
+
+// EventsStruct is the struct to represent recent lifecycle events
+type EventsStruct struct {
+	QueueName string  `json:"queueName"`
+	Events    []Event `json:"events"`
+}
+
+// tokenBucket limits the rate of enqueues of a client
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// IdempotencyEntry is the response of an enqueue kept for retries
+type IdempotencyEntry struct {
+	Response    Selection4Struct `json:"response"`
+	Fingerprint string           `json:"fingerprint"` // Fingerprint is a hash of the request body
+	CreatedAt   time.Time        `json:"createdAt"`
+}
+
+// IdempotencyStore holds the responses of enqueues by idempotency key
+type IdempotencyStore struct {
+	entries map[string]IdempotencyEntry
+	pending map[string]chan struct{} // pending holds the keys of enqueues in flight, the channel is closed once they are done
+	dirty   bool                     // dirty is set when entries changed since they were last saved
+	written chan struct{}            // written is signalled when a key is stored to have the flusher save the entries
+	mutex   sync.Mutex
+	saving  sync.Mutex // saving keeps a write of the file from overtaking an earlier one, it is taken before mutex
+}
+
+// Principal is an authenticated API client
+type Principal struct {
+	ClientID string
+	Role     string
+}
+
+// PriorityJSON is used to change the PriorityWeight of a CustomerRequest
+type PriorityJSON struct {
+	PriorityWeight int `json:"priorityWeight"`
+}
+
+// RequestStatusStruct is the status of a Customer Request looked up by its token or customer
+type RequestStatusStruct struct {
+	ID              int       `json:"id"`
+	CustomerName    string    `json:"customerName"`
+	PriorityWeight  int       `json:"priorityWeight"`
+	Status          string    `json:"status"`
+	PositionInQueue int       `json:"positionInQueue"`
+	EnqueueTime     time.Time `json:"enqueueTime"`
+	WaitTimeinSec   float64   `json:"waitTimeinSec"`
+	// CompletedAt and AgentID are only set for requests that have left the queue
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	AgentID     string     `json:"agentId,omitempty"`
+}
+
+// CompletedRequest is a Customer Request that has left the queue for good
+type CompletedRequest struct {
+	ID             int       `json:"id"`
+	QueueName      string    `json:"queueName"`
+	CustomerName   string    `json:"customerName"`
+	PriorityWeight int       `json:"priorityWeight"`
+	Account        string    `json:"account,omitempty"`
+	Status         string    `json:"status"` // Status is the final state, e.g. SERVICED, RENEGED or EXPIRED
+	AgentID        string    `json:"agentId,omitempty"`
+	EnqueueTime    time.Time `json:"enqueueTime"`
+	CompletedAt    time.Time `json:"completedAt"`
+	WaitTimeinSec  float64   `json:"waitTimeinSec"`
+	Owner          string    `json:"-"`
+}
+
+// historyStore holds the most recent completed requests in a ring
+type historyStore struct {
+	records []CompletedRequest
+	next    int         // next is the index of the oldest record, which is overwritten next once the ring is full
+	byID    map[int]int // byID maps the ID of a request to its index in records
+}
+
+// CustomerRequestsStruct holds the requests of a customer
+type CustomerRequestsStruct struct {
+	CustomerName string                `json:"customerName"`
+	Requests     []RequestStatusStruct `json:"requests"`
+}
+
+// ReportRow is the struct to represent the completed requests of a time bucket, and of a group if the report is grouped
+type ReportRow struct {
+	BucketStart            time.Time `json:"bucketStart"`
+	Group                  string    `json:"group,omitempty"` // Group is the priority weight or the queue name the row is for
+	VolumeCount            int       `json:"volumeCount"`     // VolumeCount counts all completed requests, whatever their final state
+	ServicedCount          int       `json:"servicedCount"`
+	AbandonedCount         int       `json:"abandonedCount"` // AbandonedCount counts reneged and expired requests
+	AbandonmentRatePercent float64   `json:"abandonmentRatePercent"`
+	AverageWaitInSec       float64   `json:"averageWaitInSec"` // AverageWaitInSec and WaitP90InSec are over serviced requests
+	WaitP90InSec           float64   `json:"waitP90InSec"`
+}
+
+// ReportStruct is the struct to represent a historical report of completed requests
+type ReportStruct struct {
+	From    time.Time   `json:"from"`
+	To      time.Time   `json:"to"`
+	Bucket  string      `json:"bucket"`
+	GroupBy string      `json:"groupBy,omitempty"`
+	Rows    []ReportRow `json:"rows"`
+}
+
+// QuotasJSON is used to set the enqueue quotas of the clients of a queue, zero turns a quota off
+type QuotasJSON struct {
+	RateLimit      float64 `json:"rateLimit"` // RateLimit is the number of enqueues per second with bursts of up to RateBurst
+	RateBurst      float64 `json:"rateBurst"`
+	MaxOutstanding int     `json:"maxOutstanding"` // MaxOutstanding is the number of requests a client may have in the queue
+}
+
+// CapacityJSON is used to resize the queue at runtime
+type CapacityJSON struct {
+	Capacity int `json:"capacity"`
+}
+
+// OrderingJSON is used to switch the ordering of the queue, see newStrategy for the orderings
+type OrderingJSON struct {
+	Ordering string `json:"ordering"`
+}
+
+// BatchEnqueueJSON is the body of a batch enqueue, Mode is ALL_OR_NOTHING or BEST_EFFORT
+type BatchEnqueueJSON struct {
+	Mode             string             `json:"mode"`
+	CustomerRequests []*CustomerRequest `json:"customerRequests"`
+}
+
+// BatchRenegeJSON is the body of a batch renege, Mode is ALL_OR_NOTHING or BEST_EFFORT
+type BatchRenegeJSON struct {
+	Mode string `json:"mode"`
+	IDs  []int  `json:"ids"`
+}
+
+// BatchItemResult is the result of a single item of a batch, Enqueued or Reneged is set on success
+type BatchItemResult struct {
+	Index    int                    `json:"index"`
+	Status   string                 `json:"status"`
+	Enqueued *Selection4Struct      `json:"enqueued,omitempty"`
+	Reneged  *Selection5Struct      `json:"reneged,omitempty"`
+	Error    *Selection4ErrorStruct `json:"error,omitempty"`
+}
+
+// BatchStruct is the struct to represent the results of a batch
+type BatchStruct struct {
+	Mode      string            `json:"mode"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []BatchItemResult `json:"results"`
+}
+
+// ReadinessStruct is the struct to represent health and readiness probes
+type ReadinessStruct struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
 }
 
 // ErrorStruct is used to show error messages
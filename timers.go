@@ -0,0 +1,30 @@
+package main
+
+import "time"
+
+// This method is used as a goroutine to promote due scheduled CustomerRequests and abandon expired ones
+// until stop is closed
+func runTimers(pq *PriorityQueue, interval time.Duration, stop <-chan struct{}) {
+	logger.Println("starting timers")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		var now time.Time
+		select {
+		case <-stop:
+			logger.Println("stopped timers")
+			return
+		case now = <-ticker.C:
+		}
+		pq.mutex.Lock()
+		promoted := promoteDue(pq, now)
+		expired := reapExpired(pq, now)
+		pq.mutex.Unlock()
+		if len(promoted) > 0 {
+			logger.Printf("promoted %d scheduled customer requests", len(promoted))
+		}
+		if len(expired) > 0 {
+			logger.Printf("reaper abandoned %d expired customer requests", len(expired))
+		}
+	}
+}
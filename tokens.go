@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Statuses of live Customer Requests
+const (
+	WAITING    = "WAITING"
+	SCHEDULED  = "SCHEDULED"
+	OFFERED    = "OFFERED"
+	OVERFLOWED = "OVERFLOWED"
+)
+
+var errTokenNotFound = errors.New("request not found")
+
+var errOffered = errors.New("the request is being offered to an agent")
+
+// This function reports if token may be used to renege the request with id=ID, it must be the token
+// of the request. An ID that is not live passes, so that the caller is told it is not found.
+func mayRenegeWith(pq *PriorityQueue, ID int, token string) bool {
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	if _, ok := findLive(pq, ID); !ok {
+		return true
+	}
+	return token != "" && pq.tokens[ID] == token
+}
+
+// This function returns a random token in the form of a version 4 UUID
+func newToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		logger.Fatal(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// This function gives cr a token, adds it to the index by token and records owner as the client who
+// enqueued it. pq.mutex must be held.
+func track(pq *PriorityQueue, cr *CustomerRequest, owner string) {
+	if pq.byToken == nil {
+		pq.byToken = make(map[string]*CustomerRequest)
+		pq.tokens = make(map[int]string)
+		pq.owners = make(map[int]string)
+	}
+	token := newToken()
+	pq.byToken[token] = cr
+	pq.tokens[cr.ID] = token
+	if owner != "" {
+		pq.owners[cr.ID] = owner
+	}
+}
+
+// This function returns the status of the live cr. pq.mutex must be held.
+func statusOf(pq *PriorityQueue, cr *CustomerRequest, now time.Time) RequestStatusStruct {
+	rsStruct := RequestStatusStruct{ID: cr.ID,
+		CustomerName:    cr.CustomerName,
+		PriorityWeight:  cr.PriorityWeight,
+		Status:          OFFERED,
+		PositionInQueue: -1,
+		EnqueueTime:     cr.EnqueueTime,
+		WaitTimeinSec:   now.Sub(cr.EnqueueTime).Seconds()}
+	if position := pq.queue.Position(cr); position >= 0 {
+		rsStruct.Status = WAITING
+		rsStruct.PositionInQueue = position
+	} else if pq.queue.IsScheduled(cr) {
+		rsStruct.Status = SCHEDULED
+		rsStruct.WaitTimeinSec = 0
+	} else if _, err := getOverflowed(pq, cr.ID); err == nil {
+		rsStruct.Status = OVERFLOWED
+	}
+	return rsStruct
+}
+
+// This function reneges the live cr, scheduled requests are cancelled. pq.mutex must be held.
+func renegeRequest(pq *PriorityQueue, cr *CustomerRequest, isConsole bool) (Selection5Struct, error) {
+	switch statusOf(pq, cr, time.Now()).Status {
+	case SCHEDULED:
+		return cancel(pq, cr.ID, isConsole)
+	case OFFERED:
+		return Selection5Struct{}, errOffered
+	}
+	return renege(pq, cr.ID, isConsole)
+}
+
+// This method is for getting the status of the Customer Request with token
+func getByToken(pq *PriorityQueue, token string, isConsole bool) (RequestStatusStruct, error) {
+	logger.Printf("getting request by token, isConsole: %t", isConsole)
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	cr, ok := pq.byToken[token]
+	if !ok {
+		return RequestStatusStruct{}, errTokenNotFound
+	}
+	rsStruct := statusOf(pq, cr, time.Now())
+	if isConsole {
+		jsonData, _ := json.MarshalIndent(rsStruct, "", "    ")
+		fmt.Println(string(jsonData))
+	}
+	return rsStruct, nil
+}
+
+// This method is for Reneging the Customer Request with token
+func renegeByToken(pq *PriorityQueue, token string, isConsole bool) (Selection5Struct, error) {
+	logger.Printf("reneging request by token, isConsole: %t", isConsole)
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	cr, ok := pq.byToken[token]
+	if !ok {
+		return Selection5Struct{}, errTokenNotFound
+	}
+	return renegeRequest(pq, cr, isConsole)
+}
+
+// This function returns the live requests of customerName that mayActFor allows, in the order they were enqueued.
+// pq.mutex must be held.
+func requestsOf(pq *PriorityQueue, customerName string, mayActFor func(owner string) bool) []*CustomerRequest {
+	crs := make([]*CustomerRequest, 0)
+	for _, cr := range pq.byToken {
+		if cr.CustomerName == customerName && mayActFor(pq.owners[cr.ID]) {
+			crs = append(crs, cr)
+		}
+	}
+	sort.Slice(crs, func(i, j int) bool { return crs[i].ID < crs[j].ID })
+	return crs
+}
+
+// This method is for getting the status of the requests of customerName that mayActFor allows
+func getByCustomer(pq *PriorityQueue, customerName string, mayActFor func(owner string) bool, isConsole bool) CustomerRequestsStruct {
+	logger.Printf("getting requests of customer, isConsole: %t", isConsole)
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	now := time.Now()
+	crStruct := CustomerRequestsStruct{CustomerName: customerName, Requests: make([]RequestStatusStruct, 0)}
+	for _, cr := range requestsOf(pq, customerName, mayActFor) {
+		crStruct.Requests = append(crStruct.Requests, statusOf(pq, cr, now))
+	}
+	if isConsole {
+		jsonData, _ := json.MarshalIndent(crStruct, "", "    ")
+		fmt.Println(string(jsonData))
+	}
+	return crStruct
+}
+
+// This method is for Reneging the requests of customerName that mayActFor allows, requests being offered
+// to an agent are kept. errTokenNotFound is returned if none was reneged.
+func renegeByCustomer(pq *PriorityQueue, customerName string, mayActFor func(owner string) bool, isConsole bool) ([]Selection5Struct, error) {
+	logger.Printf("reneging requests of customer, isConsole: %t", isConsole)
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	reneged := make([]Selection5Struct, 0)
+	for _, cr := range requestsOf(pq, customerName, mayActFor) {
+		if s5Struct, err := renegeRequest(pq, cr, isConsole); err == nil {
+			reneged = append(reneged, s5Struct)
+		}
+	}
+	if len(reneged) == 0 {
+		return reneged, errTokenNotFound
+	}
+	return reneged, nil
+}
@@ -20,6 +20,7 @@ func printMenu() {
 	fmt.Println("4. Enqueue Customer Request")
 	fmt.Println("5. Renege Customer Request")
 	fmt.Println("6. System Information")
+	fmt.Println("7. Print Report")
 	fmt.Println("9. Reprint Menu")
 	fmt.Println("0. Exit")
 	fmt.Println("")
@@ -33,19 +34,11 @@ func getSelection() string {
 }
 
 func getOldestTaskID(pq *PriorityQueue) (int, error) {
-	if pq.count <= 0 {
+	cr, err := pq.queue.Oldest()
+	if err != nil {
 		return -1, errors.New("queue is empty")
 	}
-	oldestID := pq.harr[0].ID
-	oldestTime := pq.harr[0].EnqueueTime
-	for i := 1; i < len(pq.harr); i++ {
-		cr := pq.harr[i]
-		if cr.EnqueueTime.Before(oldestTime) {
-			oldestTime = cr.EnqueueTime
-			oldestID = cr.ID
-		}
-	}
-	return oldestID, nil
+	return cr.ID, nil
 }
 
 func getInput() string {
@@ -56,11 +49,10 @@ func getInput() string {
 }
 
 func getCrByID(pq *PriorityQueue, ID int) (*CustomerRequest, error) {
-	for i := 0; i < len(pq.harr); i++ {
-		if pq.harr[i].ID == ID {
-			return pq.harr[i], nil
-		}
+	cr, err := pq.queue.Get(ID)
+	if err != nil || pq.queue.IsScheduled(cr) {
+		logger.Printf("id %d not found", ID)
+		return nil, errors.New("id not found")
 	}
-	logger.Printf("id %d not found", ID)
-	return nil, errors.New("id not found")
+	return cr, nil
 }
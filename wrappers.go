@@ -1,58 +1,186 @@
 package main
 
 import (
-	"container/heap"
 	"errors"
 	"fmt"
+	"time"
+
+	"github.com/umerf52/ExpertFlow-Programming-Assignment/priorityqueue"
 )
 
+// This function creates a PriorityQueue that holds up to capacity requests
+func newPriorityQueue(name string, description string, capacity int) *PriorityQueue {
+	return &PriorityQueue{queue: priorityqueue.New(
+		priorityqueue.WithName(name),
+		priorityqueue.WithDescription(description),
+		priorityqueue.WithCapacity(capacity),
+		priorityqueue.WithLogger(logger))}
+}
+
+var errCapacityReached = errors.New("The system is working at its peak capacity, please try again later.")
+
 // Wrapper function to insert into Priority Queue
 func insert(pq *PriorityQueue, cr *CustomerRequest, isConsole bool) bool {
+	return enqueue(pq, cr, "", isConsole) == nil
+}
+
+// This function enqueues cr of the client owner, which is empty on the console, and applies the overflow policy only if pq is full. errDuplicateCustomer is
+// returned if the unique key of cr is taken and errCapacityReached if there is no room for cr.
+func enqueue(pq *PriorityQueue, cr *CustomerRequest, owner string, isConsole bool) error {
 	logger.Printf("inserting Customer Request")
-	if pq.count >= pq.capacity {
+	err := priorityqueue.ErrFull
+	if _, ok := findCustomer(pq, cr); ok {
+		err = priorityqueue.ErrExists // checked first so that a duplicate never overflows
+	} else if !pq.queue.IsFull(pq.offered) {
+		err = pq.queue.Enqueue(cr)
+	}
+	if errors.Is(err, priorityqueue.ErrFull) {
+		if reason := overflow(pq, cr); reason != "" {
+			pq.enqueuedCount++
+			holdQuota(pq, owner)
+			track(pq, cr, owner)
+			if reason == EVICT {
+				recordEvent(pq, "ENQUEUED", cr, EVICT)
+			} else {
+				recordEvent(pq, "OVERFLOWED", cr, reason)
+			}
+			return nil
+		}
 		errorMsg := "Capacity reached. Could not insert.\n\n"
 		if isConsole {
 			fmt.Printf(errorMsg)
 		}
 		logger.Printf("ERROR: inserting Customer Request. %s", errorMsg)
-		return false
-	}
-	cr.ID = pq.key
-	cr.index = len(pq.harr)
-	pq.key++
-	if !pq.isInitialized {
-		pq.harr = make(Queue, 1)
-		pq.harr[0] = cr
-		heap.Init(&pq.harr)
-		pq.isInitialized = true
+		pq.rejectedCount++
+		return errCapacityReached
+	}
+	if errors.Is(err, priorityqueue.ErrExists) {
+		if isConsole {
+			fmt.Println(errDuplicateCustomer)
+		}
+		logger.Printf("ERROR: inserting Customer Request. %s", errDuplicateCustomer)
+		return errDuplicateCustomer
+	}
+	if err != nil {
+		if isConsole {
+			fmt.Println(err)
+		}
+		logger.Printf("ERROR: inserting Customer Request. %s", err)
+		pq.rejectedCount++
+		return errCapacityReached
+	}
+	pq.enqueuedCount++
+	holdQuota(pq, owner)
+	track(pq, cr, owner)
+	if pq.queue.IsScheduled(cr) {
+		recordEvent(pq, "SCHEDULED", cr, "")
 	} else {
-		heap.Push(&pq.harr, cr)
+		recordEvent(pq, "ENQUEUED", cr, "")
+	}
+	return nil
+}
+
+// This function returns the number of slots in use, scheduled and offered requests reserve their slot
+// so that they can always be put back in the heap
+func occupancy(pq *PriorityQueue) int {
+	return pq.queue.Len() + pq.queue.ScheduledLen() + pq.offered
+}
+
+// This function checks that the optional TTL, Deadline and NotBefore of cr make sense at now
+func validateTiming(cr *CustomerRequest, now time.Time) error {
+	if cr.TTLInSec < 0 || (cr.Deadline != nil && !cr.Deadline.After(now)) {
+		return errors.New("ttlInSec must be positive and deadline must be in the future")
+	}
+	if cr.Deadline != nil && cr.NotBefore != nil && !cr.Deadline.After(*cr.NotBefore) {
+		return errors.New("deadline must be after notBefore")
+	}
+	return nil
+}
+
+// This function moves every scheduled CustomerRequest that is due by now into the heap
+func promoteDue(pq *PriorityQueue, now time.Time) []*CustomerRequest {
+	promoted := pq.queue.Promote(now)
+	for _, cr := range promoted {
+		recordEvent(pq, "ENQUEUED", cr, "PROMOTED")
+	}
+	return promoted
+}
+
+// This function removes the scheduled CustomerRequest with id=cancelID
+func cancelByID(pq *PriorityQueue, cancelID int, isConsole bool) (*CustomerRequest, error) {
+	cr, err := pq.queue.Cancel(cancelID)
+	if err != nil {
+		logger.Printf("error in cancelByID. scheduled id %d not found, isConsole: %t", cancelID, isConsole)
+		return &CustomerRequest{}, errors.New("scheduled id not found")
 	}
-	pq.count++
-	logger.Printf("successfully inserted following:")
-	logger.Println(cr.ID, cr.PriorityWeight, cr.CustomerName, cr.Description, cr.EnqueueTime)
-	return true
+	forget(pq, cr)
+	return cr, nil
 }
 
 // This function returns CustomerRequest with highest PriorityWeight
 func extractMax(pq *PriorityQueue) *CustomerRequest {
-	cr := heap.Pop(&pq.harr).(*CustomerRequest) // Remove the CustomerRequest with highest PriorityWeight
-	pq.count--
+	cr, err := pq.queue.Dequeue() // Remove the CustomerRequest with highest PriorityWeight
+	if err != nil {
+		return &CustomerRequest{}
+	}
+	forget(pq, cr)
 	return cr
 }
 
 // This function deleted the CustomerRequest with id=delID
 func deleteByID(pq *PriorityQueue, delID int, isConsole bool) (*CustomerRequest, error) {
-	cr, err := getCrByID(pq, delID)
+	cr, err := pq.queue.Remove(delID)
+	if err != nil {
+		cr, err = removeOverflowed(pq, delID)
+	}
 	if err != nil {
 		logger.Printf("error in deleteById. %s, isConsole: %t", err.Error(), isConsole)
 		return &CustomerRequest{}, errors.New(err.Error())
 	}
-	maxInt := int(^uint(0) >> 1) // Make a MAX_INT
-	cr.PriorityWeight = maxInt
-	heap.Fix(&pq.harr, cr.index) // Move the CustomerRequest with highest PriorityWeight to the top
-	_ = heap.Pop(&pq.harr).(*CustomerRequest)
-	pq.count--
-
+	forget(pq, cr)
 	return cr, nil
 }
+
+// This function drops what is kept about cr once it has left the queue for good and lets
+// overflowed requests take its slot
+func forget(pq *PriorityQueue, cr *CustomerRequest) {
+	drop(pq, cr)
+	refill(pq)
+}
+
+// This function drops what is kept about cr once it has left the queue for good. Its owner is
+// kept until recordCompleted moves it to the history.
+func drop(pq *PriorityQueue, cr *CustomerRequest) {
+	releaseQuota(pq, pq.owners[cr.ID])
+	delete(pq.declined, cr.ID)
+	delete(pq.byToken, pq.tokens[cr.ID])
+	delete(pq.tokens, cr.ID)
+}
+
+// This function abandons every CustomerRequest whose Deadline is not after now.
+// Only the expired requests are visited, as they are always at the top of the deadline heap.
+func reapExpired(pq *PriorityQueue, now time.Time) []*CustomerRequest {
+	expired := append(pq.queue.Expire(now), reapOverflowed(pq, now)...)
+	for _, cr := range expired {
+		forget(pq, cr)
+		pq.expiredCount++
+		recordEvent(pq, "ABANDONED", cr, "EXPIRED")
+		recordSample(pq, now, now.Sub(cr.EnqueueTime).Seconds(), true)
+		recordCompleted(pq, cr, EXPIRED, "", now)
+	}
+	return expired
+}
+
+// This function returns the ID of the client who enqueued the waiting or scheduled request with id=ID
+func getOwner(pq *PriorityQueue, ID int) (string, error) {
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	cr, err := pq.queue.Get(ID)
+	if err != nil {
+		cr, err = getOverflowed(pq, ID)
+	}
+	if err != nil {
+		return "", err
+	}
+	return pq.owners[cr.ID], nil
+}